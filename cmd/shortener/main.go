@@ -1,18 +1,77 @@
 package main
 
 import (
-	"log"
+	"fmt"
+	"os"
 
 	"github.com/dron1337/shortener/internal/app"
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/logger"
+	"go.uber.org/zap"
 )
 
+// commands — подкоманды для работы с хранилищами без запуска сервера.
+var commands = map[string]func(args []string, log *zap.Logger) error{
+	"rebalance":       runRebalance,
+	"export":          runExport,
+	"import":          runImport,
+	"migrate-storage": runMigrateStorage,
+}
+
 func main() {
-	logger := log.New(log.Writer(), "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-	server, err := app.NewServer(logger)
+	// Пока конфигурация не прочитана, пишем в консоль с уровнем по умолчанию
+	log, err := logger.New("info", "console")
 	if err != nil {
-		logger.Fatalf("Failed to create server: %v", err)
+		panic(err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfig(os.Args[2:]); err != nil {
+			log.Fatal("Config command failed", zap.Error(err))
+		}
+		return
 	}
+	if len(os.Args) > 1 {
+		if run, ok := commands[os.Args[1]]; ok {
+			if err := run(os.Args[2:], log); err != nil {
+				log.Fatal("Command failed", zap.String("command", os.Args[1]), zap.Error(err))
+			}
+			return
+		}
+	}
+	cfg, err := config.LoadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	log, err = logger.New(cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		panic(err)
+	}
+	defer log.Sync()
+	server, err := app.NewServer(cfg, log)
+	if err != nil {
+		log.Fatal("Failed to create server", zap.Error(err))
+	}
+	server.Runtime.SetLoader(func() (*config.Config, error) {
+		return config.LoadConfig(os.Args[1:])
+	})
 	if err := server.Start(); err != nil {
-		server.Logger.Fatalf("Error starting server: %s", err)
+		log.Fatal("Error starting server", zap.Error(err))
+	}
+}
+
+// runConfig выполняет подкоманды `shortener config ...`.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return fmt.Errorf("usage: shortener config print [flags]")
+	}
+	cfg, err := config.LoadConfig(args[1:])
+	if err != nil {
+		return err
+	}
+	out, err := cfg.YAML()
+	if err != nil {
+		return err
 	}
+	_, err = os.Stdout.Write(out)
+	return err
 }
@@ -1,35 +1,54 @@
 package main
 
-/*
 import (
-	"fmt"
-	"log"
-	"net/http"
 	"testing"
 
 	"github.com/dron1337/shortener/internal/app"
 	"github.com/dron1337/shortener/internal/config"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 )
 
 func TestServer(t *testing.T) {
-	cfg := &config.Config{
-		ServerAddress: "localhost:8080",
-		BaseURL:       "http://test.example",
-	}
+	cfg := config.Default()
+	cfg.ServerAddress = "localhost:0"
+	cfg.BaseURL = "http://test.example"
 
 	t.Run("TestServerStartStop", func(t *testing.T) {
-		//mockStore := new(MockStorage)
-		logger := log.Default()
+		logger := zap.NewNop()
 
-		server, err := app.NewServer(logger, cfg, nil)
+		server, err := app.NewServer(&cfg, logger)
 		assert.NoError(t, err)
 
 		// Тестируем graceful shutdown
 		done := make(chan struct{})
 		go func() {
 			err := server.Start()
-			assert.ErrorIs(t, err, http.ErrServerClosed)
+			assert.NoError(t, err)
+			close(done)
+		}()
+
+		err = server.Stop()
+		assert.NoError(t, err)
+		<-done
+	})
+
+	t.Run("TestServerStartStopTLS", func(t *testing.T) {
+		tlsCfg := cfg
+		tlsCfg.TLSSelfSigned = true
+		tlsCfg.TLSRedirectAddr = "localhost:0"
+		tlsCfg.GRPCAddress = "localhost:0"
+
+		server, err := app.NewServer(&tlsCfg, zap.NewNop())
+		assert.NoError(t, err)
+		assert.NotNil(t, server.HTTPServer.TLSConfig)
+		assert.NotNil(t, server.RedirectServer)
+		assert.NotNil(t, server.GRPCServer)
+
+		done := make(chan struct{})
+		go func() {
+			err := server.Start()
+			assert.NoError(t, err)
 			close(done)
 		}()
 
@@ -38,4 +57,3 @@ func TestServer(t *testing.T) {
 		<-done
 	})
 }
-*/
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/migrate"
+	"github.com/dron1337/shortener/internal/store"
+	"go.uber.org/zap"
+)
+
+// runMigrateStorage выполняет `shortener migrate-storage --from схема:адрес --to схема:адрес`:
+// копирует ссылки пачками, сохраняя контрольную точку, и проверяет результат.
+// Прерванный перенос продолжается с контрольной точки, если источник за это время не менялся.
+func runMigrateStorage(args []string, log *zap.Logger) error {
+	fs := flag.NewFlagSet("migrate-storage", flag.ContinueOnError)
+	from := fs.String("from", "", "Source storage: file:PATH, postgres:DSN, sqlite:PATH, bolt:PATH or shards:DSN,DSN")
+	to := fs.String("to", "", "Target storage in the same form as -from")
+	batchSize := fs.Int("batch-size", 1000, "URLs copied at once")
+	checkpoint := fs.String("checkpoint", "migrate-storage.checkpoint", "Checkpoint file to resume from; empty disables resuming")
+	overwrite := fs.Bool("overwrite", false, "Replace target URLs with the same short keys instead of skipping them")
+	sample := fs.Int("verify-sample", 100, "Random source URLs looked up in the target after copying")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" {
+		return errors.New("both -from and -to are required")
+	}
+	if *from == *to {
+		return errors.New("source and target are the same storage")
+	}
+	if *batchSize <= 0 || *sample < 0 {
+		return errors.New("batch size must be positive and verify sample not negative")
+	}
+
+	defaults := config.Default()
+	pool := store.PoolConfig{
+		MaxOpenConns:    defaults.DBMaxOpenConns,
+		MaxIdleConns:    defaults.DBMaxIdleConns,
+		ConnMaxLifetime: defaults.DBConnMaxLifetime,
+	}
+	source, closeSource, err := migrate.Open(*from, pool)
+	if err != nil {
+		return fmt.Errorf("open source: %w", err)
+	}
+	defer closeSource(context.Background())
+	target, closeTarget, err := migrate.Open(*to, pool)
+	if err != nil {
+		return fmt.Errorf("open target: %w", err)
+	}
+	defer closeTarget(context.Background())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	result, err := migrate.Copy(ctx, source, target, migrate.Options{
+		BatchSize:      *batchSize,
+		Overwrite:      *overwrite,
+		CheckpointPath: *checkpoint,
+		Migration:      migrate.MigrationID(*from, *to),
+		Progress: func(copied int, total store.ImportResult) {
+			log.Info("Migration progress", zap.Int("copied", copied), zap.Int("imported", total.Imported),
+				zap.Int("overwritten", total.Overwritten), zap.Int("skipped", total.Skipped))
+		},
+	})
+	log.Info("Copy finished", zap.Int("resumed_after", result.Resumed), zap.Int("imported", result.Imported),
+		zap.Int("overwritten", result.Overwritten), zap.Int("skipped", result.Skipped))
+	if err != nil {
+		return err
+	}
+
+	report, err := migrate.Verify(ctx, source, target, *sample)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	log.Info("Verification finished", zap.Int("source_count", report.SourceCount), zap.Int("target_count", report.TargetCount),
+		zap.Int("sampled", report.Sampled), zap.Strings("mismatches", report.Mismatches))
+	if !report.OK() {
+		return fmt.Errorf("verification failed: %d URLs in source, %d in target, %d of %d sampled keys differ",
+			report.SourceCount, report.TargetCount, len(report.Mismatches), report.Sampled)
+	}
+	// Перенос завершён: следующий запуск начнётся с начала
+	if *checkpoint != "" {
+		if err := os.Remove(*checkpoint); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/store"
+	"go.uber.org/zap"
+)
+
+// runRebalance выполняет `shortener rebalance [-dry-run] [-batch-size N] [-- флаги конфигурации]`:
+// переносит ссылки по шардам после добавления нового DSN в конец DATABASE_SHARDS.
+// Сервер с новым списком шардов можно запускать до переноса: ключи, которые ещё
+// не перенесены, до его окончания не находятся.
+func runRebalance(args []string, log *zap.Logger) error {
+	fs := flag.NewFlagSet("rebalance", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "Only count URLs that would be moved")
+	batchSize := fs.Int("batch-size", 1000, "Rows read from a shard at once")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *batchSize <= 0 {
+		return errors.New("batch size must be positive")
+	}
+	cfg, err := config.LoadConfig(fs.Args())
+	if err != nil {
+		return err
+	}
+	dsns := cfg.ShardDSNs()
+	if len(dsns) == 0 {
+		return errors.New("DATABASE_SHARDS is not configured")
+	}
+	sharded, err := store.OpenShards(dsns, store.PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+	})
+	if err != nil {
+		return err
+	}
+	defer sharded.Close(context.Background())
+
+	// Прерванный перенос безопасно запустить снова
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stats, err := sharded.Rebalance(ctx, *batchSize, *dryRun, func(shard int, stats store.RebalanceStats) {
+		log.Info("Rebalance progress", zap.Int("shard", shard), zap.Int("scanned", stats.Scanned), zap.Int("moved", stats.Moved))
+	})
+	log.Info("Rebalance finished", zap.Bool("dry_run", *dryRun), zap.Int("shards", len(dsns)),
+		zap.Int("scanned", stats.Scanned), zap.Int("moved", stats.Moved))
+	return err
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dron1337/shortener/internal/app"
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/store"
+	"github.com/dron1337/shortener/internal/transfer"
+	"go.uber.org/zap"
+)
+
+// openStorages подключает хранилища из конфигурации. В отличие от сервера,
+// команды не продолжают работу с памятью вместо недоступной базы или файла.
+func openStorages(args []string, log *zap.Logger) (*app.Storages, error) {
+	cfg, err := config.LoadConfig(args)
+	if err != nil {
+		return nil, err
+	}
+	storages := app.OpenStorages(cfg, log)
+	if storages.Primary() == store.Transferable(storages.Memory) {
+		storages.Close(context.Background())
+		return nil, errors.New("no database or file storage is available")
+	}
+	return storages, nil
+}
+
+// runExport выполняет `shortener export [-format jsonl|csv] [-o файл] [-- флаги конфигурации]`:
+// выгружает все ссылки основного хранилища, по умолчанию в stdout.
+func runExport(args []string, log *zap.Logger) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := fs.String("format", "jsonl", "Output format: jsonl or csv")
+	output := fs.String("o", "", "Output file (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	format, err := transfer.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	storages, err := openStorages(fs.Args(), log)
+	if err != nil {
+		return err
+	}
+	defer storages.Close(context.Background())
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	n, err := transfer.Export(ctx, storages.Primary(), transfer.NewEncoder(w, format))
+	log.Info("Export finished", zap.Int("exported", n))
+	return err
+}
+
+// runImport выполняет `shortener import [-format jsonl|csv] [-i файл] [-overwrite] [-- флаги конфигурации]`:
+// загружает ссылки, по умолчанию из stdin, во все настроенные хранилища.
+// Кэш работающего сервера не сбрасывается: заменённые ссылки обновятся в нём через CACHE_TTL.
+func runImport(args []string, log *zap.Logger) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatName := fs.String("format", "jsonl", "Input format: jsonl or csv")
+	input := fs.String("i", "", "Input file (default stdin)")
+	overwrite := fs.Bool("overwrite", false, "Replace URLs whose short keys are taken instead of skipping them")
+	batchSize := fs.Int("batch-size", 1000, "URLs written at once")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	format, err := transfer.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	if *batchSize <= 0 {
+		return errors.New("batch size must be positive")
+	}
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	storages, err := openStorages(fs.Args(), log)
+	if err != nil {
+		return err
+	}
+	defer storages.Close(context.Background())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Хранилище в памяти исчезнет вместе с процессом
+	var targets []store.Transferable
+	for _, target := range storages.ImportTargets() {
+		if target != store.Transferable(storages.Memory) {
+			targets = append(targets, target)
+		}
+	}
+	result, err := transfer.Import(ctx, transfer.NewDecoder(r, format), targets, transfer.Options{
+		Overwrite: *overwrite,
+		BatchSize: *batchSize,
+		OnBatch: func(total store.ImportResult) {
+			log.Info("Import progress", zap.Int("imported", total.Imported),
+				zap.Int("overwritten", total.Overwritten), zap.Int("skipped", total.Skipped))
+		},
+	})
+	log.Info("Import finished", zap.Int("imported", result.Imported),
+		zap.Int("overwritten", result.Overwritten), zap.Int("skipped", result.Skipped))
+	return err
+}
@@ -0,0 +1,195 @@
+// Package accesslog пишет журнал запросов в формате Common/Combined Log Format или JSON.
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Форматы журнала.
+const (
+	FormatCommon   = "common"
+	FormatCombined = "combined"
+	FormatJSON     = "json"
+)
+
+// clfTime — формат времени Apache: [10/Oct/2000:13:55:36 -0700].
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// Entry — одна запись журнала.
+type Entry struct {
+	Time      time.Time     `json:"time"`
+	ClientIP  string        `json:"client_ip"`
+	UserID    string        `json:"user_id,omitempty"`
+	Method    string        `json:"method"`
+	URI       string        `json:"uri"`
+	Proto     string        `json:"proto"`
+	Status    int           `json:"status"`
+	Size      int64         `json:"size"`
+	Referer   string        `json:"referer,omitempty"`
+	UserAgent string        `json:"user_agent,omitempty"`
+	ShortKey  string        `json:"short_key,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+	Duration  time.Duration `json:"-"`
+}
+
+// Append дописывает запись в buf в заданном формате без перевода строки.
+// В CLF ключ ссылки виден в строке запроса, отдельным полем он есть только в JSON.
+func (e *Entry) Append(buf []byte, format string) []byte {
+	if format == FormatJSON {
+		data, _ := json.Marshal(struct {
+			*Entry
+			DurationMS float64 `json:"duration_ms"`
+		}{e, float64(e.Duration.Microseconds()) / 1000})
+		return append(buf, data...)
+	}
+	buf = append(buf, dash(e.ClientIP)...)
+	buf = append(buf, " - "...)
+	buf = append(buf, dash(e.UserID)...)
+	buf = append(buf, " ["...)
+	buf = e.Time.AppendFormat(buf, clfTime)
+	buf = append(buf, "] "...)
+	buf = strconv.AppendQuote(buf, e.Method+" "+e.URI+" "+e.Proto)
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, int64(e.Status), 10)
+	buf = append(buf, ' ')
+	if e.Size > 0 {
+		buf = strconv.AppendInt(buf, e.Size, 10)
+	} else {
+		buf = append(buf, '-')
+	}
+	if format == FormatCombined {
+		buf = append(buf, ' ')
+		buf = strconv.AppendQuote(buf, dash(e.Referer))
+		buf = append(buf, ' ')
+		buf = strconv.AppendQuote(buf, dash(e.UserAgent))
+	}
+	return buf
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// ParseFormat проверяет название формата.
+func ParseFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case FormatCommon, "clf":
+		return FormatCommon, nil
+	case FormatCombined:
+		return FormatCombined, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	}
+	return "", fmt.Errorf("unknown access log format %q", format)
+}
+
+// Options — параметры журнала.
+type Options struct {
+	// Output — "stdout" или путь к файлу; пустой вывод отключает журнал.
+	Output string
+	Format string
+	// MaxSize — размер файла в байтах, после которого он ротируется; 0 отключает ротацию.
+	MaxSize    int64
+	MaxBackups int
+	// RedirectSampleRate — доля успешных редиректов, попадающих в журнал (0..1).
+	// Ошибки и прочие ответы пишутся всегда.
+	RedirectSampleRate float64
+}
+
+// Logger пишет записи журнала. Нулевой *Logger ничего не пишет.
+type Logger struct {
+	format  string
+	rate    float64
+	mu      sync.Mutex
+	w       io.Writer
+	closer  io.Closer
+	onError func(error)
+	// redirects считает успешные редиректы для детерминированной выборки
+	redirects atomic.Uint64
+}
+
+// New создаёт журнал поверх w.
+func New(w io.Writer, format string, redirectSampleRate float64) (*Logger, error) {
+	format, err := ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	if redirectSampleRate < 0 || redirectSampleRate > 1 {
+		return nil, fmt.Errorf("redirect sample rate must be between 0 and 1")
+	}
+	return &Logger{format: format, rate: redirectSampleRate, w: w}, nil
+}
+
+// Open создаёт журнал по параметрам. Пустой вывод возвращает nil без ошибки.
+func Open(opts Options) (*Logger, error) {
+	if opts.Output == "" {
+		return nil, nil
+	}
+	var w io.Writer = os.Stdout
+	var closer io.Closer
+	if opts.Output != "stdout" {
+		file, err := OpenRotatingFile(opts.Output, opts.MaxSize, opts.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		w, closer = file, file
+	}
+	l, err := New(w, opts.Format, opts.RedirectSampleRate)
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, err
+	}
+	l.closer = closer
+	return l, nil
+}
+
+// OnError задаёт обработчик ошибок записи; по умолчанию они игнорируются.
+func (l *Logger) OnError(fn func(error)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onError = fn
+}
+
+// sampled решает, попадает ли ответ в журнал. Из каждых 1/rate успешных
+// редиректов пишется ровно один, а не случайная доля.
+func (l *Logger) sampled(status int) bool {
+	if status < 300 || status >= 400 || l.rate >= 1 {
+		return true
+	}
+	n := l.redirects.Add(1)
+	return uint64(float64(n)*l.rate) != uint64(float64(n-1)*l.rate)
+}
+
+// Log пишет запись, если она проходит выборку.
+func (l *Logger) Log(e *Entry) {
+	if l == nil || !l.sampled(e.Status) {
+		return
+	}
+	line := append(e.Append(make([]byte, 0, 256), l.format), '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(line); err != nil && l.onError != nil {
+		l.onError(err)
+	}
+}
+
+// Close закрывает файл журнала.
+func (l *Logger) Close() error {
+	if l == nil || l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
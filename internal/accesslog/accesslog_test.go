@@ -0,0 +1,107 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEntry() *Entry {
+	return &Entry{
+		Time:      time.Date(2000, time.October, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600)),
+		ClientIP:  "203.0.113.7",
+		UserID:    "user-1",
+		Method:    "GET",
+		URI:       "/abc123",
+		Proto:     "HTTP/1.1",
+		Status:    307,
+		Size:      0,
+		Referer:   "https://example.com/",
+		UserAgent: "curl/8.0",
+		ShortKey:  "abc123",
+	}
+}
+
+func TestEntryFormats(t *testing.T) {
+	e := testEntry()
+	assert.Equal(t, `203.0.113.7 - user-1 [10/Oct/2000:13:55:36 -0700] "GET /abc123 HTTP/1.1" 307 -`,
+		string(e.Append(nil, FormatCommon)))
+	assert.Equal(t, `203.0.113.7 - user-1 [10/Oct/2000:13:55:36 -0700] "GET /abc123 HTTP/1.1" 307 - "https://example.com/" "curl/8.0"`,
+		string(e.Append(nil, FormatCombined)))
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(e.Append(nil, FormatJSON), &decoded))
+	assert.Equal(t, "abc123", decoded["short_key"])
+	assert.Equal(t, "user-1", decoded["user_id"])
+	assert.Equal(t, "203.0.113.7", decoded["client_ip"])
+	assert.Equal(t, float64(307), decoded["status"])
+
+	e.UserID, e.Referer = "", ""
+	assert.Equal(t, `203.0.113.7 - - [10/Oct/2000:13:55:36 -0700] "GET /abc123 HTTP/1.1" 307 - "-" "curl/8.0"`,
+		string(e.Append(nil, FormatCombined)))
+}
+
+func TestSampling(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&buf, FormatCommon, 0.25)
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		e := testEntry()
+		l.Log(e)
+	}
+	assert.Equal(t, 25, strings.Count(buf.String(), "\n"), "успешные редиректы пишутся с заданной долей")
+
+	buf.Reset()
+	for _, status := range []int{200, 201, 400, 404, 410, 500} {
+		e := testEntry()
+		e.Status = status
+		l.Log(e)
+	}
+	assert.Equal(t, 6, strings.Count(buf.String(), "\n"), "ошибки и прочие ответы пишутся всегда")
+
+	_, err = New(&buf, FormatCommon, 1.5)
+	assert.Error(t, err)
+	_, err = New(&buf, "xml", 1)
+	assert.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&buf, FormatJSON, 1)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Use(l.Middleware(func(r *http.Request) string { return "198.51.100.1" }))
+	router.HandleFunc("/{key}", func(w http.ResponseWriter, r *http.Request) {
+		SetUserID(r.Context(), "user-42")
+		http.Redirect(w, r, "https://example.com", http.StatusTemporaryRedirect)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/abc?x=1", nil)
+	req.Header.Set("Referer", "https://ref.example/")
+	req.Header.Set("User-Agent", "test-agent")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var e map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &e))
+	assert.Equal(t, "198.51.100.1", e["client_ip"])
+	assert.Equal(t, "user-42", e["user_id"])
+	assert.Equal(t, "/abc?x=1", e["uri"])
+	assert.Equal(t, "abc", e["short_key"])
+	assert.Equal(t, "https://ref.example/", e["referer"])
+	assert.Equal(t, "test-agent", e["user_agent"])
+	assert.Equal(t, float64(http.StatusTemporaryRedirect), e["status"])
+
+	var disabled *Logger
+	next := http.NotFoundHandler()
+	assert.NotNil(t, disabled.Middleware(nil)(next))
+}
@@ -0,0 +1,84 @@
+package accesslog
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/dron1337/shortener/internal/logger"
+	"github.com/gorilla/mux"
+)
+
+type annotationsKey struct{}
+
+// annotations — сведения, которые становятся известны глубже по цепочке middleware.
+type annotations struct {
+	userID string
+}
+
+// SetUserID сообщает журналу пользователя текущего запроса.
+func SetUserID(ctx context.Context, userID string) {
+	if a, ok := ctx.Value(annotationsKey{}).(*annotations); ok {
+		a.userID = userID
+	}
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// Middleware пишет запись о каждом запросе. clientIP определяет адрес клиента
+// с учётом доверенных прокси. Для nil-журнала возвращает обработчик без изменений.
+func (l *Logger) Middleware(clientIP func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			a := &annotations{}
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), annotationsKey{}, a)))
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			uri := r.RequestURI
+			if uri == "" {
+				uri = r.URL.RequestURI()
+			}
+			l.Log(&Entry{
+				Time:      start,
+				ClientIP:  clientIP(r),
+				UserID:    a.userID,
+				Method:    r.Method,
+				URI:       uri,
+				Proto:     r.Proto,
+				Status:    rec.status,
+				Size:      rec.size,
+				Referer:   r.Referer(),
+				UserAgent: r.UserAgent(),
+				ShortKey:  mux.Vars(r)["key"],
+				RequestID: logger.RequestID(r.Context()),
+				Duration:  time.Since(start),
+			})
+		})
+	}
+}
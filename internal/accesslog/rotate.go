@@ -0,0 +1,93 @@
+package accesslog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile — файл, который при превышении размера переименовывается в path.1,
+// а старые копии сдвигаются (path.1 -> path.2 ...). Хранится не больше maxBackups копий.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile открывает файл на дозапись. maxSize <= 0 отключает ротацию.
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open access log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("open access log: %w", err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate сдвигает копии и начинает новый файл. Вызывается под mu.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	if f.maxBackups > 0 {
+		os.Remove(f.backup(f.maxBackups))
+		for i := f.maxBackups - 1; i >= 1; i-- {
+			os.Rename(f.backup(i), f.backup(i+1))
+		}
+		if err := os.Rename(f.path, f.backup(1)); err != nil {
+			return fmt.Errorf("rotate access log: %w", err)
+		}
+	} else if err := os.Truncate(f.path, 0); err != nil {
+		return fmt.Errorf("rotate access log: %w", err)
+	}
+	return f.open()
+}
+
+func (f *RotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", f.path, n)
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
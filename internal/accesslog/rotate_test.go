@@ -0,0 +1,39 @@
+package accesslog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := OpenRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, "fourth\n", read(path))
+	assert.Equal(t, "third\n", read(path+".1"))
+	assert.Equal(t, "second\n", read(path+".2"))
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err), "старше maxBackups копии удаляются")
+
+	require.NoError(t, f.Close())
+	_, err = f.Write([]byte("late\n"))
+	assert.Error(t, err)
+	assert.False(t, strings.Contains(read(path), "late"))
+}
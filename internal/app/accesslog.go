@@ -0,0 +1,32 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/dron1337/shortener/internal/accesslog"
+	"github.com/dron1337/shortener/internal/auth"
+	"github.com/dron1337/shortener/internal/clientip"
+	"github.com/dron1337/shortener/internal/config"
+)
+
+// accessLogClientIP определяет адрес клиента для журнала так же, как ограничитель запросов.
+func accessLogClientIP(cfg *config.Config) func(*http.Request) string {
+	trusted, _ := clientip.ParseCIDRs(cfg.TrustedProxies)
+	resolver := clientip.NewResolver(trusted)
+	return func(r *http.Request) string {
+		if ip := resolver.ClientIP(r); ip != nil {
+			return ip.String()
+		}
+		return ""
+	}
+}
+
+// accessLogUser передаёт в журнал пользователя, определённого auth.AuthMiddleware.
+func accessLogUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userID, ok := r.Context().Value(auth.UserIDKey).(string); ok {
+			accesslog.SetUserID(r.Context(), userID)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// requireAdmin пропускает запрос, только если он несёт токен администратора.
+// Без настроенного токена административные эндпоинты недоступны.
+func (h *URLHandler) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token := h.conf().AdminToken
+	if token == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return false
+	}
+	got, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func (h *URLHandler) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	result, err := h.runtime.Reload()
+	logReload(h.log(r.Context()), result, err)
+	if err != nil {
+		h.writeJSONError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
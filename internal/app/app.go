@@ -2,103 +2,229 @@ package app
 
 import (
 	"context"
-	"log"
+	"errors"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
+	"github.com/dron1337/shortener/internal/auth"
+	"github.com/dron1337/shortener/internal/certs"
 	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/lifecycle"
+	"github.com/dron1337/shortener/internal/policy"
+	"github.com/dron1337/shortener/internal/resp"
 	"github.com/dron1337/shortener/internal/store"
 	_ "github.com/lib/pq"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 type Server struct {
-	Logger     *log.Logger
+	Logger     *zap.Logger
 	HTTPServer *http.Server
+	// RedirectServer принимает HTTP и перенаправляет на HTTPS, если он настроен.
+	RedirectServer *http.Server
+	Certificates   *certs.Reloader
+	// GRPCServer обслуживает gRPC API на отдельном порту, если он настроен.
+	GRPCServer *grpc.Server
 	Config     *config.Config
 	Storages   *Storages
+	Runtime    *Runtime
+	// Lifecycle запускает компоненты сервера и останавливает их в обратном порядке.
+	Lifecycle *lifecycle.Manager
+	// serveErr получает ошибки слушателей, упавших после запуска.
+	serveErr chan error
+	// exit завершает процесс при повторном сигнале во время остановки.
+	exit func(code int)
 }
 type Storages struct {
-	Postgres    *store.PostgresStorage
+	Postgres *store.PostgresStorage
+	// SQLite заменяет Postgres, если DATABASE_DSN начинается с sqlite://.
+	SQLite *store.SQLiteStorage
+	// Bolt — встроенное хранилище в одном файле, если DATABASE_DSN начинается с bolt://.
+	Bolt *store.BoltStorage
+	// Sharded распределяет ссылки по нескольким базам Postgres, если задан DATABASE_SHARDS.
+	Sharded     *store.ShardedStorage
 	FileStorage *store.FileStorage
 	Memory      *store.InMemoryStorage
+	// Cache — кэш чтения поверх основного хранилища, если он настроен.
+	Cache *store.CacheStore
+	// CacheClient — соединение с RESP-сервером кэша.
+	CacheClient *resp.Client
+	// LocalCache — кэш горячих ссылок в памяти процесса перед CacheClient и хранилищем.
+	LocalCache *store.ShardedLRU
 }
 
+// ErrForcedShutdown — остановка прервана повторным сигналом.
+var ErrForcedShutdown = errors.New("shutdown forced by second signal")
+
+// Start запускает компоненты и обрабатывает сигналы до остановки сервера.
+// SIGHUP перечитывает конфигурацию, SIGINT и SIGTERM останавливают сервер,
+// повторный сигнал во время остановки завершает процесс немедленно.
 func (s *Server) Start() error {
-	s.Logger.Println("Starting server on", s.HTTPServer.Addr)
+	s.Logger.Info("Starting server", zap.String("address", s.HTTPServer.Addr), zap.Bool("tls", s.Certificates != nil))
 
-	serverErr := make(chan error, 1)
+	sigChan := make(chan os.Signal, 2)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
 
-	go func() {
-		if err := s.HTTPServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			serverErr <- err
+	if err := s.Lifecycle.Start(context.Background()); err != nil {
+		if errors.Is(err, lifecycle.ErrStopped) {
+			return nil
 		}
-		close(serverErr)
-	}()
+		return err
+	}
+	for {
+		select {
+		case sig := <-sigChan:
+			s.Logger.Info("Received signal", zap.Stringer("signal", sig))
+			if sig == syscall.SIGHUP {
+				s.Reload()
+				continue
+			}
+			return s.shutdown(sigChan)
+		case err := <-s.serveErr:
+			s.Logger.Error("Listener failed", zap.Error(err))
+			s.Stop()
+			return err
+		case <-s.Lifecycle.Done():
+			return nil
+		}
+	}
+}
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+// shutdown останавливает сервер, продолжая слушать сигналы: второй SIGINT или SIGTERM
+// не ждёт завершения остановки.
+func (s *Server) shutdown(sigChan <-chan os.Signal) error {
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.Stop()
+	}()
+	for {
+		select {
+		case err := <-stopped:
+			return err
+		case sig := <-sigChan:
+			if sig == syscall.SIGHUP {
+				continue
+			}
+			s.Logger.Warn("Forcing exit", zap.Stringer("signal", sig))
+			s.Logger.Sync()
+			s.exit(1)
+			return ErrForcedShutdown
+		}
+	}
+}
 
-	select {
-	case sig := <-sigChan:
-		s.Logger.Printf("Received signal: %v", sig)
-		return s.Stop()
-	case err := <-serverErr:
-		return err
+// Reload перечитывает конфигурацию и применяет изменения, не разрывая соединений.
+func (s *Server) Reload() error {
+	if s.Certificates != nil {
+		if err := s.Certificates.Reload(); err != nil {
+			s.Logger.Warn("TLS certificate reload failed", zap.Error(err))
+		}
 	}
+	result, err := s.Runtime.Reload()
+	logReload(s.Logger, result, err)
+	return err
 }
 
+// Stop снимает сервер с балансировки и останавливает компоненты за ShutdownTimeout.
 func (s *Server) Stop() error {
-	s.Logger.Println("Starting graceful shutdown...")
+	s.Logger.Info("Starting graceful shutdown", zap.Duration("timeout", s.Config.ShutdownTimeout))
+	// Сначала перестаём быть готовыми, чтобы балансировщик снял с нас трафик
+	s.Runtime.StartDraining()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), s.Config.ShutdownTimeout)
 	defer cancel()
-
-	if err := s.HTTPServer.Shutdown(ctx); err != nil {
-		s.Logger.Printf("Graceful shutdown failed: %v", err)
-		return err
+	err := s.Lifecycle.Stop(ctx)
+	if err != nil {
+		s.Logger.Error("Graceful shutdown failed", zap.Error(err))
+	} else {
+		s.Logger.Info("Server stopped gracefully")
 	}
-	s.Logger.Println("Server stopped gracefully")
-	return nil
+	s.Logger.Sync()
+	return err
 }
 
-func NewServer(logger *log.Logger) (*Server, error) {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		logger.Fatalf("Failed to load configuration: %v", err)
-		cfg = &config.Config{}
+func NewServer(cfg *config.Config, logger *zap.Logger) (*Server, error) {
+	if err := auth.Initialize(auth.Options{
+		Name:     cfg.CookieName,
+		MaxAge:   cfg.CookieMaxAge,
+		Secure:   cfg.CookieSecure,
+		HashKey:  cfg.CookieHashKey,
+		BlockKey: cfg.CookieBlockKey,
+	}); err != nil {
+		return nil, err
 	}
-	storages := Storages{Memory: store.NewInMemoryStorage()}
-	if cfg.FileName != "" {
-		storages.FileStorage = store.NewFileStorage(cfg.FileName)
+	storages := OpenStorages(cfg, logger)
+	newURLCache(cfg, storages, logger)
+	rt, err := NewRuntime(cfg, storages, logger)
+	if err != nil {
+		return nil, err
 	}
-	if cfg.DBConnection != "" {
-		db, err := store.CreateDBConnection(cfg.DBConnection)
-		if err != nil {
-			logger.Printf("WARNING: DB storage disabled: %v", err)
-		} else {
-			if err := db.Ping(); err != nil {
-				logger.Printf("WARNING: DB connection failed: %v", err)
-			} else {
-				storages.Postgres = store.NewPostgresStorage(db)
-			}
-		}
+	mux := NewRouter(rt, storages, logger)
+	certificates, err := newCertificates(cfg)
+	if err != nil {
+		return nil, err
 	}
-	mux := NewRouter(cfg, &storages, logger)
 
-	return &Server{
+	errorLog, err := zap.NewStdLogAt(logger.Named("http"), zap.ErrorLevel)
+	if err != nil {
+		return nil, err
+	}
+	server := &Server{
 		Logger: logger,
 		HTTPServer: &http.Server{
 			Addr:         cfg.ServerAddress,
 			Handler:      mux,
-			ErrorLog:     logger,
-			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 10 * time.Second,
-			IdleTimeout:  15 * time.Second,
+			ErrorLog:     errorLog,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
 		},
-		Config:   cfg,
-		Storages:  &storages,
-	}, nil
+		Certificates: certificates,
+		Config:       cfg,
+		Storages:     storages,
+		Runtime:      rt,
+		Lifecycle:    lifecycle.New(logger.Named("lifecycle")),
+		serveErr:     make(chan error, 3),
+		exit:         os.Exit,
+	}
+	if certificates != nil {
+		server.HTTPServer.TLSConfig = certs.TLSConfig(certificates)
+		if cfg.TLSSelfSigned {
+			logger.Warn("Serving HTTPS with a self-signed certificate")
+		}
+	}
+	if cfg.GRPCAddress != "" {
+		handler := NewURLHandler(cfg, storages, logger)
+		handler.runtime = rt
+		server.GRPCServer = NewGRPCServer(handler, certificates)
+	}
+	if cfg.TLSRedirectAddr != "" {
+		server.RedirectServer = &http.Server{
+			Addr:         cfg.TLSRedirectAddr,
+			Handler:      redirectToHTTPS(cfg.ServerAddress),
+			ErrorLog:     errorLog,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
+		}
+	}
+	server.registerHooks()
+	return server, nil
+}
+
+func newURLPolicy(cfg *config.Config) (*policy.Policy, error) {
+	return policy.New(policy.Options{
+		Schemes:       strings.Split(cfg.URLSchemes, ","),
+		MaxLength:     cfg.URLMaxLength,
+		BlocklistFile: cfg.URLBlocklistFile,
+		AllowlistFile: cfg.URLAllowlistFile,
+		AllowPrivate:  cfg.URLAllowPrivate,
+		ResolveHosts:  cfg.URLResolveHosts,
+		SelfURLs:      []string{cfg.BaseURL, cfg.ServerAddress},
+	})
 }
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/lifecycle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"go.uber.org/zap"
+)
+
+func testServerConfig(t *testing.T) *config.Config {
+	cfg := config.Default()
+	cfg.ServerAddress = "localhost:0"
+	cfg.BaseURL = "http://test.example"
+	cfg.FileName = filepath.Join(t.TempDir(), "urls.json")
+	cfg.AccessLog = filepath.Join(t.TempDir(), "access.log")
+	cfg.ShutdownTimeout = 2 * time.Second
+	return &cfg
+}
+
+// startServer запускает сервер и ждёт, пока запустятся все его компоненты.
+func startServer(t *testing.T, server *Server) <-chan error {
+	started := make(chan struct{})
+	server.Lifecycle.Append(lifecycle.Hook{
+		Name:    "test",
+		OnStart: func(context.Context) error { close(started); return nil },
+	})
+	result := make(chan error, 1)
+	go func() { result <- server.Start() }()
+	select {
+	case <-started:
+	case err := <-result:
+		t.Fatalf("server did not start: %v", err)
+	}
+	return result
+}
+
+func TestServerLifecycle(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	t.Run("Stop releases all goroutines", func(t *testing.T) {
+		cfg := testServerConfig(t)
+		cfg.GRPCAddress = "localhost:0"
+		server, err := NewServer(cfg, zap.NewNop())
+		require.NoError(t, err)
+		result := startServer(t, server)
+
+		client := &http.Client{Transport: &http.Transport{}}
+		resp, err := client.Get("http://" + server.HTTPServer.Addr + "/healthz")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		client.CloseIdleConnections()
+
+		require.NoError(t, server.Stop())
+		require.NoError(t, <-result)
+		assert.True(t, server.Runtime.Draining())
+	})
+
+	t.Run("Second signal forces exit", func(t *testing.T) {
+		server, err := NewServer(testServerConfig(t), zap.NewNop())
+		require.NoError(t, err)
+		stopping := make(chan struct{})
+		release := make(chan struct{})
+		// Компонент, который не успевает остановиться сам
+		server.Lifecycle.Append(lifecycle.Hook{
+			Name: "stuck",
+			OnStop: func(context.Context) error {
+				close(stopping)
+				<-release
+				return nil
+			},
+		})
+		exitCode := make(chan int, 1)
+		server.exit = func(code int) { exitCode <- code }
+		result := startServer(t, server)
+
+		require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+		<-stopping
+		require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+		assert.ErrorIs(t, <-result, ErrForcedShutdown)
+		assert.Equal(t, 1, <-exitCode)
+
+		close(release)
+		<-server.Lifecycle.Done()
+	})
+
+	t.Run("Listen error is returned from Start", func(t *testing.T) {
+		first, err := NewServer(testServerConfig(t), zap.NewNop())
+		require.NoError(t, err)
+		result := startServer(t, first)
+
+		cfg := testServerConfig(t)
+		cfg.ServerAddress = first.HTTPServer.Addr
+		second, err := NewServer(cfg, zap.NewNop())
+		require.NoError(t, err)
+		assert.ErrorContains(t, second.Start(), "start http")
+
+		require.NoError(t, first.Stop())
+		require.NoError(t, <-result)
+	})
+}
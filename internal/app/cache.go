@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/resp"
+	"github.com/dron1337/shortener/internal/store"
+	"go.uber.org/zap"
+)
+
+// newURLCache оборачивает кэшем основное хранилище: базу данных, а без неё файл.
+// Хранилищу в памяти кэш не нужен.
+func newURLCache(cfg *config.Config, storages *Storages, logger *zap.Logger) {
+	var backend store.Storage
+	switch {
+	case storages.Postgres != nil:
+		backend = storages.Postgres
+	case storages.SQLite != nil:
+		backend = storages.SQLite
+	case storages.Bolt != nil:
+		backend = storages.Bolt
+	case storages.Sharded != nil:
+		backend = storages.Sharded
+	case storages.FileStorage != nil:
+		backend = storages.FileStorage
+	default:
+		return
+	}
+	onError := func(err error) {
+		logger.Warn("URL cache error", zap.Error(err))
+	}
+	var cache store.Cache
+	if cfg.CacheSize > 0 {
+		storages.LocalCache = store.NewShardedLRU(cfg.CacheSize, cfg.CacheShards)
+		cache = storages.LocalCache
+	}
+	if cfg.CacheAddress != "" {
+		// Адрес уже проверен в config.LoadConfig
+		opts, _ := resp.ParseURL(cfg.CacheAddress)
+		storages.CacheClient = resp.NewClient(opts)
+		if cache != nil {
+			cache = store.NewTieredCache(storages.LocalCache, storages.CacheClient, cfg.CacheLocalTTL, onError)
+		} else {
+			cache = storages.CacheClient
+		}
+	}
+	if cache == nil {
+		return
+	}
+	storages.Cache = store.NewCacheStore(backend, cache, store.CacheOptions{
+		TTL:         cfg.CacheTTL,
+		NegativeTTL: cfg.CacheNegativeTTL,
+		OnError:     onError,
+	})
+}
+
+// urlStorage возвращает backend, а если кэш обёрнут вокруг него, — кэш.
+func (h *URLHandler) urlStorage(backend store.Storage) store.Storage {
+	if c := h.storages.Cache; c != nil && c.Backend() == backend {
+		return c
+	}
+	return backend
+}
+
+// invalidate сбрасывает ключи в кэше после изменений в обход CacheStore.
+func (h *URLHandler) invalidate(ctx context.Context, keys ...string) {
+	if h.storages.Cache == nil {
+		return
+	}
+	if err := h.storages.Cache.Invalidate(ctx, keys...); err != nil {
+		h.log(ctx).Warn("URL cache invalidation failed", zap.Error(err))
+	}
+}
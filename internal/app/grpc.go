@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/dron1337/shortener/internal/auth"
+	"github.com/dron1337/shortener/internal/certs"
+	"github.com/dron1337/shortener/internal/errors"
+	"github.com/dron1337/shortener/internal/logger"
+	pb "github.com/dron1337/shortener/internal/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCService реализует gRPC API поверх тех же хранилищ и логики, что и HTTP-обработчики.
+type GRPCService struct {
+	pb.UnimplementedShortenerServiceServer
+	h *URLHandler
+}
+
+// NewGRPCServer создаёт gRPC-сервер; при включённом TLS использует те же сертификаты, что и HTTPS.
+func NewGRPCServer(h *URLHandler, certificates *certs.Reloader) *grpc.Server {
+	opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(tracingInterceptor(h.runtime.Tracer), requestIDInterceptor(h.logger), auth.UnaryServerInterceptor, trackWritesInterceptor)}
+	if certificates != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(certs.TLSConfig(certificates))))
+	}
+	server := grpc.NewServer(opts...)
+	pb.RegisterShortenerServiceServer(server, &GRPCService{h: h})
+	return server
+}
+
+// requestIDInterceptor — аналог logger.Middleware для gRPC: идентификатор запроса
+// берётся из метаданных x-request-id и возвращается в заголовке ответа.
+func requestIDInterceptor(base *zap.Logger) grpc.UnaryServerInterceptor {
+	key := strings.ToLower(logger.RequestIDHeader)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var incoming string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(key); len(values) > 0 {
+				incoming = values[0]
+			}
+		}
+		requestID := logger.NewRequestID(incoming)
+		grpc.SetHeader(ctx, metadata.Pairs(key, requestID))
+		ctx = logger.WithRequestID(ctx, requestID)
+		l := logger.ForRequest(ctx, base)
+		resp, err := handler(logger.WithLogger(ctx, l), req)
+		l.Info("got incoming gRPC request", zap.String("method", info.FullMethod), zap.Stringer("code", status.Code(err)))
+		return resp, err
+	}
+}
+
+// validateURL проверяет URL так же, как HTTP-обработчики, и возвращает ошибку gRPC.
+func (s *GRPCService) validateURL(ctx context.Context, originalURL string) error {
+	if _, err := url.ParseRequestURI(originalURL); err != nil {
+		return status.Errorf(codes.InvalidArgument, "malformed URL %q", originalURL)
+	}
+	if reason := s.h.policyViolation(ctx, originalURL); reason != "" {
+		return status.Error(codes.InvalidArgument, reason)
+	}
+	return nil
+}
+
+func (s *GRPCService) Shorten(ctx context.Context, req *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	userID := ctx.Value(auth.UserIDKey).(string)
+	if err := s.validateURL(ctx, req.GetUrl()); err != nil {
+		return nil, err
+	}
+	shortURL, created, err := s.h.shorten(ctx, userID, req.GetUrl())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to save URL")
+	}
+	return &pb.ShortenResponse{
+		ShortUrl: fmt.Sprintf("%s/%s", s.h.conf().BaseURL, shortURL),
+		Created:  created,
+	}, nil
+}
+
+func (s *GRPCService) ShortenBatch(ctx context.Context, req *pb.ShortenBatchRequest) (*pb.ShortenBatchResponse, error) {
+	userID := ctx.Value(auth.UserIDKey).(string)
+	if len(req.GetItems()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty batch")
+	}
+	for _, item := range req.GetItems() {
+		if err := s.validateURL(ctx, item.GetOriginalUrl()); err != nil {
+			return nil, status.Errorf(status.Code(err), "%s: %s", item.GetCorrelationId(), status.Convert(err).Message())
+		}
+	}
+	resp := &pb.ShortenBatchResponse{}
+	for _, item := range req.GetItems() {
+		shortURL, _, err := s.h.shorten(ctx, userID, item.GetOriginalUrl())
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to save URL")
+		}
+		resp.Items = append(resp.Items, &pb.BatchResult{
+			CorrelationId: item.GetCorrelationId(),
+			ShortUrl:      fmt.Sprintf("%s/%s", s.h.conf().BaseURL, shortURL),
+		})
+	}
+	return resp, nil
+}
+
+func (s *GRPCService) Resolve(ctx context.Context, req *pb.ResolveRequest) (*pb.ResolveResponse, error) {
+	if req.GetShortKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty key")
+	}
+	originalURL, err := s.h.resolve(ctx, req.GetShortKey())
+	if err == errors.ErrURLDeleted {
+		return nil, status.Error(codes.NotFound, "URL deleted")
+	}
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "URL not found")
+	}
+	return &pb.ResolveResponse{OriginalUrl: originalURL}, nil
+}
+
+func (s *GRPCService) ListUserURLs(ctx context.Context, _ *pb.ListUserURLsRequest) (*pb.ListUserURLsResponse, error) {
+	userID := ctx.Value(auth.UserIDKey).(string)
+	urls, err := s.h.userURLs(ctx, userID)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, "failed to list URLs")
+	}
+	resp := &pb.ListUserURLsResponse{}
+	for _, u := range urls {
+		resp.Urls = append(resp.Urls, &pb.UserURL{ShortUrl: u.ShortURL, OriginalUrl: u.OriginalURL})
+	}
+	return resp, nil
+}
+
+func (s *GRPCService) DeleteUserURLs(ctx context.Context, req *pb.DeleteUserURLsRequest) (*pb.DeleteUserURLsResponse, error) {
+	userID := ctx.Value(auth.UserIDKey).(string)
+	if len(req.GetShortKeys()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no keys to delete")
+	}
+	if err := s.h.deleteUserURLs(ctx, userID, req.GetShortKeys()); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &pb.DeleteUserURLsResponse{}, nil
+}
+
+func (s *GRPCService) Ping(ctx context.Context, _ *pb.PingRequest) (*pb.PingResponse, error) {
+	if err := s.h.checkDatabase(ctx); stderrors.Is(err, errNoDatabase) {
+		return nil, status.Error(codes.Unavailable, errNoDatabase.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Unavailable, "database is unreachable")
+	}
+	return &pb.PingResponse{}, nil
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/dron1337/shortener/internal/auth"
+	"github.com/dron1337/shortener/internal/config"
+	pb "github.com/dron1337/shortener/internal/proto"
+	"github.com/dron1337/shortener/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPCService(t *testing.T) {
+	cfg := config.Default()
+	cfg.BaseURL = "http://test.example"
+	storages := Storages{Memory: store.NewInMemoryStorage()}
+	server := NewGRPCServer(NewURLHandler(&cfg, &storages, zap.NewNop()), nil)
+	ln := bufconn.Listen(1 << 20)
+	go server.Serve(ln)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewShortenerServiceClient(conn)
+	ctx := context.Background()
+
+	var header metadata.MD
+	resp, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com/grpc"}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.True(t, resp.GetCreated())
+	tokens := header.Get(auth.MetadataKey)
+	require.Len(t, tokens, 1)
+	userCtx := metadata.AppendToOutgoingContext(ctx, auth.MetadataKey, tokens[0])
+
+	t.Run("Duplicate is not created again", func(t *testing.T) {
+		again, err := client.Shorten(userCtx, &pb.ShortenRequest{Url: "https://example.com/grpc"})
+		require.NoError(t, err)
+		assert.False(t, again.GetCreated())
+		assert.Equal(t, resp.GetShortUrl(), again.GetShortUrl())
+	})
+
+	t.Run("Resolve", func(t *testing.T) {
+		key := resp.GetShortUrl()[len(cfg.BaseURL)+1:]
+		got, err := client.Resolve(ctx, &pb.ResolveRequest{ShortKey: key})
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/grpc", got.GetOriginalUrl())
+
+		_, err = client.Resolve(ctx, &pb.ResolveRequest{ShortKey: "missing"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Batch and list by token", func(t *testing.T) {
+		batch, err := client.ShortenBatch(userCtx, &pb.ShortenBatchRequest{Items: []*pb.BatchItem{
+			{CorrelationId: "1", OriginalUrl: "https://example.com/one"},
+			{CorrelationId: "2", OriginalUrl: "https://example.com/two"},
+		}})
+		require.NoError(t, err)
+		require.Len(t, batch.GetItems(), 2)
+		assert.Equal(t, "2", batch.GetItems()[1].GetCorrelationId())
+
+		list, err := client.ListUserURLs(userCtx, &pb.ListUserURLsRequest{})
+		require.NoError(t, err)
+		assert.Len(t, list.GetUrls(), 3)
+	})
+
+	t.Run("Invalid URL", func(t *testing.T) {
+		_, err := client.Shorten(userCtx, &pb.ShortenRequest{Url: "not a url"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Database-only methods without database", func(t *testing.T) {
+		_, err := client.Ping(ctx, &pb.PingRequest{})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		_, err = client.DeleteUserURLs(userCtx, &pb.DeleteUserURLsRequest{ShortKeys: []string{"abc"}})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
@@ -1,26 +1,32 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/dron1337/shortener/internal/auth"
+	"github.com/dron1337/shortener/internal/canonical"
 	"github.com/dron1337/shortener/internal/config"
 	"github.com/dron1337/shortener/internal/errors"
+	"github.com/dron1337/shortener/internal/logger"
 	"github.com/dron1337/shortener/internal/service"
 	"github.com/dron1337/shortener/internal/store"
 	"github.com/gorilla/mux"
+	"go.uber.org/zap"
 )
 
 type URLHandler struct {
 	storages *Storages
-	logger   *log.Logger
-	config   *config.Config
+	logger   *zap.Logger
+	runtime  *Runtime
 }
 type RequestData struct {
 	URL string `json:"url"`
@@ -33,6 +39,11 @@ type BatchRequestItem struct {
 	OriginalURL   string `json:"original_url"`
 }
 
+type ErrorResponse struct {
+	CorrelationID string `json:"correlation_id,omitempty"`
+	Error         string `json:"error"`
+}
+
 type BatchRequest []BatchRequestItem
 type BatchResponse []BatchResponseItem
 type BatchResponseItem struct {
@@ -40,12 +51,219 @@ type BatchResponseItem struct {
 	ShortURL      string `json:"short_url"`
 }
 
-func NewURLHandler(cfg *config.Config, storages *Storages, logger *log.Logger) *URLHandler {
-	return &URLHandler{config: cfg, storages: storages, logger: logger}
+func NewURLHandler(cfg *config.Config, storages *Storages, logger *zap.Logger) *URLHandler {
+	return &URLHandler{runtime: newStaticRuntime(cfg), storages: storages, logger: logger}
 }
-func (h *URLHandler) GenerateURL(w http.ResponseWriter, r *http.Request) {
-	h.logger.Printf("Incoming request: %s %s, Headers: %v", r.Method, r.URL, r.Header)
+
+// log возвращает логер запроса с его идентификаторами.
+func (h *URLHandler) log(ctx context.Context) *zap.Logger {
+	return logger.FromContext(ctx, h.logger)
+}
+
+func (h *URLHandler) metrics() *Metrics {
+	return h.runtime.Metrics
+}
+
+// conf возвращает актуальную конфигурацию; она может смениться при перезагрузке.
+func (h *URLHandler) conf() *config.Config {
+	return h.runtime.Config()
+}
+
+// policyViolation возвращает причину отказа, если URL запрещён политикой.
+func (h *URLHandler) policyViolation(ctx context.Context, originalURL string) string {
+	if h.runtime.Policy == nil {
+		return ""
+	}
+	if err := h.runtime.Policy.Check(ctx, originalURL); err != nil {
+		h.log(ctx).Info("URL rejected by policy", zap.String("url", originalURL), zap.Error(err))
+		return err.Error()
+	}
+	return ""
+}
+
+func (h *URLHandler) writeJSONError(w http.ResponseWriter, status int, resp ErrorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// canonicalURL возвращает канонический вид URL для поиска дубликатов.
+func (h *URLHandler) canonicalURL(originalURL string) string {
+	canonicalURL, err := canonical.Canonicalize(originalURL, canonical.Options{
+		SortQuery:     h.conf().URLSortQuery,
+		StripTracking: h.conf().URLStripTracking,
+	})
+	if err != nil {
+		return originalURL
+	}
+	return canonicalURL
+}
+
+// findShortKey ищет уже сокращённый URL по каноническому виду во всех хранилищах.
+func (h *URLHandler) findShortKey(ctx context.Context, canonicalURL string) string {
 	shortURL := ""
+	if h.storages.Postgres != nil {
+		sctx, done := h.startStorage(ctx, "postgres", "get_short_key")
+		shortURL = h.storages.Postgres.GetShortKey(sctx, canonicalURL)
+		done(nil)
+	}
+	if shortURL == "" && h.storages.SQLite != nil {
+		sctx, done := h.startStorage(ctx, "sqlite", "get_short_key")
+		shortURL = h.storages.SQLite.GetShortKey(sctx, canonicalURL)
+		done(nil)
+	}
+	if shortURL == "" && h.storages.Bolt != nil {
+		sctx, done := h.startStorage(ctx, "bolt", "get_short_key")
+		shortURL = h.storages.Bolt.GetShortKey(sctx, canonicalURL)
+		done(nil)
+	}
+	if shortURL == "" && h.storages.Sharded != nil {
+		sctx, done := h.startStorage(ctx, "shards", "get_short_key")
+		shortURL = h.storages.Sharded.GetShortKey(sctx, canonicalURL)
+		done(nil)
+	}
+	if shortURL == "" && h.storages.FileStorage != nil {
+		sctx, done := h.startStorage(ctx, "file", "get_short_key")
+		shortURL = h.storages.FileStorage.GetShortKey(sctx, canonicalURL)
+		done(nil)
+	}
+	if shortURL == "" && h.storages.Memory != nil {
+		sctx, done := h.startStorage(ctx, "memory", "get_short_key")
+		shortURL = h.storages.Memory.GetShortKey(sctx, canonicalURL)
+		done(nil)
+	}
+	return shortURL
+}
+
+func (h *URLHandler) saveURL(ctx context.Context, userID, originalURL, canonicalURL, shortURL string) error {
+	var saveErrors []error
+	if h.storages.Postgres != nil {
+		sctx, done := h.startStorage(ctx, "postgres", "save")
+		err := h.urlStorage(h.storages.Postgres).Save(sctx, userID, originalURL, canonicalURL, shortURL)
+		done(err)
+		if err != nil {
+			saveErrors = append(saveErrors, fmt.Errorf("postgres save failed: %w", err))
+			h.log(ctx).Error("Storage save failed", zap.String("backend", "postgres"), zap.Error(err))
+		}
+	}
+	if h.storages.SQLite != nil {
+		sctx, done := h.startStorage(ctx, "sqlite", "save")
+		err := h.urlStorage(h.storages.SQLite).Save(sctx, userID, originalURL, canonicalURL, shortURL)
+		done(err)
+		if err != nil {
+			saveErrors = append(saveErrors, fmt.Errorf("sqlite save failed: %w", err))
+			h.log(ctx).Error("Storage save failed", zap.String("backend", "sqlite"), zap.Error(err))
+		}
+	}
+	if h.storages.Bolt != nil {
+		sctx, done := h.startStorage(ctx, "bolt", "save")
+		err := h.urlStorage(h.storages.Bolt).Save(sctx, userID, originalURL, canonicalURL, shortURL)
+		done(err)
+		if err != nil {
+			saveErrors = append(saveErrors, fmt.Errorf("bolt save failed: %w", err))
+			h.log(ctx).Error("Storage save failed", zap.String("backend", "bolt"), zap.Error(err))
+		}
+	}
+	if h.storages.Sharded != nil {
+		sctx, done := h.startStorage(ctx, "shards", "save")
+		err := h.urlStorage(h.storages.Sharded).Save(sctx, userID, originalURL, canonicalURL, shortURL)
+		done(err)
+		if err != nil {
+			saveErrors = append(saveErrors, fmt.Errorf("shards save failed: %w", err))
+			h.log(ctx).Error("Storage save failed", zap.String("backend", "shards"), zap.Error(err))
+		}
+	}
+	if h.storages.FileStorage != nil {
+		sctx, done := h.startStorage(ctx, "file", "save")
+		err := h.urlStorage(h.storages.FileStorage).Save(sctx, userID, originalURL, canonicalURL, shortURL)
+		done(err)
+		if err != nil {
+			saveErrors = append(saveErrors, fmt.Errorf("file save failed: %w", err))
+			h.log(ctx).Error("Storage save failed", zap.String("backend", "file"), zap.Error(err))
+		}
+	}
+	if h.storages.Memory != nil {
+		sctx, done := h.startStorage(ctx, "memory", "save")
+		err := h.storages.Memory.Save(sctx, userID, originalURL, canonicalURL, shortURL)
+		done(err)
+		if err != nil {
+			saveErrors = append(saveErrors, fmt.Errorf("memory save failed: %w", err))
+			h.log(ctx).Error("Storage save failed", zap.String("backend", "memory"), zap.Error(err))
+		}
+	}
+	return stderrors.Join(saveErrors...)
+}
+
+// shorten возвращает ключ для URL, создавая новый, если такой адрес ещё не сокращали.
+func (h *URLHandler) shorten(ctx context.Context, userID, originalURL string) (string, bool, error) {
+	canonicalURL := h.canonicalURL(originalURL)
+	if shortURL := h.findShortKey(ctx, canonicalURL); shortURL != "" {
+		return shortURL, false, nil
+	}
+	shortURL, err := h.newShortKey(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	if err := h.saveURL(ctx, userID, originalURL, canonicalURL, shortURL); err != nil {
+		return "", false, err
+	}
+	return shortURL, true, nil
+}
+
+// maxKeyAttempts ограничивает число попыток сгенерировать свободный ключ.
+const maxKeyAttempts = 5
+
+// newShortKey генерирует ключ, который ещё не занят ни в одном хранилище.
+func (h *URLHandler) newShortKey(ctx context.Context) (string, error) {
+	for range maxKeyAttempts {
+		key := service.GenerateShortKey()
+		if !h.keyExists(ctx, key) {
+			return key, nil
+		}
+		h.metrics().keyCollisions.Inc()
+		h.log(ctx).Warn("Short key collision", zap.String("key", key))
+	}
+	return "", stderrors.New("failed to generate a unique short key")
+}
+
+// keyExists сообщает, занят ли ключ, в том числе удалённой ссылкой.
+func (h *URLHandler) keyExists(ctx context.Context, key string) bool {
+	taken := func(err error) bool { return err == nil || err == errors.ErrURLDeleted }
+	if h.storages.Postgres != nil {
+		if _, err := h.storages.Postgres.GetOriginalURL(ctx, key); taken(err) {
+			return true
+		}
+	}
+	if h.storages.SQLite != nil {
+		if _, err := h.storages.SQLite.GetOriginalURL(ctx, key); taken(err) {
+			return true
+		}
+	}
+	if h.storages.Bolt != nil {
+		if _, err := h.storages.Bolt.GetOriginalURL(ctx, key); taken(err) {
+			return true
+		}
+	}
+	if h.storages.Sharded != nil {
+		if _, err := h.storages.Sharded.GetOriginalURL(ctx, key); taken(err) {
+			return true
+		}
+	}
+	if h.storages.FileStorage != nil {
+		if _, err := h.storages.FileStorage.GetOriginalURL(ctx, key); err == nil {
+			return true
+		}
+	}
+	if h.storages.Memory != nil {
+		if _, err := h.storages.Memory.GetOriginalURL(ctx, key); taken(err) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *URLHandler) GenerateURL(w http.ResponseWriter, r *http.Request) {
+	h.log(r.Context()).Debug("Incoming request", zap.String("method", r.Method), zap.String("url", r.URL.String()), logger.Headers(r.Header))
 	userID := r.Context().Value(auth.UserIDKey).(string)
 	w.Header().Set("Content-Type", "text/plain")
 	body, err := io.ReadAll(r.Body)
@@ -55,7 +273,7 @@ func (h *URLHandler) GenerateURL(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 	originalURL := strings.TrimSpace(string(body))
-	h.logger.Printf("Original URL: %s", originalURL)
+	h.log(r.Context()).Debug("Original URL", zap.String("url", originalURL))
 	if originalURL == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		return
@@ -64,68 +282,143 @@ func (h *URLHandler) GenerateURL(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	if h.storages.Postgres != nil {
-		shortURL = h.storages.Postgres.GetShortKey(r.Context(), originalURL)
-	}
-
-	if shortURL == "" && h.storages.FileStorage != nil {
-		shortURL = h.storages.FileStorage.GetShortKey(r.Context(), originalURL)
+	if reason := h.policyViolation(r.Context(), originalURL); reason != "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(reason))
+		return
 	}
-
-	if shortURL == "" && h.storages.Memory != nil {
-		shortURL = h.storages.Memory.GetShortKey(r.Context(), originalURL)
+	shortURL, created, err := h.shorten(r.Context(), userID, originalURL)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("Failed to save URL to one or more storage backends"))
+		return
 	}
 	status := http.StatusConflict
-	if shortURL == "" {
-		shortURL = service.GenerateShortKey()
-		var saveErrors []error
-		if h.storages.Postgres != nil {
-			if err := h.storages.Postgres.Save(r.Context(), userID, originalURL, shortURL); err != nil {
-				saveErrors = append(saveErrors, fmt.Errorf("postgres save failed: %w", err))
-				h.logger.Printf("Postgres save error: %v", err)
-			}
-		}
-		if h.storages.FileStorage != nil {
-			if err := h.storages.FileStorage.Save(r.Context(), userID, originalURL, shortURL); err != nil {
-				saveErrors = append(saveErrors, fmt.Errorf("file save failed: %w", err))
-				h.logger.Printf("File storage save error: %v", err)
-			}
-		}
-		if h.storages.Memory != nil {
-			if err := h.storages.Memory.Save(r.Context(), userID, originalURL, shortURL); err != nil {
-				saveErrors = append(saveErrors, fmt.Errorf("memory save failed: %w", err))
-				h.logger.Printf("Memory storage save error: %v", err)
-			}
-		}
+	if created {
 		status = http.StatusCreated
-		if len(saveErrors) > 0 {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("Failed to save URL to one or more storage backends"))
-			return
-		}
 	}
-	fullShortURL := fmt.Sprintf("%s/%s", h.config.BaseURL, shortURL)
-	h.logger.Printf("Short URL: %s", fullShortURL)
+	fullShortURL := fmt.Sprintf("%s/%s", h.conf().BaseURL, shortURL)
+	h.log(r.Context()).Debug("Short URL", zap.String("short_url", fullShortURL))
 	w.WriteHeader(status)
 	w.Write([]byte(fullShortURL))
 }
+
+// userURLs возвращает ссылки пользователя из базы (Postgres читает с реплики, если она есть), а без неё — из памяти.
+func (h *URLHandler) userURLs(ctx context.Context, userID string) ([]store.ResponseURLs, error) {
+	var backend string
+	var listURLs func(context.Context, string, string) ([]store.ResponseURLs, error)
+	switch {
+	case h.storages.Postgres != nil:
+		backend, listURLs = "postgres", h.storages.Postgres.GetURLsByUser
+	case h.storages.SQLite != nil:
+		backend, listURLs = "sqlite", h.storages.SQLite.GetURLsByUser
+	case h.storages.Bolt != nil:
+		backend, listURLs = "bolt", h.storages.Bolt.GetURLsByUser
+	case h.storages.Sharded != nil:
+		backend, listURLs = "shards", h.storages.Sharded.GetURLsByUser
+	case h.storages.Memory != nil:
+		sctx, done := h.startStorage(ctx, "memory", "get_user_urls")
+		urls := h.storages.Memory.GetURLsByUser(sctx, userID, h.conf().BaseURL)
+		done(nil)
+		return urls, nil
+	default:
+		return nil, nil
+	}
+	sctx, done := h.startStorage(ctx, backend, "get_user_urls")
+	urls, err := listURLs(sctx, userID, h.conf().BaseURL)
+	done(err)
+	if err != nil {
+		h.log(ctx).Error("Failed to list user URLs", zap.String("user_id", userID), zap.Error(err))
+	}
+	return urls, err
+}
+
 func (h *URLHandler) GetUserURLs(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(auth.UserIDKey).(string)
-	h.logger.Printf("User ID из куки: %s", userID)
+	h.log(r.Context()).Debug("Listing user URLs", zap.String("user_id", userID))
 	w.Header().Set("Content-Type", "application/json")
-	var urls []store.ResponseURLs
-	if h.storages.Memory != nil {
-		urls = h.storages.Memory.GetURLsByUser(r.Context(), userID, h.config.BaseURL)
+	query, err := parseURLListQuery(r.URL.Query())
+	if err != nil {
+		h.writeJSONError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
 	}
+	urls, err := h.userURLs(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	urls = query.apply(urls)
 	if len(urls) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 	json.NewEncoder(w).Encode(urls)
 }
+
+// urlListQuery — сортировка и фильтры списка ссылок пользователя:
+// ?sort=created_at|-created_at|updated_at|-updated_at, ?created_after=, ?created_before= в RFC 3339.
+type urlListQuery struct {
+	sortBy        string
+	desc          bool
+	createdAfter  time.Time
+	createdBefore time.Time
+}
+
+func parseURLListQuery(values url.Values) (urlListQuery, error) {
+	var q urlListQuery
+	if sortBy := values.Get("sort"); sortBy != "" {
+		q.sortBy, q.desc = strings.TrimPrefix(sortBy, "-"), strings.HasPrefix(sortBy, "-")
+		if q.sortBy != "created_at" && q.sortBy != "updated_at" {
+			return q, fmt.Errorf("unknown sort %q: want created_at or updated_at, optionally prefixed with -", sortBy)
+		}
+	}
+	for name, dst := range map[string]*time.Time{"created_after": &q.createdAfter, "created_before": &q.createdBefore} {
+		value := values.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return q, fmt.Errorf("invalid %s %q: want RFC 3339 time", name, value)
+		}
+		*dst = t
+	}
+	return q, nil
+}
+
+// apply отбирает и упорядочивает ссылки. Ссылки с неизвестным временем создания
+// в фильтры по нему не попадают, а при сортировке считаются самыми старыми.
+func (q urlListQuery) apply(urls []store.ResponseURLs) []store.ResponseURLs {
+	if !q.createdAfter.IsZero() || !q.createdBefore.IsZero() {
+		filtered := urls[:0]
+		for _, u := range urls {
+			if u.CreatedAt.IsZero() ||
+				!q.createdAfter.IsZero() && !u.CreatedAt.After(q.createdAfter) ||
+				!q.createdBefore.IsZero() && !u.CreatedAt.Before(q.createdBefore) {
+				continue
+			}
+			filtered = append(filtered, u)
+		}
+		urls = filtered
+	}
+	if q.sortBy == "" {
+		return urls
+	}
+	key := func(u store.ResponseURLs) time.Time { return u.CreatedAt }
+	if q.sortBy == "updated_at" {
+		key = func(u store.ResponseURLs) time.Time { return u.UpdatedAt }
+	}
+	sort.SliceStable(urls, func(i, j int) bool {
+		if q.desc {
+			return key(urls[j]).Before(key(urls[i]))
+		}
+		return key(urls[i]).Before(key(urls[j]))
+	})
+	return urls
+}
+
 func (h *URLHandler) GenerateJSONURL(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(auth.UserIDKey).(string)
-	shortURL := ""
 	w.Header().Set("Content-Type", "application/json")
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -133,14 +426,14 @@ func (h *URLHandler) GenerateJSONURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer r.Body.Close()
-	h.logger.Printf("Raw body: %q", body)
+	h.log(r.Context()).Debug("Raw body", zap.ByteString("body", body))
 	var data RequestData
 	if err := json.Unmarshal(body, &data); err != nil {
-		h.logger.Println("error parse json:", err)
+		h.log(r.Context()).Info("Failed to parse JSON request", zap.Error(err))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	h.logger.Println("URL:", data.URL)
+	h.log(r.Context()).Debug("URL", zap.String("url", data.URL))
 	if data.URL == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		return
@@ -149,59 +442,33 @@ func (h *URLHandler) GenerateJSONURL(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	if h.storages.Postgres != nil {
-		shortURL = h.storages.Postgres.GetShortKey(r.Context(), data.URL)
-	}
-	if shortURL == "" && h.storages.FileStorage != nil {
-		shortURL = h.storages.FileStorage.GetShortKey(r.Context(), data.URL)
+	if reason := h.policyViolation(r.Context(), data.URL); reason != "" {
+		h.writeJSONError(w, http.StatusUnprocessableEntity, ErrorResponse{Error: reason})
+		return
 	}
-	if shortURL == "" && h.storages.Memory != nil {
-		shortURL = h.storages.Memory.GetShortKey(r.Context(), data.URL)
+	shortURL, created, err := h.shorten(r.Context(), userID, data.URL)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("Failed to save URL to one or more storage backends"))
+		return
 	}
 	status := http.StatusConflict
-	h.logger.Println("shortURL:", shortURL)
-	if shortURL == "" {
-		shortURL = service.GenerateShortKey()
-		var saveErrors []error
-		if h.storages.Postgres != nil {
-			if err := h.storages.Postgres.Save(r.Context(), userID, data.URL, shortURL); err != nil {
-				saveErrors = append(saveErrors, fmt.Errorf("postgres save failed: %w", err))
-				h.logger.Printf("Postgres save error: %v", err)
-			}
-		}
-		if h.storages.FileStorage != nil {
-			if err := h.storages.FileStorage.Save(r.Context(), userID, data.URL, shortURL); err != nil {
-				saveErrors = append(saveErrors, fmt.Errorf("file save failed: %w", err))
-				h.logger.Printf("File storage save error: %v", err)
-			}
-		}
-		if h.storages.Memory != nil {
-			if err := h.storages.Memory.Save(r.Context(), userID, data.URL, shortURL); err != nil {
-				saveErrors = append(saveErrors, fmt.Errorf("memory save failed: %w", err))
-				h.logger.Printf("Memory storage save error: %v", err)
-			}
-		}
+	if created {
 		status = http.StatusCreated
-		if len(saveErrors) > 0 {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("Failed to save URL to one or more storage backends"))
-			return
-		}
 	}
-	fullShortURL := fmt.Sprintf("%s/%s", h.config.BaseURL, shortURL)
+	h.log(r.Context()).Debug("Short key", zap.String("key", shortURL))
+	fullShortURL := fmt.Sprintf("%s/%s", h.conf().BaseURL, shortURL)
 	response := ResponseData{Result: fullShortURL}
 	jsonBytes, err := json.Marshal(response)
 	if err != nil {
-		h.logger.Println("error parse response json:", err)
+		h.log(r.Context()).Error("Failed to encode response", zap.Error(err))
 	}
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	h.logger.Printf("Sending response: %s", jsonBytes)
+	h.log(r.Context()).Debug("Sending response", zap.ByteString("body", jsonBytes))
 	w.Write(jsonBytes)
 }
 func (h *URLHandler) GetURL(w http.ResponseWriter, r *http.Request) {
-	h.logger.Printf("Request: %s %s", r.Method, r.URL.Path)
 	w.Header().Set("Content-Type", "text/plain")
 	vars := mux.Vars(r)
 	key := vars["key"]
@@ -209,51 +476,124 @@ func (h *URLHandler) GetURL(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	h.logger.Printf("Key: %s", key)
-	var saveErrors []error
-	var url string
-	var found bool
+	h.log(r.Context()).Debug("Key", zap.String("key", key))
+	url, err := h.resolve(r.Context(), key)
+	if err == errors.ErrURLDeleted {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	h.log(r.Context()).Debug("Resolved URL", zap.String("url", url))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Location", url)
+	w.WriteHeader(http.StatusTemporaryRedirect)
+}
+
+// resolve ищет исходный URL по ключу во всех хранилищах.
+// Возвращает errors.ErrURLDeleted, если ссылка удалена пользователем.
+func (h *URLHandler) resolve(ctx context.Context, key string) (string, error) {
+	var lookupErrors []error
 	if h.storages.Postgres != nil {
-		if u, err := h.storages.Postgres.GetOriginalURL(r.Context(), key); err != nil {
-			if err == errors.ErrURLDeleted {
-				h.logger.Printf("URL deleted in Postgres: %s", key)
-				w.WriteHeader(http.StatusGone)
-				return
-			}
-		} else {
-			url = u
-			found = true
+		sctx, done := h.startStorage(ctx, "postgres", "get_original_url")
+		u, err := h.urlStorage(h.storages.Postgres).GetOriginalURL(sctx, key)
+		done(err)
+		if err == nil {
+			return u, nil
+		}
+		if err == errors.ErrURLDeleted {
+			h.log(ctx).Debug("URL deleted in Postgres", zap.String("key", key))
+			return "", err
 		}
 	}
-	if !found && h.storages.FileStorage != nil {
-		if u, err := h.storages.FileStorage.GetOriginalURL(r.Context(), key); err != nil {
-			saveErrors = append(saveErrors, fmt.Errorf("file get failed: %w", err))
-			h.logger.Printf("File storage get error: %v", err)
-		} else {
-			url = u
-			found = true
+	if h.storages.SQLite != nil {
+		sctx, done := h.startStorage(ctx, "sqlite", "get_original_url")
+		u, err := h.urlStorage(h.storages.SQLite).GetOriginalURL(sctx, key)
+		done(err)
+		if err == nil {
+			return u, nil
+		}
+		if err == errors.ErrURLDeleted {
+			h.log(ctx).Debug("URL deleted in SQLite", zap.String("key", key))
+			return "", err
 		}
 	}
-	if !found && h.storages.Memory != nil {
-		if u, err := h.storages.Memory.GetOriginalURL(r.Context(), key); err != nil {
-			saveErrors = append(saveErrors, fmt.Errorf("memory get failed: %w", err))
-			h.logger.Printf("Memory storage get error: %v", err)
-		} else {
-			url = u
-			found = true
+	if h.storages.Bolt != nil {
+		sctx, done := h.startStorage(ctx, "bolt", "get_original_url")
+		u, err := h.urlStorage(h.storages.Bolt).GetOriginalURL(sctx, key)
+		done(err)
+		if err == nil {
+			return u, nil
+		}
+		if err == errors.ErrURLDeleted {
+			h.log(ctx).Debug("URL deleted in Bolt", zap.String("key", key))
+			return "", err
 		}
 	}
-	h.logger.Println("URL:", url)
-	if len(saveErrors) > 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		return
+	if h.storages.Sharded != nil {
+		sctx, done := h.startStorage(ctx, "shards", "get_original_url")
+		u, err := h.urlStorage(h.storages.Sharded).GetOriginalURL(sctx, key)
+		done(err)
+		if err == nil {
+			return u, nil
+		}
+		if err == errors.ErrURLDeleted {
+			h.log(ctx).Debug("URL deleted in shards", zap.String("key", key))
+			return "", err
+		}
 	}
-	w.Header().Set("Location", url)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	// Память опрашивается раньше файла: файл только дописывается и не знает
+	// об удалении ссылок из пространства.
+	if h.storages.Memory != nil {
+		sctx, done := h.startStorage(ctx, "memory", "get_original_url")
+		u, err := h.storages.Memory.GetOriginalURL(sctx, key)
+		done(err)
+		if err == nil {
+			return u, nil
+		}
+		if err == errors.ErrURLDeleted {
+			h.log(ctx).Debug("URL deleted in memory", zap.String("key", key))
+			return "", err
+		}
+		lookupErrors = append(lookupErrors, fmt.Errorf("memory get failed: %w", err))
+		h.log(ctx).Debug("Storage lookup failed", zap.String("backend", "memory"), zap.Error(err))
+	}
+	if h.storages.FileStorage != nil {
+		sctx, done := h.startStorage(ctx, "file", "get_original_url")
+		u, err := h.urlStorage(h.storages.FileStorage).GetOriginalURL(sctx, key)
+		done(err)
+		if err == nil {
+			return u, nil
+		}
+		lookupErrors = append(lookupErrors, fmt.Errorf("file get failed: %w", err))
+		h.log(ctx).Debug("Storage lookup failed", zap.String("backend", "file"), zap.Error(err))
+	}
+	if len(lookupErrors) == 0 {
+		return "", errors.ErrURLNotFound
+	}
+	return "", stderrors.Join(lookupErrors...)
 }
+
+// checkDatabase проверяет соединение с базой: Postgres, SQLite, bbolt или всеми шардами.
+func (h *URLHandler) checkDatabase(ctx context.Context) error {
+	switch {
+	case h.storages.Postgres != nil:
+		return h.storages.Postgres.CheckConnection(ctx)
+	case h.storages.SQLite != nil:
+		return h.storages.SQLite.CheckConnection(ctx)
+	case h.storages.Bolt != nil:
+		return h.storages.Bolt.CheckConnection(ctx)
+	case h.storages.Sharded != nil:
+		return h.storages.Sharded.CheckConnection(ctx)
+	}
+	return errNoDatabase
+}
+
+// CheckDBConnection проверяет соединение с базой; без настроенной БД отвечает ошибкой.
 func (h *URLHandler) CheckDBConnection(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
-	if err := h.storages.Postgres.CheckConnection(r.Context()); err != nil {
+	if err := h.checkDatabase(r.Context()); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -264,7 +604,7 @@ func (h *URLHandler) GenerateBatchJSONURL(w http.ResponseWriter, r *http.Request
 	w.Header().Set("Content-Type", "application/json")
 	var batch BatchRequest
 	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
-		h.logger.Println("Failed to decode batch request:", err)
+		h.log(r.Context()).Info("Failed to decode batch request", zap.Error(err))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -273,30 +613,36 @@ func (h *URLHandler) GenerateBatchJSONURL(w http.ResponseWriter, r *http.Request
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	for _, item := range batch {
+		if _, err := url.ParseRequestURI(item.OriginalURL); err != nil {
+			h.writeJSONError(w, http.StatusBadRequest, ErrorResponse{CorrelationID: item.CorrelationID, Error: "malformed URL"})
+			return
+		}
+		if reason := h.policyViolation(r.Context(), item.OriginalURL); reason != "" {
+			h.writeJSONError(w, http.StatusUnprocessableEntity, ErrorResponse{CorrelationID: item.CorrelationID, Error: reason})
+			return
+		}
+	}
 	var response BatchResponse
-	var shortURL string
 	status := http.StatusConflict
 	for _, item := range batch {
-		shortURL = h.storages.Postgres.GetShortKey(r.Context(), item.OriginalURL)
-		if shortURL == "" {
-			shortURL = service.GenerateShortKey()
-			err := h.storages.Postgres.Save(r.Context(), userID, item.OriginalURL, shortURL)
+		shortURL, created, err := h.shorten(r.Context(), userID, item.OriginalURL)
+		if err != nil {
+			h.log(r.Context()).Error("Unexpected save error", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if created {
 			status = http.StatusCreated
-			if err != nil {
-				h.logger.Printf("Unexpected save error: %v", err)
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
 		}
 		response = append(response, BatchResponseItem{
 			CorrelationID: item.CorrelationID,
-			ShortURL:      fmt.Sprintf("%s/%s", h.config.BaseURL, shortURL),
+			ShortURL:      fmt.Sprintf("%s/%s", h.conf().BaseURL, shortURL),
 		})
 	}
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.logger.Printf("Failed to encode response: %v", err)
+		h.log(r.Context()).Error("Failed to encode response", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
@@ -305,7 +651,7 @@ func (h *URLHandler) DeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 	var urls []string
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewDecoder(r.Body).Decode(&urls); err != nil {
-		h.logger.Println("Failed to decode batch request:", err)
+		h.log(r.Context()).Info("Failed to decode batch request", zap.Error(err))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -314,6 +660,42 @@ func (h *URLHandler) DeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	h.storages.Postgres.DeleteUserURLs(r.Context(), userID, urls)
+	if err := h.deleteUserURLs(r.Context(), userID, urls); stderrors.Is(err, errNoDatabase) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 	w.WriteHeader(http.StatusAccepted)
 }
+
+// errNoDatabase — операция поддерживается только базами данных: Postgres, SQLite, bbolt или шардами Postgres.
+var errNoDatabase = stderrors.New("database storage is not configured")
+
+// deleteUserURLs помечает ссылки пользователя удалёнными и возвращает ошибку хранилища.
+// Кэши сбрасываются и при ошибке: часть ссылок могла успеть удалиться.
+func (h *URLHandler) deleteUserURLs(ctx context.Context, userID string, keys []string) error {
+	var backend string
+	var deleteURLs func(context.Context, string, []string) error
+	switch {
+	case h.storages.Postgres != nil:
+		backend, deleteURLs = "postgres", h.storages.Postgres.DeleteUserURLs
+	case h.storages.SQLite != nil:
+		backend, deleteURLs = "sqlite", h.storages.SQLite.DeleteUserURLs
+	case h.storages.Bolt != nil:
+		backend, deleteURLs = "bolt", h.storages.Bolt.DeleteUserURLs
+	case h.storages.Sharded != nil:
+		backend, deleteURLs = "shards", h.storages.Sharded.DeleteUserURLs
+	default:
+		return errNoDatabase
+	}
+	sctx, done := h.startStorage(ctx, backend, "delete_user_urls")
+	err := deleteURLs(sctx, userID, keys)
+	done(err)
+	h.invalidate(ctx, keys...)
+	if err != nil {
+		h.log(ctx).Error("Failed to delete user URLs", zap.String("user_id", userID), zap.Error(err))
+	}
+	return err
+}
@@ -2,17 +2,22 @@ package app
 
 import (
 	"context"
-	"log"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/dron1337/shortener/internal/auth"
 	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/policy"
 	"github.com/dron1337/shortener/internal/store"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
 func TestGetURLHandler_RealStorage(t *testing.T) {
@@ -23,14 +28,14 @@ func TestGetURLHandler_RealStorage(t *testing.T) {
 
 	// Создаем реальное in-memory хранилище
 	storages := Storages{Memory: store.NewInMemoryStorage()}
-	handler := NewURLHandler(cfg, &storages, log.Default())
+	handler := NewURLHandler(cfg, &storages, zap.NewNop())
 	router := mux.NewRouter()
 	router.HandleFunc("/{key}", handler.GetURL).Methods("GET")
 
 	// 2. Подготовка данных - сохраним тестовый URL
 	testURL := "https://example.com"
 	userID := "test-user"
-	err := storages.Memory.Save(context.Background(), userID, testURL, "abc123")
+	err := storages.Memory.Save(context.Background(), userID, testURL, testURL, "abc123")
 	assert.NoError(t, err)
 
 	// 3. Тест успешного редиректа
@@ -61,7 +66,7 @@ func TestGenerateURLHandler_RealStorage(t *testing.T) {
 	}
 
 	storages := Storages{Memory: store.NewInMemoryStorage()}
-	handler := NewURLHandler(cfg, &storages, log.Default())
+	handler := NewURLHandler(cfg, &storages, zap.NewNop())
 
 	t.Run("Successful URL generation", func(t *testing.T) {
 		testURL := "https://example.com"
@@ -94,3 +99,214 @@ func TestGenerateURLHandler_RealStorage(t *testing.T) {
 		assert.Contains(t, rr2.Body.String(), cfg.BaseURL)
 	})
 }
+
+func TestGenerateURLHandler_Policy(t *testing.T) {
+	cfg := &config.Config{
+		BaseURL: "http://test.example",
+	}
+	storages := Storages{Memory: store.NewInMemoryStorage()}
+	handler := NewURLHandler(cfg, &storages, zap.NewNop())
+	urlPolicy, err := policy.New(policy.Options{
+		Schemes:  []string{"http", "https"},
+		SelfURLs: []string{cfg.BaseURL},
+	})
+	assert.NoError(t, err)
+	handler.runtime.Policy = urlPolicy
+
+	tests := []struct {
+		name   string
+		call   func(http.ResponseWriter, *http.Request)
+		body   string
+		reason string
+	}{
+		{"Text javascript scheme", handler.GenerateURL, "javascript:alert(1)", `scheme "javascript" is not allowed`},
+		{"JSON loopback", handler.GenerateJSONURL, `{"url":"http://127.0.0.1/admin"}`, "private or loopback"},
+		{"Batch loop", handler.GenerateBatchJSONURL, `[{"correlation_id":"1","original_url":"http://test.example/abc"}]`, "shortener itself"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, "test-user"))
+			rr := httptest.NewRecorder()
+
+			tt.call(rr, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.reason)
+		})
+	}
+}
+
+func TestGenerateURLHandler_Canonical(t *testing.T) {
+	cfg := &config.Config{
+		BaseURL:          "http://test.example",
+		URLStripTracking: true,
+	}
+	storages := Storages{Memory: store.NewInMemoryStorage()}
+	handler := NewURLHandler(cfg, &storages, zap.NewNop())
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, "test-user"))
+		rr := httptest.NewRecorder()
+		handler.GenerateURL(rr, req)
+		return rr
+	}
+
+	first := post("HTTP://Example.com/?utm_source=mail")
+	assert.Equal(t, http.StatusCreated, first.Code)
+	for _, variant := range []string{"http://example.com", "http://example.com/?", "http://EXAMPLE.com:80/"} {
+		rr := post(variant)
+		assert.Equal(t, http.StatusConflict, rr.Code, variant)
+		assert.Equal(t, first.Body.String(), rr.Body.String(), variant)
+	}
+
+	// Сохраняется исходная форма, введённая пользователем
+	urls := storages.Memory.GetURLsByUser(context.Background(), "test-user", cfg.BaseURL)
+	assert.Len(t, urls, 1)
+	assert.Equal(t, "HTTP://Example.com/?utm_source=mail", urls[0].OriginalURL)
+}
+
+func TestHandlers_EmbeddedDatabases(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		open func(t *testing.T) Storages
+	}{
+		{"SQLite", func(t *testing.T) Storages {
+			sqlite, err := store.OpenSQLite(store.SQLiteScheme + filepath.Join(t.TempDir(), "urls.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { sqlite.Close(context.Background()) })
+			return Storages{SQLite: sqlite, Memory: store.NewInMemoryStorage()}
+		}},
+		{"Bolt", func(t *testing.T) Storages {
+			bolt, err := store.OpenBolt(store.BoltScheme + filepath.Join(t.TempDir(), "urls.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { bolt.Close(context.Background()) })
+			return Storages{Bolt: bolt, Memory: store.NewInMemoryStorage()}
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{BaseURL: "http://test.example"}
+			storages := tt.open(t)
+			handler := NewURLHandler(cfg, &storages, zap.NewNop())
+			router := mux.NewRouter()
+			router.HandleFunc("/ping", handler.CheckDBConnection).Methods("GET")
+			router.HandleFunc("/{key}", handler.GetURL).Methods("GET")
+			router.HandleFunc("/", handler.GenerateURL).Methods("POST")
+			router.HandleFunc("/api/user/urls", handler.DeleteUserURLs).Methods("DELETE")
+			router.HandleFunc("/api/user/urls", handler.GetUserURLs).Methods("GET")
+			do := func(method, path, body string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, path, strings.NewReader(body))
+				req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, "test-user"))
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, req)
+				return rr
+			}
+
+			assert.Equal(t, http.StatusOK, do("GET", "/ping", "").Code)
+
+			rr := do("POST", "/", "https://example.com/db")
+			require.Equal(t, http.StatusCreated, rr.Code)
+			key := strings.TrimPrefix(rr.Body.String(), cfg.BaseURL+"/")
+			rr = do("POST", "/", "https://example.com/db")
+			assert.Equal(t, http.StatusConflict, rr.Code)
+
+			rr = do("GET", "/"+key, "")
+			assert.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+			assert.Equal(t, "https://example.com/db", rr.Header().Get("Location"))
+
+			// После перезапуска память пуста, список должен прийти из базы.
+			storages.Memory = store.NewInMemoryStorage()
+			rr = do("GET", "/api/user/urls", "")
+			require.Equal(t, http.StatusOK, rr.Code, "list after restart")
+			var urls []store.ResponseURLs
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &urls))
+			require.Len(t, urls, 1)
+			assert.Equal(t, cfg.BaseURL+"/"+key, urls[0].ShortURL)
+			assert.Equal(t, "https://example.com/db", urls[0].OriginalURL)
+
+			rr = do("DELETE", "/api/user/urls", `["`+key+`"]`)
+			require.Equal(t, http.StatusAccepted, rr.Code)
+			assert.Equal(t, http.StatusGone, do("GET", "/"+key, "").Code)
+			assert.Equal(t, http.StatusNoContent, do("GET", "/api/user/urls", "").Code, "list after delete")
+		})
+	}
+}
+
+func TestDeleteUserURLs_Errors(t *testing.T) {
+	del := func(storages *Storages) int {
+		handler := NewURLHandler(&config.Config{BaseURL: "http://test.example"}, storages, zap.NewNop())
+		req := httptest.NewRequest("DELETE", "/api/user/urls", strings.NewReader(`["abc"]`))
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, "test-user"))
+		rr := httptest.NewRecorder()
+		handler.DeleteUserURLs(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, del(&Storages{Memory: store.NewInMemoryStorage()}))
+
+	sqlite, err := store.OpenSQLite(store.SQLiteScheme + filepath.Join(t.TempDir(), "urls.db"))
+	require.NoError(t, err)
+	require.NoError(t, sqlite.Close(context.Background()))
+	assert.Equal(t, http.StatusInternalServerError, del(&Storages{SQLite: sqlite}), "ошибка записи в базу не скрывается за 202")
+}
+
+func TestGetUserURLs_SortAndFilter(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{BaseURL: "http://test.example"}
+	storages := Storages{Memory: store.NewInMemoryStorage()}
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+	_, err := storages.Memory.Import(ctx, []store.Record{
+		{ShortKey: "first", OriginalURL: "https://example.com/1", UserID: "test-user",
+			Timestamps: store.Timestamps{CreatedAt: day(1), UpdatedAt: day(9)}},
+		{ShortKey: "second", OriginalURL: "https://example.com/2", UserID: "test-user",
+			Timestamps: store.Timestamps{CreatedAt: day(2), UpdatedAt: day(2)}},
+		{ShortKey: "third", OriginalURL: "https://example.com/3", UserID: "test-user",
+			Timestamps: store.Timestamps{CreatedAt: day(3), UpdatedAt: day(5)}},
+		{ShortKey: "legacy", OriginalURL: "https://example.com/legacy", UserID: "test-user"},
+	}, false)
+	require.NoError(t, err)
+	handler := NewURLHandler(cfg, &storages, zap.NewNop())
+
+	list := func(query string) (int, []string) {
+		req := httptest.NewRequest("GET", "/api/user/urls"+query, nil)
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, "test-user"))
+		rr := httptest.NewRecorder()
+		handler.GetUserURLs(rr, req)
+		if rr.Code != http.StatusOK {
+			return rr.Code, nil
+		}
+		var urls []store.ResponseURLs
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &urls))
+		keys := make([]string, len(urls))
+		for i, u := range urls {
+			keys[i] = strings.TrimPrefix(u.ShortURL, cfg.BaseURL+"/")
+		}
+		return rr.Code, keys
+	}
+
+	for name, tt := range map[string]struct {
+		query string
+		want  []string
+	}{
+		"Created ascending":  {"?sort=created_at", []string{"legacy", "first", "second", "third"}},
+		"Created descending": {"?sort=-created_at", []string{"third", "second", "first", "legacy"}},
+		"Updated descending": {"?sort=-updated_at", []string{"first", "third", "second", "legacy"}},
+		"Created after":      {"?sort=created_at&created_after=2025-01-01T00:00:00Z", []string{"second", "third"}},
+		"Created range": {"?created_after=2025-01-01T12:00:00Z&created_before=2025-01-03T00:00:00Z",
+			[]string{"second"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			code, keys := list(tt.query)
+			require.Equal(t, http.StatusOK, code)
+			assert.Equal(t, tt.want, keys)
+		})
+	}
+
+	code, _ := list("?created_after=2026-01-01T00:00:00Z")
+	assert.Equal(t, http.StatusNoContent, code)
+	for _, query := range []string{"?sort=name", "?created_after=yesterday", "?created_before=2025-01-01"} {
+		code, _ := list(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Состояния проверок здоровья.
+const (
+	healthOK   = "ok"
+	healthFail = "fail"
+	// healthDegraded — компонент недоступен, но сервис работает без него.
+	healthDegraded = "degraded"
+)
+
+// healthCheckTimeout ограничивает каждую проверку зависимостей.
+const healthCheckTimeout = 2 * time.Second
+
+// ComponentHealth — результат проверки одного компонента.
+type ComponentHealth struct {
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+	// Depth — глубина очереди удаления, только для delete_queue.
+	Depth *int64 `json:"depth,omitempty"`
+}
+
+// HealthResponse — тело ответа /healthz и /readyz.
+type HealthResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components,omitempty"`
+}
+
+// Healthz сообщает, что процесс жив. Зависимости не проверяются,
+// чтобы недоступная БД не приводила к перезапуску контейнера.
+func (h *URLHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, HealthResponse{Status: healthOK})
+}
+
+// Readyz проверяет все настроенные хранилища и возвращает 503, если хоть одно
+// недоступно или сервер уже останавливается.
+func (h *URLHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	components := h.checkComponents(r.Context())
+	if h.runtime != nil && h.runtime.Draining() {
+		components["server"] = ComponentHealth{Status: healthFail, Error: "shutting down"}
+	}
+	resp := HealthResponse{Status: healthOK, Components: components}
+	code := http.StatusOK
+	for _, c := range components {
+		if c.Status == healthFail {
+			resp.Status = healthFail
+			code = http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeHealth(w, code, resp)
+}
+
+func (h *URLHandler) checkComponents(ctx context.Context) map[string]ComponentHealth {
+	components := make(map[string]ComponentHealth)
+	check := func(name string, fn func(context.Context) error) {
+		ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		defer cancel()
+		start := time.Now()
+		c := ComponentHealth{Status: healthOK}
+		if err := fn(ctx); err != nil {
+			c.Status, c.Error = healthFail, err.Error()
+		}
+		c.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+		components[name] = c
+	}
+	if h.storages.Postgres != nil {
+		check("postgres", h.storages.Postgres.CheckConnection)
+		components["delete_queue"] = h.deleteQueueHealth(h.storages.Postgres.PendingDeletes())
+		// Без реплик чтения идут в основную базу, поэтому их недоступность не снимает готовность
+		if healthy, total := h.storages.Postgres.HealthyReplicas(); total > 0 {
+			c := ComponentHealth{Status: healthOK}
+			if healthy < total {
+				c.Status, c.Error = healthDegraded, fmt.Sprintf("%d of %d replicas unavailable", total-healthy, total)
+			}
+			components["postgres_replicas"] = c
+		}
+	}
+	if h.storages.SQLite != nil {
+		check("sqlite", h.storages.SQLite.CheckConnection)
+	}
+	if h.storages.Bolt != nil {
+		check("bolt", h.storages.Bolt.CheckConnection)
+	}
+	if h.storages.Sharded != nil {
+		check("shards", h.storages.Sharded.CheckConnection)
+		components["delete_queue"] = h.deleteQueueHealth(h.storages.Sharded.PendingDeletes())
+	}
+	if h.storages.FileStorage != nil {
+		check("file", h.storages.FileStorage.CheckWritable)
+	}
+	if h.storages.Memory != nil {
+		components["memory"] = ComponentHealth{Status: healthOK}
+	}
+	if h.storages.CacheClient != nil {
+		check("cache", h.storages.CacheClient.Ping)
+		// С локальным LRU запросы обслуживаются и без RESP-сервера
+		if c := components["cache"]; c.Status == healthFail && h.conf().CacheSize > 0 {
+			c.Status = healthDegraded
+			components["cache"] = c
+		}
+	}
+	return components
+}
+
+// deleteQueueHealth снимает готовность, если очередь удаления длиннее ReadyDeleteQueueLimit.
+func (h *URLHandler) deleteQueueHealth(depth int64) ComponentHealth {
+	c := ComponentHealth{Status: healthOK, Depth: &depth}
+	if limit := h.conf().ReadyDeleteQueueLimit; limit > 0 && depth > int64(limit) {
+		c.Status, c.Error = healthFail, fmt.Sprintf("delete queue depth %d exceeds %d", depth, limit)
+	}
+	return c
+}
+
+func writeHealth(w http.ResponseWriter, code int, resp HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
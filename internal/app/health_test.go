@@ -0,0 +1,117 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/resp"
+	"github.com/dron1337/shortener/internal/store"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	cfg := config.Default()
+	newRouter := func(t *testing.T, storages *Storages) (*mux.Router, *Runtime) {
+		rt, err := NewRuntime(&cfg, storages, zap.NewNop())
+		require.NoError(t, err)
+		return NewRouter(rt, storages, zap.NewNop()), rt
+	}
+	get := func(router *mux.Router, path string) (*httptest.ResponseRecorder, HealthResponse) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		var resp HealthResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr, resp
+	}
+
+	t.Run("Healthy", func(t *testing.T) {
+		storages := &Storages{
+			Memory:      store.NewInMemoryStorage(),
+			FileStorage: store.NewFileStorage(filepath.Join(t.TempDir(), "urls.json")),
+		}
+		router, _ := newRouter(t, storages)
+
+		rr, resp := get(router, "/healthz")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "ok", resp.Status)
+
+		rr, resp = get(router, "/readyz")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "ok", resp.Status)
+		assert.Equal(t, "ok", resp.Components["file"].Status)
+		assert.Equal(t, "ok", resp.Components["memory"].Status)
+		assert.NotContains(t, resp.Components, "postgres")
+	})
+
+	t.Run("Unwritable file storage", func(t *testing.T) {
+		storages := &Storages{
+			Memory:      store.NewInMemoryStorage(),
+			FileStorage: store.NewFileStorage(t.TempDir()),
+		}
+		router, _ := newRouter(t, storages)
+
+		rr, resp := get(router, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(t, "fail", resp.Status)
+		assert.Equal(t, "fail", resp.Components["file"].Status)
+		assert.NotEmpty(t, resp.Components["file"].Error)
+
+		rr, _ = get(router, "/healthz")
+		assert.Equal(t, http.StatusOK, rr.Code, "liveness не зависит от хранилищ")
+	})
+
+	t.Run("Draining", func(t *testing.T) {
+		router, rt := newRouter(t, &Storages{Memory: store.NewInMemoryStorage()})
+		rt.StartDraining()
+
+		rr, resp := get(router, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(t, "fail", resp.Components["server"].Status)
+		rr, _ = get(router, "/healthz")
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("Ping without database", func(t *testing.T) {
+		router, _ := newRouter(t, &Storages{Memory: store.NewInMemoryStorage()})
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/ping", nil))
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+
+	t.Run("Cache", func(t *testing.T) {
+		server, err := resp.NewServer("127.0.0.1:0", "")
+		require.NoError(t, err)
+		defer server.Close()
+		cacheCfg := cfg
+		cacheCfg.CacheAddress = server.Addr()
+		cacheCfg.CacheSize = 100
+		storages := &Storages{FileStorage: store.NewFileStorage(filepath.Join(t.TempDir(), "urls.json"))}
+		newURLCache(&cacheCfg, storages, zap.NewNop())
+		require.NotNil(t, storages.Cache)
+		defer storages.CacheClient.Close()
+		rt, err := NewRuntime(&cacheCfg, storages, zap.NewNop())
+		require.NoError(t, err)
+		router := NewRouter(rt, storages, zap.NewNop())
+
+		rr, health := get(router, "/readyz")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "ok", health.Components["cache"].Status)
+
+		require.NoError(t, server.Close())
+		rr, health = get(router, "/readyz")
+		assert.Equal(t, http.StatusOK, rr.Code, "с локальным LRU сервис остаётся готовым")
+		assert.Equal(t, "degraded", health.Components["cache"].Status)
+
+		cacheCfg.CacheSize = 0
+		rr, health = get(router, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(t, "fail", health.Components["cache"].Status)
+	})
+}
@@ -0,0 +1,179 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dron1337/shortener/internal/lifecycle"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// registerHooks регистрирует компоненты в порядке запуска. Останавливаются они
+// в обратном: сначала слушатели дожидаются текущих запросов, затем фоновые
+// наблюдатели, телеметрия и в последнюю очередь хранилища.
+func (s *Server) registerHooks() {
+	if pg := s.Storages.Postgres; pg != nil {
+		s.Lifecycle.Append(lifecycle.Hook{Name: "postgres", OnStop: pg.Close})
+	}
+	if sqlite := s.Storages.SQLite; sqlite != nil {
+		s.Lifecycle.Append(lifecycle.Hook{Name: "sqlite", OnStop: sqlite.Close})
+	}
+	if bolt := s.Storages.Bolt; bolt != nil {
+		s.Lifecycle.Append(lifecycle.Hook{Name: "bolt", OnStop: bolt.Close})
+	}
+	if sharded := s.Storages.Sharded; sharded != nil {
+		s.Lifecycle.Append(lifecycle.Hook{Name: "shards", OnStop: sharded.Close})
+	}
+	// Очистка корзин лимитов работает поверх базы и останавливается раньше неё.
+	if limiter := s.Runtime.Limiter; limiter != nil {
+		s.Lifecycle.Append(lifecycle.Hook{Name: "ratelimit", OnStop: limiter.Close})
+	}
+	if client := s.Storages.CacheClient; client != nil {
+		s.Lifecycle.Append(lifecycle.Hook{
+			Name:   "cache",
+			OnStop: func(context.Context) error { return client.Close() },
+		})
+	}
+	s.Lifecycle.Append(lifecycle.Hook{
+		Name: "telemetry",
+		OnStop: func(ctx context.Context) error {
+			return errors.Join(s.Runtime.Tracer.Close(), s.Runtime.AccessLog.Close())
+		},
+	})
+	s.Lifecycle.Append(s.watchersHook())
+	if s.GRPCServer != nil {
+		s.Lifecycle.Append(s.grpcHook())
+	}
+	if s.RedirectServer != nil {
+		s.Lifecycle.Append(s.httpHook("redirect", s.RedirectServer, false))
+	}
+	s.Lifecycle.Append(s.httpHook("http", s.HTTPServer, s.Certificates != nil))
+}
+
+// reportServeError передаёт ошибку упавшего слушателя в Start, не блокируясь,
+// если её уже некому читать.
+func (s *Server) reportServeError(name string, err error) {
+	select {
+	case s.serveErr <- fmt.Errorf("%s: %w", name, err):
+	default:
+	}
+}
+
+// httpHook слушает адрес при запуске, чтобы ошибка занятого порта вернулась из Start,
+// и при остановке дожидается текущих запросов, а по истечении срока рвёт соединения.
+func (s *Server) httpHook(name string, srv *http.Server, useTLS bool) lifecycle.Hook {
+	done := make(chan struct{})
+	return lifecycle.Hook{
+		Name: name,
+		OnStart: func(ctx context.Context) error {
+			ln, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				return err
+			}
+			// Запоминаем фактический адрес: при порте 0 его выбирает ОС
+			srv.Addr = ln.Addr().String()
+			s.Logger.Info("Listening", zap.String("server", name), zap.String("address", srv.Addr))
+			go func() {
+				defer close(done)
+				var err error
+				if useTLS {
+					err = srv.ServeTLS(ln, "", "")
+				} else {
+					err = srv.Serve(ln)
+				}
+				if err != nil && !errors.Is(err, http.ErrServerClosed) {
+					s.reportServeError(name, err)
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			err := srv.Shutdown(ctx)
+			if err != nil {
+				srv.Close()
+			}
+			<-done
+			return err
+		},
+	}
+}
+
+func (s *Server) grpcHook() lifecycle.Hook {
+	done := make(chan struct{})
+	return lifecycle.Hook{
+		Name: "grpc",
+		OnStart: func(ctx context.Context) error {
+			ln, err := net.Listen("tcp", s.Config.GRPCAddress)
+			if err != nil {
+				return err
+			}
+			s.Logger.Info("Listening", zap.String("server", "grpc"), zap.Stringer("address", ln.Addr()))
+			go func() {
+				defer close(done)
+				if err := s.GRPCServer.Serve(ln); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+					s.reportServeError("grpc", err)
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			stopped := make(chan struct{})
+			go func() {
+				s.GRPCServer.GracefulStop()
+				close(stopped)
+			}()
+			var err error
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				err = ctx.Err()
+				s.GRPCServer.Stop()
+				<-stopped
+			}
+			<-done
+			return err
+		},
+	}
+}
+
+// watchersHook запускает наблюдение за файлами политики URL и сертификатов.
+func (s *Server) watchersHook() lifecycle.Hook {
+	var (
+		wg     sync.WaitGroup
+		cancel context.CancelFunc
+	)
+	return lifecycle.Hook{
+		Name: "watchers",
+		OnStart: func(context.Context) error {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.Runtime.Policy.Watch(ctx, 5*time.Second, func(err error) {
+					s.Logger.Warn("URL policy reload failed", zap.Error(err))
+				})
+			}()
+			if s.Certificates != nil {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.Certificates.Watch(ctx, 5*time.Second, func(err error) {
+						s.Logger.Warn("TLS certificate reload failed", zap.Error(err))
+					})
+				}()
+			}
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			cancel()
+			return lifecycle.Wait(ctx, &wg)
+		},
+	}
+}
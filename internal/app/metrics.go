@@ -0,0 +1,115 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dron1337/shortener/internal/metrics"
+	"github.com/gorilla/mux"
+)
+
+// Metrics — метрики HTTP, хранилищ и генерации ключей.
+type Metrics struct {
+	Registry        *metrics.Registry
+	requests        *metrics.CounterVec
+	requestDuration *metrics.HistogramVec
+	storageDuration *metrics.HistogramVec
+	keyCollisions   *metrics.CounterVec
+}
+
+func newMetrics(storages *Storages) *Metrics {
+	reg := metrics.NewRegistry()
+	m := &Metrics{
+		Registry: reg,
+		requests: reg.NewCounterVec("http_requests_total",
+			"Number of HTTP requests by route template and status.", "method", "route", "status"),
+		requestDuration: reg.NewHistogramVec("http_request_duration_seconds",
+			"HTTP request latency by route template.", metrics.DefBuckets, "method", "route"),
+		storageDuration: reg.NewHistogramVec("storage_operation_duration_seconds",
+			"Storage operation latency by backend.", metrics.DefBuckets, "backend", "operation"),
+		keyCollisions: reg.NewCounterVec("short_key_collisions_total",
+			"Generated short keys that were already taken."),
+	}
+	pendingDeletes := func() float64 { return 0 }
+	if storages != nil && storages.Postgres != nil {
+		pg := storages.Postgres
+		pendingDeletes = func() float64 { return float64(pg.PendingDeletes()) }
+		db := pg.DB()
+		reg.NewGaugeFunc("db_open_connections", "Established DB connections, in use and idle.",
+			func() float64 { return float64(db.Stats().OpenConnections) })
+		reg.NewGaugeFunc("db_in_use_connections", "DB connections currently in use.",
+			func() float64 { return float64(db.Stats().InUse) })
+		reg.NewGaugeFunc("db_idle_connections", "Idle DB connections.",
+			func() float64 { return float64(db.Stats().Idle) })
+		reg.NewGaugeFunc("db_max_open_connections", "Maximum number of open DB connections.",
+			func() float64 { return float64(db.Stats().MaxOpenConnections) })
+		reg.NewCounterFunc("db_wait_count_total", "Connections waited for because the pool was exhausted.",
+			func() float64 { return float64(db.Stats().WaitCount) })
+		reg.NewGaugeFunc("db_replicas_healthy", "Read replicas that passed the last health check.",
+			func() float64 { healthy, _ := pg.HealthyReplicas(); return float64(healthy) })
+		reg.NewCounterFunc("db_wait_duration_seconds_total", "Total time spent waiting for a DB connection.",
+			func() float64 { return db.Stats().WaitDuration.Seconds() })
+	}
+	if storages != nil && storages.Sharded != nil {
+		sharded := storages.Sharded
+		pendingDeletes = func() float64 { return float64(sharded.PendingDeletes()) }
+	}
+	if storages != nil && storages.LocalCache != nil {
+		lru := storages.LocalCache
+		reg.NewCounterFunc("url_cache_hits_total", "Short key lookups served from the in-process cache.",
+			func() float64 { return float64(lru.Stats().Hits) })
+		reg.NewCounterFunc("url_cache_misses_total", "Short key lookups not found in the in-process cache.",
+			func() float64 { return float64(lru.Stats().Misses) })
+		reg.NewCounterFunc("url_cache_evictions_total", "Entries evicted from the in-process cache to respect its size.",
+			func() float64 { return float64(lru.Stats().Evictions) })
+		reg.NewGaugeFunc("url_cache_entries", "Entries in the in-process cache, including expired ones not yet removed.",
+			func() float64 { return float64(lru.Stats().Entries) })
+	}
+	reg.NewGaugeFunc("delete_queue_depth", "URLs queued for deletion and not yet processed.", pendingDeletes)
+	return m
+}
+
+// observeStorage учитывает длительность операции хранилища, начатой в start.
+func (m *Metrics) observeStorage(backend, operation string, start time.Time) {
+	m.storageDuration.Observe(time.Since(start).Seconds(), backend, operation)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Middleware считает запросы по шаблону маршрута, а не по пути, чтобы ключи ссылок не раздували число рядов.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tpl, err := current.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		m.requests.Inc(r.Method, route, strconv.Itoa(rec.status))
+		m.requestDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	cfg := config.Default()
+	storages := Storages{Memory: store.NewInMemoryStorage()}
+	rt, err := NewRuntime(&cfg, &storages, zap.NewNop())
+	require.NoError(t, err)
+	router := NewRouter(rt, &storages, zap.NewNop())
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader("https://example.com/metrics")))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	key := strings.TrimPrefix(rr.Body.String(), cfg.BaseURL+"/")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/"+key, nil))
+	require.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	for _, want := range []string{
+		`http_requests_total{method="POST",route="/",status="201"} 1`,
+		`http_requests_total{method="GET",route="/{key}",status="307"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/{key}"} 1`,
+		`storage_operation_duration_seconds_count{backend="memory",operation="save"} 1`,
+		`short_key_collisions_total 0`,
+		`delete_queue_depth 0`,
+	} {
+		assert.Contains(t, body, want)
+	}
+	assert.NotContains(t, body, key, "short keys must not leak into labels")
+}
+
+func TestURLCacheMetrics(t *testing.T) {
+	cfg := config.Default()
+	storages := Storages{FileStorage: store.NewFileStorage(filepath.Join(t.TempDir(), "urls.json"))}
+	newURLCache(&cfg, &storages, zap.NewNop())
+	require.NotNil(t, storages.LocalCache)
+	rt, err := NewRuntime(&cfg, &storages, zap.NewNop())
+	require.NoError(t, err)
+	router := NewRouter(rt, &storages, zap.NewNop())
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader("https://example.com/cached")))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	key := strings.TrimPrefix(rr.Body.String(), cfg.BaseURL+"/")
+	for _, path := range []string{"/" + key, "/" + key, "/unknown"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	body := rr.Body.String()
+	assert.Contains(t, body, "url_cache_hits_total 2")
+	assert.Contains(t, body, "url_cache_misses_total 1")
+	assert.Contains(t, body, "url_cache_entries 2", "ссылка и отметка о неизвестном ключе")
+	assert.Contains(t, body, `storage_operation_duration_seconds_count{backend="file",operation="get_original_url"} 3`)
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/dron1337/shortener/internal/clientip"
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/ratelimit"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// Группы маршрутов с отдельными лимитами
+const (
+	rateGroupShorten  = "shorten"
+	rateGroupRedirect = "redirect"
+	rateGroupAPI      = "api"
+	// rateGroupProbe — проверки здоровья; лимит для неё не задаётся
+	rateGroupProbe = "probe"
+)
+
+// rateLimitGroup относит запрос к группе по шаблону сработавшего маршрута.
+func rateLimitGroup(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return rateGroupAPI
+	}
+	tpl, _ := route.GetPathTemplate()
+	switch {
+	case tpl == "/healthz" || tpl == "/readyz":
+		return rateGroupProbe
+	case r.Method == http.MethodPost && (tpl == "/" || tpl == "/api/shorten" || tpl == "/api/shorten/batch" || tpl == "/api/workspaces/{id}/urls"):
+		return rateGroupShorten
+	case r.Method == http.MethodGet && tpl == "/{key}":
+		return rateGroupRedirect
+	default:
+		return rateGroupAPI
+	}
+}
+
+func rateLimits(cfg *config.Config) map[string]ratelimit.Limit {
+	limits := make(map[string]ratelimit.Limit)
+	for group, value := range map[string]string{
+		rateGroupShorten:  cfg.RateLimitShorten,
+		rateGroupRedirect: cfg.RateLimitRedirect,
+		rateGroupAPI:      cfg.RateLimitAPI,
+	} {
+		// Значения уже проверены в config.LoadConfig
+		if limit, err := ratelimit.ParseLimit(value); err == nil && limit.Enabled() {
+			limits[group] = limit
+		}
+	}
+	return limits
+}
+
+// rateLimitIdleTTL — через сколько удаляется корзина клиента, не делавшего запросов.
+const rateLimitIdleTTL = 10 * time.Minute
+
+func newRateLimiter(cfg *config.Config, storages *Storages, logger *zap.Logger) *ratelimit.Limiter {
+	onError := func(err error) { logger.Error("Rate limit store error", zap.Error(err)) }
+	var rateStore ratelimit.Store = ratelimit.NewMemoryStore(rateLimitIdleTTL)
+	var db *sql.DB
+	switch {
+	case storages.Postgres != nil:
+		db = storages.Postgres.DB()
+	case storages.Sharded != nil:
+		// Счётчики лимитов не шардируются и хранятся в первом шарде
+		db = storages.Sharded.Shards()[0].DB()
+	}
+	if cfg.RateLimitStore == "postgres" && db != nil {
+		pgStore, err := ratelimit.NewPostgresStore(db, rateLimitIdleTTL, onError)
+		if err != nil {
+			logger.Warn("Shared rate limit store disabled", zap.Error(err))
+		} else {
+			rateStore = pgStore
+		}
+	}
+	trusted, _ := clientip.ParseCIDRs(cfg.TrustedProxies)
+	return ratelimit.NewLimiter(rateStore, rateLimits(cfg), rateLimitGroup, clientip.NewResolver(trusted), onError)
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/store"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// openReplicas подключает реплики для чтения к основной базе. Недоступная при
+// запуске реплика пропускается: чтения без неё идут на остальные или в основную базу.
+func openReplicas(cfg *config.Config, pg *store.PostgresStorage, logger *zap.Logger) {
+	var dbs []*sql.DB
+	for i, dsn := range cfg.ReplicaDSNs() {
+		db, err := store.OpenDB(dsn, store.PoolConfig{
+			MaxOpenConns:    cfg.DBMaxOpenConns,
+			MaxIdleConns:    cfg.DBMaxIdleConns,
+			ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		})
+		if err != nil {
+			logger.Warn("DB replica disabled", zap.Int("replica", i), zap.Error(err))
+			continue
+		}
+		dbs = append(dbs, db)
+	}
+	pg.SetReplicas(dbs, cfg.DBReplicaCheckInterval)
+}
+
+// trackWrites включает чтение своих записей: после записи в рамках запроса
+// чтения идут в основную базу, а не на реплику.
+func trackWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(store.TrackWrites(r.Context())))
+	})
+}
+
+// trackWritesInterceptor — аналог trackWrites для gRPC.
+func trackWritesInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(store.TrackWrites(ctx), req)
+}
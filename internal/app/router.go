@@ -1,32 +1,55 @@
 package app
 
 import (
-	"log"
+	"net/http"
 
 	"github.com/dron1337/shortener/internal/auth"
-	"github.com/dron1337/shortener/internal/config"
 	"github.com/dron1337/shortener/internal/logger"
 	"github.com/dron1337/shortener/internal/service"
 	"github.com/gorilla/mux"
+	"go.uber.org/zap"
 )
 
-func NewRouter(cfg *config.Config, storages *Storages, log *log.Logger) *mux.Router {
+func NewRouter(rt *Runtime, storages *Storages, log *zap.Logger) *mux.Router {
 	r := mux.NewRouter()
 
-	if err := logger.Initialize("info"); err != nil {
-		panic(err)
-	}
-
-	r.Use(logger.LoggingMiddleware)
+	r.Use(tracingMiddleware(rt.Tracer))
+	r.Use(logger.Middleware(log))
+	r.Use(rt.AccessLog.Middleware(accessLogClientIP(rt.Config())))
+	r.Use(rt.Metrics.Middleware)
+	r.Use(rt.CORS.Middleware)
 	r.Use(service.GzipHandle)
 	r.Use(auth.AuthMiddleware)
-	handler := NewURLHandler(cfg, storages, log)
+	r.Use(accessLogUser)
+	r.Use(rt.Limiter.Middleware)
+	r.Use(trackWrites)
+	handler := NewURLHandler(rt.Config(), storages, log)
+	handler.runtime = rt
+	// Preflight-запросы CORS обрабатывает middleware, маршрут нужен, чтобы он сработал
+	r.PathPrefix("/").Methods("OPTIONS").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	r.HandleFunc("/api/admin/reload", handler.ReloadConfig).Methods("POST")
+	r.HandleFunc("/api/admin/export", handler.ExportURLs).Methods("GET")
+	r.HandleFunc("/api/admin/import", handler.ImportURLs).Methods("POST")
+	r.HandleFunc("/api/internal/stats", handler.GetStats).Methods("GET")
+	r.Handle("/metrics", rt.Metrics.Registry.Handler()).Methods("GET")
 	r.HandleFunc("/ping", handler.CheckDBConnection).Methods("GET")
+	r.HandleFunc("/healthz", handler.Healthz).Methods("GET")
+	r.HandleFunc("/readyz", handler.Readyz).Methods("GET")
 	r.HandleFunc("/{key}", handler.GetURL).Methods("GET")
 	r.HandleFunc("/api/user/urls", handler.GetUserURLs).Methods("GET")
 	r.HandleFunc("/", handler.GenerateURL).Methods("POST")
 	r.HandleFunc("/api/shorten", handler.GenerateJSONURL).Methods("POST")
 	r.HandleFunc("/api/shorten/batch", handler.GenerateBatchJSONURL).Methods("POST")
 	r.HandleFunc("/api/user/urls", handler.DeleteUserURLs).Methods("DELETE")
+	r.HandleFunc("/api/workspaces", handler.CreateWorkspace).Methods("POST")
+	r.HandleFunc("/api/workspaces/{id}/members", handler.GetWorkspaceMembers).Methods("GET")
+	r.HandleFunc("/api/workspaces/{id}/members", handler.SetWorkspaceMember).Methods("PUT")
+	r.HandleFunc("/api/workspaces/{id}/members/{userID}", handler.RemoveWorkspaceMember).Methods("DELETE")
+	r.HandleFunc("/api/workspaces/{id}/urls", handler.GetWorkspaceURLs).Methods("GET")
+	r.HandleFunc("/api/workspaces/{id}/urls", handler.GenerateWorkspaceURL).Methods("POST")
+	r.HandleFunc("/api/workspaces/{id}/urls", handler.DeleteWorkspaceURLs).Methods("DELETE")
+	r.HandleFunc("/api/workspaces/{id}/urls/transfer", handler.TransferWorkspaceURLs).Methods("POST")
 	return r
 }
@@ -1,25 +1,31 @@
 package app
-/*
+
 import (
-	"log"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/store"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
 func TestRouter(t *testing.T) {
-	cfg := &config.Config{
-		ServerAddress: "localhost:8080",
-		BaseURL:       "http://test.example",
-	}
+	cfg := config.Default()
+	cfg.BaseURL = "http://test.example"
 
 	t.Run("TestValidRoutes", func(t *testing.T) {
-		mockStore := new(MockStorage)
-		router := NewRouter(cfg, mockStore, log.Default())
+		storages := Storages{Memory: store.NewInMemoryStorage()}
+		assert.NoError(t, storages.Memory.Save(t.Context(), "user", "http://example.com/abc", "http://example.com/abc", "abc123"))
+		rt, err := NewRuntime(&cfg, &storages, zap.NewNop())
+		assert.NoError(t, err)
+		router := NewRouter(rt, &storages, zap.NewNop())
 
 		tests := []struct {
 			method       string
@@ -29,7 +35,7 @@ func TestRouter(t *testing.T) {
 			{"POST", "/", http.StatusCreated},
 			{"GET", "/abc123", http.StatusTemporaryRedirect},
 			{"POST", "/api/shorten", http.StatusCreated},
-			{"GET", "/ping", http.StatusOK},
+			{"GET", "/api/user/urls", http.StatusNoContent},
 		}
 
 		for _, tt := range tests {
@@ -37,7 +43,7 @@ func TestRouter(t *testing.T) {
 				var req *http.Request
 				if tt.method == "POST" {
 					if tt.path == "/api/shorten" {
-						req = httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"url":"http://example.com"}`))
+						req = httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"url":"http://example.com/json"}`))
 						req.Header.Set("Content-Type", "application/json")
 					} else {
 						req = httptest.NewRequest(tt.method, tt.path, strings.NewReader("http://example.com"))
@@ -54,5 +60,27 @@ func TestRouter(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("TestAccessLog", func(t *testing.T) {
+		logCfg := cfg
+		logCfg.AccessLog = filepath.Join(t.TempDir(), "access.log")
+		logCfg.AccessLogFormat = "json"
+		storages := Storages{Memory: store.NewInMemoryStorage()}
+		require.NoError(t, storages.Memory.Save(t.Context(), "user", "http://example.com/abc", "http://example.com/abc", "abc123"))
+		rt, err := NewRuntime(&logCfg, &storages, zap.NewNop())
+		require.NoError(t, err)
+		router := NewRouter(rt, &storages, zap.NewNop())
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abc123", nil))
+		require.NoError(t, rt.AccessLog.Close())
+
+		data, err := os.ReadFile(logCfg.AccessLog)
+		require.NoError(t, err)
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(data, &entry))
+		assert.Equal(t, "abc123", entry["short_key"])
+		assert.Equal(t, float64(http.StatusTemporaryRedirect), entry["status"])
+		assert.NotEmpty(t, entry["user_id"])
+		assert.NotEmpty(t, entry["request_id"])
+	})
 }
-*/
\ No newline at end of file
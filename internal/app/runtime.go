@@ -0,0 +1,199 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dron1337/shortener/internal/accesslog"
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/cors"
+	"github.com/dron1337/shortener/internal/logger"
+	"github.com/dron1337/shortener/internal/policy"
+	"github.com/dron1337/shortener/internal/ratelimit"
+	"github.com/dron1337/shortener/internal/tracing"
+	"go.uber.org/zap"
+)
+
+// reloadableFields — поля, которые применяются без перезапуска сервера.
+var reloadableFields = map[string]bool{
+	"log_level":           true,
+	"base_url":            true,
+	"rate_limit_shorten":  true,
+	"rate_limit_redirect": true,
+	"rate_limit_api":      true,
+	"url_blocklist_file":  true,
+	"url_allowlist_file":  true,
+	"cors_origins":        true,
+}
+
+// Runtime хранит текущую конфигурацию и компоненты, которые можно перенастроить на лету.
+type Runtime struct {
+	cfg     atomic.Pointer[config.Config]
+	Policy  *policy.Policy
+	Limiter *ratelimit.Limiter
+	CORS    *cors.CORS
+	Metrics *Metrics
+	Tracer  *tracing.Tracer
+	// AccessLog — журнал запросов; nil, если он отключён.
+	AccessLog *accesslog.Logger
+	// draining выставляется в начале остановки, чтобы балансировщик снял трафик.
+	draining atomic.Bool
+
+	mu     sync.Mutex
+	loader func() (*config.Config, error)
+}
+
+// ReloadResult описывает итог перезагрузки конфигурации.
+type ReloadResult struct {
+	Applied []config.Change `json:"applied"`
+	// Ignored — изменения, которые вступят в силу только после перезапуска.
+	Ignored []config.Change `json:"ignored"`
+}
+
+func newStaticRuntime(cfg *config.Config) *Runtime {
+	rt := &Runtime{Metrics: newMetrics(nil), Tracer: tracing.NewTracer(serviceName, nil)}
+	rt.cfg.Store(cfg)
+	return rt
+}
+
+func NewRuntime(cfg *config.Config, storages *Storages, logger *zap.Logger) (*Runtime, error) {
+	urlPolicy, err := newURLPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	rt := newStaticRuntime(cfg)
+	rt.Policy = urlPolicy
+	rt.Limiter = newRateLimiter(cfg, storages, logger)
+	rt.CORS = cors.New(cfg.CORSOrigins)
+	rt.Metrics = newMetrics(storages)
+	exporter, err := tracing.OpenExporter(serviceName, cfg.TraceOutput)
+	if err != nil {
+		return nil, err
+	}
+	rt.Tracer = tracing.NewTracer(serviceName, exporter)
+	rt.AccessLog, err = accesslog.Open(accesslog.Options{
+		Output:             cfg.AccessLog,
+		Format:             cfg.AccessLogFormat,
+		MaxSize:            int64(cfg.AccessLogMaxSize) << 20,
+		MaxBackups:         cfg.AccessLogMaxBackups,
+		RedirectSampleRate: cfg.AccessLogRedirectSample,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rt.AccessLog != nil {
+		rt.AccessLog.OnError(func(err error) {
+			logger.Warn("Access log write failed", zap.Error(err))
+		})
+	}
+	return rt, nil
+}
+
+func (rt *Runtime) Config() *config.Config {
+	return rt.cfg.Load()
+}
+
+// StartDraining переводит /readyz в состояние «не готов».
+func (rt *Runtime) StartDraining() {
+	rt.draining.Store(true)
+}
+
+// Draining сообщает, что сервер останавливается.
+func (rt *Runtime) Draining() bool {
+	return rt.draining.Load()
+}
+
+// SetLoader задаёт источник конфигурации для Reload.
+func (rt *Runtime) SetLoader(loader func() (*config.Config, error)) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.loader = loader
+}
+
+// Reload перечитывает конфигурацию и применяет безопасные изменения.
+func (rt *Runtime) Reload() (ReloadResult, error) {
+	rt.mu.Lock()
+	loader := rt.loader
+	rt.mu.Unlock()
+	if loader == nil {
+		return ReloadResult{}, errors.New("config reload is not configured")
+	}
+	next, err := loader()
+	if err != nil {
+		return ReloadResult{}, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return rt.Apply(next)
+}
+
+// Apply применяет безопасное подмножество next. Либо применяются все изменения,
+// либо (при ошибке) не применяется ни одно.
+func (rt *Runtime) Apply(next *config.Config) (ReloadResult, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	cur := rt.Config()
+	result := ReloadResult{}
+	for _, change := range config.Diff(*cur, *next) {
+		if reloadableFields[change.Field] {
+			result.Applied = append(result.Applied, change)
+		} else {
+			result.Ignored = append(result.Ignored, change)
+		}
+	}
+	if len(result.Applied) == 0 {
+		return result, nil
+	}
+
+	// Сначала проверяем всё, что может завершиться ошибкой
+	if err := next.Validate(nil); err != nil {
+		return ReloadResult{}, err
+	}
+	limits := rateLimits(next)
+	if rt.Policy != nil && (cur.URLBlocklistFile != next.URLBlocklistFile || cur.URLAllowlistFile != next.URLAllowlistFile) {
+		if err := rt.Policy.SetFiles(next.URLBlocklistFile, next.URLAllowlistFile); err != nil {
+			return ReloadResult{}, err
+		}
+	}
+	if err := logger.SetLevel(next.LogLevel); err != nil {
+		return ReloadResult{}, err
+	}
+
+	if rt.Limiter != nil {
+		rt.Limiter.SetLimits(limits)
+	}
+	if rt.CORS != nil {
+		rt.CORS.SetOrigins(next.CORSOrigins)
+	}
+	if rt.Policy != nil {
+		rt.Policy.SetSelfURLs([]string{next.BaseURL, cur.ServerAddress})
+	}
+	updated := *cur
+	updated.LogLevel = next.LogLevel
+	updated.BaseURL = next.BaseURL
+	updated.RateLimitShorten = next.RateLimitShorten
+	updated.RateLimitRedirect = next.RateLimitRedirect
+	updated.RateLimitAPI = next.RateLimitAPI
+	updated.URLBlocklistFile = next.URLBlocklistFile
+	updated.URLAllowlistFile = next.URLAllowlistFile
+	updated.CORSOrigins = next.CORSOrigins
+	rt.cfg.Store(&updated)
+	return result, nil
+}
+
+func logReload(logger *zap.Logger, result ReloadResult, err error) {
+	if err != nil {
+		logger.Warn("Configuration reload rejected", zap.Error(err))
+		return
+	}
+	if len(result.Applied) == 0 && len(result.Ignored) == 0 {
+		logger.Info("Configuration reloaded: no changes")
+		return
+	}
+	for _, change := range result.Applied {
+		logger.Info("Configuration reloaded", zap.String("field", change.Field), zap.String("old", change.Old), zap.String("new", change.New))
+	}
+	for _, change := range result.Ignored {
+		logger.Warn("Configuration change requires restart", zap.String("field", change.Field), zap.String("old", change.Old), zap.String("new", change.New))
+	}
+}
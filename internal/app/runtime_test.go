@@ -0,0 +1,80 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRuntimeApply(t *testing.T) {
+	cfg := config.Default()
+	storages := Storages{Memory: store.NewInMemoryStorage()}
+	rt, err := NewRuntime(&cfg, &storages, zap.NewNop())
+	require.NoError(t, err)
+
+	t.Run("Safe and restart-only changes", func(t *testing.T) {
+		next := *rt.Config()
+		next.BaseURL = "https://sho.rt"
+		next.LogLevel = "debug"
+		next.ServerAddress = "localhost:9090"
+		result, err := rt.Apply(&next)
+		require.NoError(t, err)
+		require.Len(t, result.Applied, 2)
+		require.Len(t, result.Ignored, 1)
+		assert.Equal(t, "server_address", result.Ignored[0].Field)
+		assert.Equal(t, "https://sho.rt", rt.Config().BaseURL)
+		assert.Equal(t, "debug", rt.Config().LogLevel)
+		assert.Equal(t, "localhost:8080", rt.Config().ServerAddress)
+	})
+
+	t.Run("Invalid config is rejected", func(t *testing.T) {
+		next := *rt.Config()
+		next.BaseURL = "https://other.example"
+		next.RateLimitAPI = "fast"
+		_, err := rt.Apply(&next)
+		require.Error(t, err)
+		assert.Equal(t, "https://sho.rt", rt.Config().BaseURL)
+	})
+}
+
+func TestReloadEndpoint(t *testing.T) {
+	cfg := config.Default()
+	cfg.AdminToken = "secret"
+	storages := Storages{Memory: store.NewInMemoryStorage()}
+	rt, err := NewRuntime(&cfg, &storages, zap.NewNop())
+	require.NoError(t, err)
+	rt.SetLoader(func() (*config.Config, error) {
+		next := cfg
+		next.CORSOrigins = "https://app.example"
+		return &next, nil
+	})
+	router := NewRouter(rt, &storages, zap.NewNop())
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{name: "No token", status: http.StatusUnauthorized},
+		{name: "Wrong token", token: "Bearer nope", status: http.StatusUnauthorized},
+		{name: "Valid token", token: "Bearer secret", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/admin/reload", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.status, rr.Code)
+		})
+	}
+	assert.Equal(t, "https://app.example", rt.Config().CORSOrigins)
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/dron1337/shortener/internal/clientip"
+	"github.com/dron1337/shortener/internal/store"
+	"go.uber.org/zap"
+)
+
+// fromTrustedSubnet проверяет, что адрес клиента входит в доверенную подсеть.
+// X-Real-IP учитывается только от прокси из TrustedProxies, иначе берётся адрес
+// соединения. Без настроенной подсети доступ закрыт.
+func (h *URLHandler) fromTrustedSubnet(r *http.Request) bool {
+	cfg := h.conf()
+	if cfg.TrustedSubnet == "" {
+		return false
+	}
+	_, network, err := net.ParseCIDR(cfg.TrustedSubnet)
+	if err != nil {
+		return false
+	}
+	ip := clientip.RemoteIP(r)
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" && ip != nil {
+		proxies, _ := clientip.ParseCIDRs(cfg.TrustedProxies)
+		for _, proxy := range proxies {
+			if proxy.Contains(ip) {
+				ip = net.ParseIP(realIP)
+				break
+			}
+		}
+	}
+	return ip != nil && network.Contains(ip)
+}
+
+// stats возвращает показатели основного хранилища: базы данных, файла или памяти.
+func (h *URLHandler) stats(r *http.Request) (store.Stats, error) {
+	switch {
+	case h.storages.Postgres != nil:
+		return h.storages.Postgres.Stats(r.Context())
+	case h.storages.SQLite != nil:
+		return h.storages.SQLite.Stats(r.Context())
+	case h.storages.Bolt != nil:
+		return h.storages.Bolt.Stats(r.Context())
+	case h.storages.Sharded != nil:
+		return h.storages.Sharded.Stats(r.Context())
+	case h.storages.FileStorage != nil:
+		return h.storages.FileStorage.Stats(r.Context())
+	case h.storages.Memory != nil:
+		return h.storages.Memory.Stats(r.Context())
+	}
+	return store.Stats{}, nil
+}
+
+func (h *URLHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	if !h.fromTrustedSubnet(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	stats, err := h.stats(r)
+	if err != nil {
+		h.log(r.Context()).Error("Stats error", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
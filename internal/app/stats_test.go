@@ -0,0 +1,59 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGetStats(t *testing.T) {
+	storages := Storages{Memory: store.NewInMemoryStorage()}
+	ctx := context.Background()
+	require.NoError(t, storages.Memory.Save(ctx, "user1", "https://a.example", "https://a.example", "key1"))
+	require.NoError(t, storages.Memory.Save(ctx, "user1", "https://b.example", "https://b.example", "key2"))
+	require.NoError(t, storages.Memory.Save(ctx, "user2", "https://c.example", "https://c.example", "key3"))
+
+	tests := []struct {
+		name       string
+		subnet     string
+		proxies    string
+		remoteAddr string
+		realIP     string
+		wantStatus int
+	}{
+		{name: "Subnet not configured", remoteAddr: "10.0.0.5:1234", wantStatus: http.StatusForbidden},
+		{name: "X-Real-IP from untrusted peer", subnet: "192.168.1.0/24", remoteAddr: "10.0.0.5:1234", realIP: "192.168.1.7", wantStatus: http.StatusForbidden},
+		{name: "X-Real-IP through trusted proxy", subnet: "192.168.1.0/24", proxies: "10.0.0.0/8", remoteAddr: "10.0.0.5:1234", realIP: "192.168.1.7", wantStatus: http.StatusOK},
+		{name: "X-Real-IP outside subnet", subnet: "192.168.1.0/24", proxies: "192.168.1.0/24", remoteAddr: "192.168.1.7:1234", realIP: "10.0.0.5", wantStatus: http.StatusForbidden},
+		{name: "Remote address inside subnet", subnet: "10.0.0.0/8", remoteAddr: "10.0.0.5:1234", wantStatus: http.StatusOK},
+		{name: "Malformed X-Real-IP", subnet: "10.0.0.0/8", proxies: "10.0.0.0/8", remoteAddr: "10.0.0.5:1234", realIP: "garbage", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.TrustedSubnet = tt.subnet
+			cfg.TrustedProxies = tt.proxies
+			handler := NewURLHandler(&cfg, &storages, zap.NewNop())
+
+			req := httptest.NewRequest("GET", "/api/internal/stats", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			rr := httptest.NewRecorder()
+			handler.GetStats(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.JSONEq(t, `{"urls":3,"users":2}`, rr.Body.String())
+			}
+		})
+	}
+}
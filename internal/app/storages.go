@@ -0,0 +1,125 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/store"
+	"go.uber.org/zap"
+)
+
+// OpenStorages подключает хранилища из конфигурации. Недоступная база отключается
+// с предупреждением в журнале, и ссылки хранятся в файле или в памяти.
+func OpenStorages(cfg *config.Config, logger *zap.Logger) *Storages {
+	storages := &Storages{Memory: store.NewInMemoryStorage()}
+	if cfg.FileName != "" {
+		storages.FileStorage = store.NewFileStorage(cfg.FileName)
+	}
+	if store.IsSQLiteDSN(cfg.DBConnection) {
+		sqlite, err := store.OpenSQLite(cfg.DBConnection)
+		if err != nil {
+			logger.Warn("SQLite storage disabled", zap.Error(err))
+		} else {
+			storages.SQLite = sqlite
+		}
+	} else if store.IsBoltDSN(cfg.DBConnection) {
+		bolt, err := store.OpenBolt(cfg.DBConnection)
+		if err != nil {
+			logger.Warn("Bolt storage disabled", zap.Error(err))
+		} else {
+			storages.Bolt = bolt
+		}
+	} else if dsns := cfg.ShardDSNs(); len(dsns) > 0 {
+		sharded, err := store.OpenShards(dsns, store.PoolConfig{
+			MaxOpenConns:    cfg.DBMaxOpenConns,
+			MaxIdleConns:    cfg.DBMaxIdleConns,
+			ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		})
+		if err != nil {
+			logger.Warn("Sharded storage disabled", zap.Error(err))
+		} else {
+			storages.Sharded = sharded
+		}
+	} else if cfg.DBConnection != "" {
+		db, err := store.CreateDBConnection(cfg.DBConnection, store.PoolConfig{
+			MaxOpenConns:    cfg.DBMaxOpenConns,
+			MaxIdleConns:    cfg.DBMaxIdleConns,
+			ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		})
+		if err != nil {
+			logger.Warn("DB storage disabled", zap.Error(err))
+		} else {
+			if err := db.Ping(); err != nil {
+				logger.Warn("DB connection failed", zap.Error(err))
+			} else {
+				storages.Postgres = store.NewPostgresStorage(db)
+				openReplicas(cfg, storages.Postgres, logger)
+			}
+		}
+	}
+	return storages
+}
+
+// Primary возвращает основное хранилище: базу данных, файл или память — то, из которого
+// берётся статистика.
+func (s *Storages) Primary() store.Transferable {
+	switch {
+	case s.Postgres != nil:
+		return s.Postgres
+	case s.SQLite != nil:
+		return s.SQLite
+	case s.Bolt != nil:
+		return s.Bolt
+	case s.Sharded != nil:
+		return s.Sharded
+	case s.FileStorage != nil:
+		return s.FileStorage
+	case s.Memory != nil:
+		return s.Memory
+	}
+	return nil
+}
+
+// ImportTargets возвращает все хранилища, в которые сохраняются новые ссылки, основное первым.
+func (s *Storages) ImportTargets() []store.Transferable {
+	var targets []store.Transferable
+	if s.Postgres != nil {
+		targets = append(targets, s.Postgres)
+	}
+	if s.SQLite != nil {
+		targets = append(targets, s.SQLite)
+	}
+	if s.Bolt != nil {
+		targets = append(targets, s.Bolt)
+	}
+	if s.Sharded != nil {
+		targets = append(targets, s.Sharded)
+	}
+	if s.FileStorage != nil {
+		targets = append(targets, s.FileStorage)
+	}
+	if s.Memory != nil {
+		targets = append(targets, s.Memory)
+	}
+	return targets
+}
+
+// Close закрывает базы данных. Сервер закрывает их хуками жизненного цикла,
+// а этот метод нужен командам, которые работают с хранилищами без сервера.
+func (s *Storages) Close(ctx context.Context) error {
+	var errs []error
+	if s.Postgres != nil {
+		errs = append(errs, s.Postgres.Close(ctx))
+	}
+	if s.SQLite != nil {
+		errs = append(errs, s.SQLite.Close(ctx))
+	}
+	if s.Bolt != nil {
+		errs = append(errs, s.Bolt.Close(ctx))
+	}
+	if s.Sharded != nil {
+		errs = append(errs, s.Sharded.Close(ctx))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dron1337/shortener/internal/certs"
+	"github.com/dron1337/shortener/internal/config"
+)
+
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// newCertificates возвращает источник сертификатов или nil, если TLS выключен.
+func newCertificates(cfg *config.Config) (*certs.Reloader, error) {
+	if !cfg.TLSEnabled() {
+		return nil, nil
+	}
+	if cfg.TLSCertFile != "" {
+		return certs.NewReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	cert, err := certs.SelfSigned(selfSignedHosts(cfg), selfSignedValidity)
+	if err != nil {
+		return nil, err
+	}
+	return certs.NewStatic(cert), nil
+}
+
+func selfSignedHosts(cfg *config.Config) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if host, _, err := net.SplitHostPort(cfg.ServerAddress); err == nil && host != "" {
+		hosts = append(hosts, host)
+	}
+	if u, err := url.Parse(cfg.BaseURL); err == nil && u.Hostname() != "" {
+		hosts = append(hosts, u.Hostname())
+	}
+	return hosts
+}
+
+// redirectToHTTPS перенаправляет запросы на тот же хост по HTTPS на порт основного сервера.
+func redirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
+}
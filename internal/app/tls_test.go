@@ -0,0 +1,29 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsAddr string
+		target    string
+		want      string
+	}{
+		{name: "Custom port", httpsAddr: ":8443", target: "http://sho.rt:8080/abc?x=1", want: "https://sho.rt:8443/abc?x=1"},
+		{name: "Default port", httpsAddr: "0.0.0.0:443", target: "http://sho.rt/abc", want: "https://sho.rt/abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			redirectToHTTPS(tt.httpsAddr).ServeHTTP(rr, httptest.NewRequest("GET", tt.target, nil))
+			assert.Equal(t, http.StatusPermanentRedirect, rr.Code)
+			assert.Equal(t, tt.want, rr.Header().Get("Location"))
+		})
+	}
+}
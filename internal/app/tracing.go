@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"time"
+
+	"github.com/dron1337/shortener/internal/errors"
+	"github.com/dron1337/shortener/internal/tracing"
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const serviceName = "shortener"
+
+// tracingMiddleware открывает серверный спан на запрос, продолжая трассу из traceparent.
+func tracingMiddleware(tracer *tracing.Tracer) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := "unknown"
+			if current := mux.CurrentRoute(r); current != nil {
+				if tpl, err := current.GetPathTemplate(); err == nil {
+					route = tpl
+				}
+			}
+			ctx := tracing.Extract(r.Context(), r.Header)
+			ctx, span := tracer.Start(ctx, r.Method+" "+route, tracing.KindServer)
+			defer span.End()
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			span.SetAttributes("http.request.method", r.Method, "http.route", route, "http.response.status_code", rec.status)
+			if rec.status >= http.StatusInternalServerError {
+				span.SetError(stderrors.New(http.StatusText(rec.status)))
+			}
+		})
+	}
+}
+
+// tracingInterceptor — то же для gRPC; traceparent передаётся в метаданных.
+func tracingInterceptor(tracer *tracing.Tracer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(tracing.TraceparentHeader); len(values) > 0 {
+				if sc, err := tracing.ParseTraceparent(values[0]); err == nil {
+					ctx = tracing.ContextWithRemoteParent(ctx, sc)
+				}
+			}
+		}
+		ctx, span := tracer.Start(ctx, info.FullMethod, tracing.KindServer)
+		defer span.End()
+		resp, err := handler(ctx, req)
+		span.SetAttributes("rpc.system", "grpc", "rpc.method", info.FullMethod, "rpc.grpc.status_code", int(status.Code(err)))
+		span.SetError(err)
+		return resp, err
+	}
+}
+
+// startStorage начинает замер вызова хранилища: дочерний спан и метрику длительности.
+// Отсутствие или удаление ссылки ошибкой операции не считается.
+func (h *URLHandler) startStorage(ctx context.Context, backend, operation string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := h.runtime.Tracer.Start(ctx, "storage "+operation, tracing.KindClient)
+	span.SetAttributes("db.system", backend, "db.operation", operation)
+	return ctx, func(err error) {
+		if err != nil && !stderrors.Is(err, errors.ErrURLNotFound) && !stderrors.Is(err, errors.ErrURLDeleted) {
+			span.SetError(err)
+		}
+		span.End()
+		h.metrics().observeStorage(backend, operation, start)
+	}
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/store"
+	"github.com/dron1337/shortener/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+}
+
+func TestRequestTracing(t *testing.T) {
+	cfg := config.Default()
+	storages := Storages{Memory: store.NewInMemoryStorage()}
+	require.NoError(t, storages.Memory.Save(context.Background(), "user", "https://example.com", "https://example.com", "traced01"))
+	rt, err := NewRuntime(&cfg, &storages, zap.NewNop())
+	require.NoError(t, err)
+	var out bytes.Buffer
+	rt.Tracer = tracing.NewTracer(serviceName, tracing.NewOTLPExporter(serviceName, &out, nil))
+	router := NewRouter(rt, &storages, zap.NewNop())
+
+	req := httptest.NewRequest("GET", "/traced01", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+
+	spans := make(map[string]exportedSpan)
+	for _, line := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		var exported struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		require.NoError(t, json.Unmarshal(line, &exported))
+		span := exported.ResourceSpans[0].ScopeSpans[0].Spans[0]
+		spans[span.Name] = span
+	}
+	server, ok := spans["GET /{key}"]
+	require.True(t, ok, "request span is exported")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", server.ParentSpanID)
+
+	storage, ok := spans["storage get_original_url"]
+	require.True(t, ok, "storage span is exported")
+	assert.Equal(t, server.TraceID, storage.TraceID)
+	assert.Equal(t, server.SpanID, storage.ParentSpanID)
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dron1337/shortener/internal/store"
+	"github.com/dron1337/shortener/internal/transfer"
+	"go.uber.org/zap"
+)
+
+// importBatchSize — число ссылок, которые ImportURLs записывает за раз.
+const importBatchSize = 500
+
+type ImportResponse struct {
+	store.ImportResult
+	Error string `json:"error,omitempty"`
+}
+
+// ExportURLs выгружает все ссылки основного хранилища в формате ?format=jsonl|csv.
+// Ответ пишется по мере чтения и ограничен WriteTimeout сервера, поэтому большие
+// базы удобнее выгружать командой shortener export.
+func (h *URLHandler) ExportURLs(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	format, err := transfer.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		h.writeJSONError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="urls.`+string(format)+`"`)
+	n, err := transfer.Export(r.Context(), h.storages.Primary(), transfer.NewEncoder(w, format))
+	if err != nil {
+		// Статус уже отправлен: клиент увидит оборванную выгрузку
+		h.log(r.Context()).Error("URL export failed", zap.Int("exported", n), zap.Error(err))
+		return
+	}
+	h.log(r.Context()).Info("URLs exported", zap.Int("exported", n))
+}
+
+// ImportURLs загружает ссылки из тела запроса во все хранилища. Ссылки с занятыми
+// ключами пропускаются, а с ?overwrite=true заменяются.
+func (h *URLHandler) ImportURLs(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	format, err := transfer.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		h.writeJSONError(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	overwrite, _ := strconv.ParseBool(r.URL.Query().Get("overwrite"))
+	dec := &keyRecorder{Decoder: transfer.NewDecoder(r.Body, format)}
+	result, err := transfer.Import(r.Context(), dec, h.storages.ImportTargets(), transfer.Options{
+		Overwrite: overwrite,
+		BatchSize: importBatchSize,
+		// Кэш мог запомнить прежнюю ссылку или её отсутствие
+		OnBatch: func(store.ImportResult) {
+			h.invalidate(r.Context(), dec.keys...)
+			dec.keys = dec.keys[:0]
+		},
+	})
+	if len(dec.keys) > 0 {
+		h.invalidate(r.Context(), dec.keys...)
+	}
+	resp := ImportResponse{ImportResult: result}
+	status := http.StatusOK
+	if err != nil {
+		resp.Error = err.Error()
+		status = http.StatusInternalServerError
+		if stderrors.Is(err, transfer.ErrMalformed) {
+			status = http.StatusBadRequest
+		}
+		h.log(r.Context()).Error("URL import failed", zap.Error(err))
+	}
+	h.log(r.Context()).Info("URLs imported", zap.Int("imported", result.Imported),
+		zap.Int("overwritten", result.Overwritten), zap.Int("skipped", result.Skipped))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// keyRecorder запоминает ключи прочитанных ссылок, чтобы сбросить их в кэше.
+type keyRecorder struct {
+	transfer.Decoder
+	keys []string
+}
+
+func (d *keyRecorder) Decode() (store.Record, error) {
+	rec, err := d.Decoder.Decode()
+	if err == nil {
+		d.keys = append(d.keys, rec.ShortKey)
+	}
+	return rec, err
+}
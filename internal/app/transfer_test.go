@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestTransferEndpoints(t *testing.T) {
+	cfg := config.Default()
+	cfg.AdminToken = "secret"
+	bolt, err := store.OpenBolt(store.BoltScheme + filepath.Join(t.TempDir(), "urls.db"))
+	require.NoError(t, err)
+	defer bolt.Close(context.Background())
+	storages := Storages{Bolt: bolt, Memory: store.NewInMemoryStorage()}
+	rt, err := NewRuntime(&cfg, &storages, zap.NewNop())
+	require.NoError(t, err)
+	router := NewRouter(rt, &storages, zap.NewNop())
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	importResult := func(rr *httptest.ResponseRecorder) ImportResponse {
+		var resp ImportResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		return resp
+	}
+
+	csv := "short_key,original_url,canonical_url,user_id,is_deleted\n" +
+		"imported,https://example.com/live,,user-1,false\n" +
+		"gone1234,https://example.com/gone,,user-1,true\n"
+	rr := do("POST", "/api/admin/import?format=csv", csv)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, store.ImportResult{Imported: 2}, importResult(rr).ImportResult)
+
+	rr = do("GET", "/imported", "")
+	assert.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+	assert.Equal(t, "https://example.com/live", rr.Header().Get("Location"))
+	assert.Equal(t, http.StatusGone, do("GET", "/gone1234", "").Code)
+
+	rr = do("POST", "/api/admin/import?format=csv", csv)
+	assert.Equal(t, store.ImportResult{Skipped: 2}, importResult(rr).ImportResult)
+
+	rr = do("POST", "/api/admin/import?overwrite=true",
+		`{"short_key":"imported","original_url":"https://example.com/new","user_id":"user-2","is_deleted":false}`+"\n{broken")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, store.ImportResult{}, importResult(rr).ImportResult, "пачка с ошибкой не записывается")
+	assert.Equal(t, "https://example.com/live", do("GET", "/imported", "").Header().Get("Location"))
+
+	rr = do("POST", "/api/admin/import?overwrite=true",
+		`{"short_key":"imported","original_url":"https://example.com/new","user_id":"user-2","is_deleted":false}`)
+	assert.Equal(t, store.ImportResult{Overwritten: 1}, importResult(rr).ImportResult)
+	assert.Equal(t, "https://example.com/new", do("GET", "/imported", "").Header().Get("Location"))
+
+	rr = do("GET", "/api/admin/export?format=jsonl", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	var rec store.Record
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &rec))
+	assert.True(t, rec.IsDeleted)
+	assert.Equal(t, "gone1234", rec.ShortKey)
+
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/api/admin/export?format=xml", "").Code)
+	req := httptest.NewRequest("GET", "/api/admin/export", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
@@ -0,0 +1,280 @@
+package app
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dron1337/shortener/internal/auth"
+	"github.com/dron1337/shortener/internal/errors"
+	"github.com/dron1337/shortener/internal/store"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type WorkspaceRequest struct {
+	Name string `json:"name"`
+}
+type MemberRequest struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+type TransferResponse struct {
+	Moved int `json:"moved"`
+}
+
+// workspaceStorage возвращает хранилище рабочих пространств: базу данных, если она подключена, иначе память.
+func (h *URLHandler) workspaceStorage() store.WorkspaceStorage {
+	if h.storages.Postgres != nil {
+		return h.storages.Postgres
+	}
+	if h.storages.SQLite != nil {
+		return h.storages.SQLite
+	}
+	if h.storages.Bolt != nil {
+		return h.storages.Bolt
+	}
+	if h.storages.Sharded != nil {
+		return h.storages.Sharded
+	}
+	if h.storages.Memory != nil {
+		return h.storages.Memory
+	}
+	return nil
+}
+
+// authorize проверяет роль пользователя в пространстве из пути запроса и при отказе сам пишет ответ.
+func (h *URLHandler) authorize(w http.ResponseWriter, r *http.Request, required store.Role) (string, bool) {
+	workspaceID := mux.Vars(r)["id"]
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	ws := h.workspaceStorage()
+	if ws == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return "", false
+	}
+	role, err := ws.GetMemberRole(r.Context(), workspaceID, userID)
+	if err != nil {
+		h.writeWorkspaceError(w, r, err)
+		return "", false
+	}
+	if !role.Allows(required) {
+		w.WriteHeader(http.StatusForbidden)
+		return "", false
+	}
+	return workspaceID, true
+}
+
+func (h *URLHandler) writeWorkspaceError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case stderrors.Is(err, errors.ErrWorkspaceNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case stderrors.Is(err, errors.ErrNotWorkspaceMember):
+		w.WriteHeader(http.StatusForbidden)
+	default:
+		h.log(r.Context()).Error("Workspace storage error", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *URLHandler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	w.Header().Set("Content-Type", "application/json")
+	var req WorkspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	ws := h.workspaceStorage()
+	if ws == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	workspace, err := ws.CreateWorkspace(r.Context(), userID, strings.TrimSpace(req.Name))
+	if err != nil {
+		h.writeWorkspaceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(workspace)
+}
+
+func (h *URLHandler) GetWorkspaceMembers(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.authorize(w, r, store.RoleViewer)
+	if !ok {
+		return
+	}
+	members, err := h.workspaceStorage().GetWorkspaceMembers(r.Context(), workspaceID)
+	if err != nil {
+		h.writeWorkspaceError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+func (h *URLHandler) SetWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.authorize(w, r, store.RoleAdmin)
+	if !ok {
+		return
+	}
+	var req MemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	role, err := store.ParseRole(req.Role)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ws := h.workspaceStorage()
+	if role != store.RoleAdmin && h.isLastAdmin(r, ws, workspaceID, req.UserID) {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	if err := ws.SetWorkspaceMember(r.Context(), workspaceID, req.UserID, role); err != nil {
+		h.writeWorkspaceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *URLHandler) RemoveWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.authorize(w, r, store.RoleAdmin)
+	if !ok {
+		return
+	}
+	memberID := mux.Vars(r)["userID"]
+	ws := h.workspaceStorage()
+	if h.isLastAdmin(r, ws, workspaceID, memberID) {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	if err := ws.RemoveWorkspaceMember(r.Context(), workspaceID, memberID); err != nil {
+		if stderrors.Is(err, errors.ErrNotWorkspaceMember) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		h.writeWorkspaceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isLastAdmin не даёт лишить пространство последнего администратора.
+func (h *URLHandler) isLastAdmin(r *http.Request, ws store.WorkspaceStorage, workspaceID, userID string) bool {
+	members, err := ws.GetWorkspaceMembers(r.Context(), workspaceID)
+	if err != nil {
+		return false
+	}
+	admins := 0
+	isAdmin := false
+	for _, m := range members {
+		if m.Role == store.RoleAdmin {
+			admins++
+			if m.UserID == userID {
+				isAdmin = true
+			}
+		}
+	}
+	return isAdmin && admins == 1
+}
+
+func (h *URLHandler) GenerateWorkspaceURL(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.authorize(w, r, store.RoleEditor)
+	if !ok {
+		return
+	}
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	w.Header().Set("Content-Type", "application/json")
+	var data RequestData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil || data.URL == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if _, err := url.ParseRequestURI(data.URL); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if reason := h.policyViolation(r.Context(), data.URL); reason != "" {
+		h.writeJSONError(w, http.StatusUnprocessableEntity, ErrorResponse{Error: reason})
+		return
+	}
+	shortURL, err := h.newShortKey(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := h.workspaceStorage().SaveToWorkspace(r.Context(), workspaceID, userID, data.URL, h.canonicalURL(data.URL), shortURL); err != nil {
+		h.writeWorkspaceError(w, r, err)
+		return
+	}
+	h.invalidate(r.Context(), shortURL)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ResponseData{Result: fmt.Sprintf("%s/%s", h.conf().BaseURL, shortURL)})
+}
+
+func (h *URLHandler) GetWorkspaceURLs(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.authorize(w, r, store.RoleViewer)
+	if !ok {
+		return
+	}
+	urls, err := h.workspaceStorage().GetWorkspaceURLs(r.Context(), workspaceID, h.conf().BaseURL)
+	if err != nil {
+		h.writeWorkspaceError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if len(urls) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	json.NewEncoder(w).Encode(urls)
+}
+
+func (h *URLHandler) DeleteWorkspaceURLs(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.authorize(w, r, store.RoleEditor)
+	if !ok {
+		return
+	}
+	var keys []string
+	if err := json.NewDecoder(r.Body).Decode(&keys); err != nil || len(keys) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := h.workspaceStorage().DeleteWorkspaceURLs(r.Context(), workspaceID, keys); err != nil {
+		h.writeWorkspaceError(w, r, err)
+		return
+	}
+	h.invalidate(r.Context(), keys...)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// TransferWorkspaceURLs переносит ссылки текущего пользователя в пространство.
+func (h *URLHandler) TransferWorkspaceURLs(w http.ResponseWriter, r *http.Request) {
+	workspaceID, ok := h.authorize(w, r, store.RoleEditor)
+	if !ok {
+		return
+	}
+	userID := r.Context().Value(auth.UserIDKey).(string)
+	var keys []string
+	if err := json.NewDecoder(r.Body).Decode(&keys); err != nil || len(keys) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	moved, err := h.workspaceStorage().TransferURLs(r.Context(), userID, workspaceID, keys)
+	if err != nil {
+		h.writeWorkspaceError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TransferResponse{Moved: moved})
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dron1337/shortener/internal/auth"
+	"github.com/dron1337/shortener/internal/config"
+	"github.com/dron1337/shortener/internal/store"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWorkspaceHandlers(t *testing.T) {
+	cfg := &config.Config{BaseURL: "http://test.example"}
+	storages := Storages{Memory: store.NewInMemoryStorage()}
+	handler := NewURLHandler(cfg, &storages, zap.NewNop())
+	router := mux.NewRouter()
+	router.HandleFunc("/api/workspaces", handler.CreateWorkspace).Methods("POST")
+	router.HandleFunc("/api/workspaces/{id}/members", handler.SetWorkspaceMember).Methods("PUT")
+	router.HandleFunc("/api/workspaces/{id}/members/{userID}", handler.RemoveWorkspaceMember).Methods("DELETE")
+	router.HandleFunc("/api/workspaces/{id}/urls", handler.GetWorkspaceURLs).Methods("GET")
+	router.HandleFunc("/api/workspaces/{id}/urls", handler.GenerateWorkspaceURL).Methods("POST")
+	router.HandleFunc("/api/workspaces/{id}/urls", handler.DeleteWorkspaceURLs).Methods("DELETE")
+	router.HandleFunc("/api/workspaces/{id}/urls/transfer", handler.TransferWorkspaceURLs).Methods("POST")
+	router.HandleFunc("/{key}", handler.GetURL).Methods("GET")
+
+	do := func(method, path, userID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserIDKey, userID))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do("POST", "/api/workspaces", "admin", `{"name":"team"}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var ws store.Workspace
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&ws))
+	base := "/api/workspaces/" + ws.ID
+
+	t.Run("Non-member is forbidden", func(t *testing.T) {
+		rr := do("GET", base+"/urls", "stranger", "")
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("Unknown workspace", func(t *testing.T) {
+		rr := do("GET", "/api/workspaces/missing/urls", "admin", "")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Viewer cannot create links", func(t *testing.T) {
+		rr := do("PUT", base+"/members", "admin", `{"user_id":"viewer","role":"viewer"}`)
+		require.Equal(t, http.StatusNoContent, rr.Code)
+		rr = do("POST", base+"/urls", "viewer", `{"url":"https://example.com"}`)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("Editor creates and viewer lists", func(t *testing.T) {
+		rr := do("PUT", base+"/members", "admin", `{"user_id":"editor","role":"editor"}`)
+		require.Equal(t, http.StatusNoContent, rr.Code)
+		rr = do("POST", base+"/urls", "editor", `{"url":"https://example.com/team"}`)
+		require.Equal(t, http.StatusCreated, rr.Code)
+
+		rr = do("GET", base+"/urls", "viewer", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		var urls []store.ResponseURLs
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&urls))
+		require.Len(t, urls, 1)
+		assert.Equal(t, "https://example.com/team", urls[0].OriginalURL)
+
+		key := strings.TrimPrefix(urls[0].ShortURL, cfg.BaseURL+"/")
+		rr = do("DELETE", base+"/urls", "viewer", `["`+key+`"]`)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Equal(t, http.StatusTemporaryRedirect, do("GET", "/"+key, "viewer", "").Code)
+		rr = do("DELETE", base+"/urls", "editor", `["`+key+`"]`)
+		assert.Equal(t, http.StatusAccepted, rr.Code)
+		rr = do("GET", base+"/urls", "viewer", "")
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		assert.Equal(t, http.StatusGone, do("GET", "/"+key, "viewer", "").Code, "удалённая ссылка отвечает 410, как в Postgres")
+	})
+
+	t.Run("Transfer own links", func(t *testing.T) {
+		require.NoError(t, storages.Memory.Save(context.Background(), "editor", "https://example.com/own", "https://example.com/own", "own00001"))
+		require.NoError(t, storages.Memory.Save(context.Background(), "someone", "https://example.com/foreign", "https://example.com/foreign", "foreign1"))
+		rr := do("POST", base+"/urls/transfer", "editor", `["own00001","foreign1"]`)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"moved":1}`, rr.Body.String())
+	})
+
+	t.Run("Invalid role", func(t *testing.T) {
+		rr := do("PUT", base+"/members", "admin", `{"user_id":"x","role":"owner"}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Last admin cannot leave", func(t *testing.T) {
+		rr := do("DELETE", base+"/members/admin", "admin", "")
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+}
@@ -2,52 +2,114 @@ package auth
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/dron1337/shortener/internal/logger"
 	"github.com/google/uuid"
 	"github.com/gorilla/securecookie"
+	"go.uber.org/zap"
 )
 
 var (
 	hashKey  = securecookie.GenerateRandomKey(64)
 	blockKey = securecookie.GenerateRandomKey(32)
 	s        = securecookie.New(hashKey, blockKey)
+	cookie   = Options{Name: "session", MaxAge: 24 * time.Hour}
 )
 
+// Options — параметры сессионной куки. Ключи задаются в hex; если ключ пуст,
+// используется случайный, и куки перестают читаться после перезапуска.
+type Options struct {
+	Name     string
+	MaxAge   time.Duration
+	Secure   bool
+	HashKey  string
+	BlockKey string
+}
+
+// Initialize настраивает сессионную куку.
+func Initialize(opts Options) error {
+	hk, err := decodeKey(opts.HashKey, 64)
+	if err != nil {
+		return fmt.Errorf("invalid cookie hash key: %w", err)
+	}
+	if len(hk) < 32 {
+		return fmt.Errorf("invalid cookie hash key: must be at least 32 bytes")
+	}
+	bk, err := decodeKey(opts.BlockKey, 32)
+	if err != nil {
+		return fmt.Errorf("invalid cookie block key: %w", err)
+	}
+	if len(bk) != 16 && len(bk) != 24 && len(bk) != 32 {
+		return fmt.Errorf("invalid cookie block key: must be 16, 24 or 32 bytes")
+	}
+	if opts.Name == "" {
+		opts.Name = "session"
+	}
+	hashKey, blockKey = hk, bk
+	s = securecookie.New(hashKey, blockKey)
+	cookie = opts
+	return nil
+}
+
+func decodeKey(key string, size int) ([]byte, error) {
+	if key == "" {
+		return securecookie.GenerateRandomKey(size), nil
+	}
+	return hex.DecodeString(key)
+}
+
+// UserIDFromToken извлекает ID пользователя из значения сессионной куки.
+func UserIDFromToken(token string) (string, bool) {
+	var sessionData map[string]string
+	if err := s.Decode(cookie.Name, token, &sessionData); err != nil {
+		return "", false
+	}
+	userID, exists := sessionData["user_id"]
+	return userID, exists && userID != ""
+}
+
+// NewToken создаёт нового пользователя и подписанный токен для него.
+func NewToken() (userID, token string, err error) {
+	userID = generateUserID()
+	token, err = s.Encode(cookie.Name, map[string]string{"user_id": userID})
+	return userID, token, err
+}
+
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var userID string
+		isNew := false
 		// 1. Пытаемся получить и декодировать куку
-		if cookie, err := r.Cookie("session"); err == nil {
-			var sessionData map[string]string
-			if err := s.Decode("session", cookie.Value, &sessionData); err == nil {
-				if id, exists := sessionData["user_id"]; exists {
-					userID = id
-				}
-			}
+		if c, err := r.Cookie(cookie.Name); err == nil {
+			userID, _ = UserIDFromToken(c.Value)
 		}
 
 		// 2. Если куки нет или она невалидна - создаем новую
 		if userID == "" {
-			userID = generateUserID() // Ваша функция генерации ID
-			value := map[string]string{"user_id": userID}
-			if encoded, err := s.Encode("session", value); err == nil {
-				cookie := &http.Cookie{
-					Name:     "session",
+			id, encoded, err := NewToken()
+			userID = id
+			isNew = true
+			if err == nil {
+				http.SetCookie(w, &http.Cookie{
+					Name:     cookie.Name,
 					Value:    encoded,
 					Path:     "/",
 					HttpOnly: true,
-					MaxAge:   86400, // 1 день
-				}
-				http.SetCookie(w, cookie)
+					Secure:   cookie.Secure,
+					MaxAge:   int(cookie.MaxAge.Seconds()),
+				})
 			} else {
-				fmt.Printf("Ошибка кодирования куки: %v", err)
+				logger.FromContext(r.Context(), zap.NewNop()).Error("Failed to encode session cookie", zap.Error(err))
 			}
 		}
 
 		// 3. Добавляем userID в контекст запроса
 		ctx := context.WithValue(r.Context(), UserIDKey, userID)
+		ctx = context.WithValue(ctx, NewUserKey, isNew)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
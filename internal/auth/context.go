@@ -4,4 +4,6 @@ type ContextKey string
 
 const (
 	UserIDKey ContextKey = "userID"
+	// NewUserKey помечает запросы, для которых ID пользователя сгенерирован только что.
+	NewUserKey ContextKey = "newUser"
 )
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataKey — ключ метаданных gRPC с токеном пользователя (значением сессионной куки).
+const MetadataKey = "token"
+
+// UnaryServerInterceptor — аналог AuthMiddleware для gRPC: кладёт ID пользователя
+// в контекст, а новому пользователю возвращает токен в заголовке ответа.
+func UnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var userID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if tokens := md.Get(MetadataKey); len(tokens) > 0 {
+			userID, _ = UserIDFromToken(tokens[0])
+		}
+	}
+	isNew := false
+	if userID == "" {
+		id, token, err := NewToken()
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to issue token")
+		}
+		if err := grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, token)); err != nil {
+			return nil, err
+		}
+		userID = id
+		isNew = true
+	}
+	ctx = context.WithValue(ctx, UserIDKey, userID)
+	ctx = context.WithValue(ctx, NewUserKey, isNew)
+	return handler(ctx, req)
+}
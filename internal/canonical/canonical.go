@@ -0,0 +1,210 @@
+package canonical
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+type Options struct {
+	// SortQuery сортирует параметры запроса по имени.
+	SortQuery bool
+	// StripTracking удаляет рекламные и трекинговые параметры (utm_*, fbclid и т.п.).
+	StripTracking bool
+}
+
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"yclid":   true,
+	"msclkid": true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"_gl":     true,
+	"igshid":  true,
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Canonicalize приводит URL к каноническому виду, чтобы одинаковые адреса,
+// записанные по-разному, получали один и тот же короткий ключ.
+func Canonicalize(raw string, opts Options) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Opaque != "" {
+		return u.String(), nil
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host != "" && net.ParseIP(host) == nil {
+		ascii, err := idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", fmt.Errorf("invalid host %q: %w", host, err)
+		}
+		host = ascii
+	}
+	port := u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	// Путь разбирается в закодированном виде: %2F внутри сегмента — не разделитель,
+	// и сервер отличает /x%2Fy от /x/y. RawPath хранит исходную запись, даже если
+	// она закодирована неправильно, а EscapedPath в этом случае её бы отбросил.
+	rawPath := u.RawPath
+	if rawPath == "" {
+		rawPath = u.EscapedPath()
+	}
+	u.RawPath = normalizePath(rawPath)
+	if u.Path, err = url.PathUnescape(u.RawPath); err != nil {
+		return "", err
+	}
+	if u.Host != "" && u.Path == "" {
+		u.Path = "/"
+	}
+
+	u.RawQuery = normalizeQuery(u.RawQuery, opts)
+	u.ForceQuery = false
+	if u.Fragment == "" {
+		u.RawFragment = ""
+	}
+	return u.String(), nil
+}
+
+// normalizePath приводит к одному виду каждый сегмент закодированного пути, убирает
+// сегменты "." и ".." и повторные слэши, сохраняя завершающий слэш.
+func normalizePath(p string) string {
+	if p == "" {
+		return ""
+	}
+	trailing := strings.HasSuffix(p, "/")
+	var out []string
+	for _, seg := range strings.Split(p, "/") {
+		switch seg = normalizeSegment(seg); seg {
+		case "", ".":
+		case "..":
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+	result := "/" + strings.Join(out, "/")
+	if trailing && len(out) > 0 {
+		result += "/"
+	}
+	return result
+}
+
+// normalizeSegment декодирует незарезервированные символы, записывает остальные
+// %XX заглавными буквами и кодирует всё, что нельзя оставить в пути как есть.
+// Закодированный символ остаётся закодированным: %2F и %3B значат не то же, что "/" и ";".
+func normalizeSegment(seg string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(seg); i++ {
+		c := seg[i]
+		if c == '%' && i+2 < len(seg) && isHex(seg[i+1]) && isHex(seg[i+2]) {
+			c = unhex(seg[i+1])<<4 | unhex(seg[i+2])
+			i += 2
+			if isUnreserved(c) {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('%')
+				b.WriteByte(hex[c>>4])
+				b.WriteByte(hex[c&15])
+			}
+			continue
+		}
+		if isUnreserved(c) || strings.IndexByte("$&+,:;=@", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&15])
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+func normalizeQuery(rawQuery string, opts Options) string {
+	if rawQuery == "" {
+		return ""
+	}
+	type pair struct {
+		key, value string
+		hasValue   bool
+	}
+	var pairs []pair
+	for _, part := range strings.Split(rawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		key, value, hasValue := strings.Cut(part, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if opts.StripTracking && isTracking(name) {
+			continue
+		}
+		pairs = append(pairs, pair{key: key, value: value, hasValue: hasValue})
+	}
+	if opts.SortQuery {
+		sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].key < pairs[j].key })
+	}
+	parts := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		if p.hasValue {
+			parts = append(parts, p.key+"="+p.value)
+		} else {
+			parts = append(parts, p.key)
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func isTracking(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasPrefix(name, "utm_") || trackingParams[name]
+}
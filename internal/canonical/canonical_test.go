@@ -0,0 +1,52 @@
+package canonical
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	opts := Options{StripTracking: true}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"HTTP://Example.com/", "http://example.com/"},
+		{"http://example.com", "http://example.com/"},
+		{"http://example.com/?", "http://example.com/"},
+		{"http://example.com:80/a", "http://example.com/a"},
+		{"https://example.com:443/a", "https://example.com/a"},
+		{"https://example.com:8443/a", "https://example.com:8443/a"},
+		{"http://example.com/a/./b/../c", "http://example.com/a/c"},
+		{"http://example.com//a//b/", "http://example.com/a/b/"},
+		{"https://a.com/x%2Fy", "https://a.com/x%2Fy"},
+		{"https://a.com/x%2fy/./z%20w", "https://a.com/x%2Fy/z%20w"},
+		{"https://a.com/a/x%2F..%2Fy/../b", "https://a.com/a/b"},
+		{"https://a.com/%7Euser", "https://a.com/~user"},
+		{"https://a.com/x%2Fy/é", "https://a.com/x%2Fy/%C3%A9"},
+		{"https://a.com/x%2fy/%c3%a9", "https://a.com/x%2Fy/%C3%A9"},
+		{"https://a.com/x%2Fy/%C3%A9", "https://a.com/x%2Fy/%C3%A9"},
+		{"https://a.com/x%2Fy/%7Euser", "https://a.com/x%2Fy/~user"},
+		{"https://a.com/a%3bb/c;d/%2e%2E/e", "https://a.com/a%3Bb/e"},
+		{"https://a.com/x%2Fy/(z)", "https://a.com/x%2Fy/%28z%29"},
+		{"http://пример.рф/путь", "http://xn--e1afmkfd.xn--p1ai/%D0%BF%D1%83%D1%82%D1%8C"},
+		{"http://example.com/?utm_source=x&id=1&fbclid=abc", "http://example.com/?id=1"},
+		{"http://example.com/?b=2&a=1", "http://example.com/?b=2&a=1"},
+		{"http://example.com/?flag", "http://example.com/?flag"},
+		{"http://[::1]:80/", "http://[::1]/"},
+		{"http://example.com/#Section", "http://example.com/#Section"},
+	}
+	for _, tt := range tests {
+		got, err := Canonicalize(tt.in, opts)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+}
+
+func TestCanonicalizeSortQuery(t *testing.T) {
+	got, err := Canonicalize("http://example.com/?b=2&a=1&utm_medium=x", Options{SortQuery: true})
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/?a=1&b=2&utm_medium=x", got)
+}
@@ -0,0 +1,170 @@
+// Package certs загружает TLS-сертификаты и следит за их обновлением.
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reloader отдаёт текущий сертификат для TLS-рукопожатий. Новый сертификат
+// подхватывается без перезапуска сервера, уже открытые соединения не рвутся.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+
+	mu       sync.Mutex
+	modTimes [2]time.Time
+}
+
+// NewReloader загружает пару сертификат/ключ из файлов.
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewStatic возвращает Reloader с неизменяемым сертификатом, например самоподписанным.
+func NewStatic(cert tls.Certificate) *Reloader {
+	r := &Reloader{}
+	r.cert.Store(&cert)
+	return r
+}
+
+// Reload перечитывает файлы. При ошибке продолжает использоваться прежний сертификат.
+func (r *Reloader) Reload() error {
+	if r.certFile == "" {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	modTimes := r.stat()
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS certificate: %w", err)
+	}
+	r.cert.Store(&cert)
+	r.modTimes = modTimes
+	return nil
+}
+
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Watch периодически проверяет время изменения файлов и перечитывает их.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	if r.certFile == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (r *Reloader) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stat() != r.modTimes
+}
+
+func (r *Reloader) stat() [2]time.Time {
+	var modTimes [2]time.Time
+	for i, path := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(path); err == nil {
+			modTimes[i] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// TLSConfig возвращает настройки TLS сервера с поддержкой HTTP/2.
+func TLSConfig(r *Reloader) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+}
+
+// GenerateSelfSigned создаёт самоподписанный сертификат для хостов в формате PEM.
+// Предназначен только для разработки.
+func GenerateSelfSigned(hosts []string, validFor time.Duration) (certPEM, keyPEM []byte, err error) {
+	if len(hosts) == 0 {
+		return nil, nil, errors.New("no hosts for self-signed certificate")
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"shortener development"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// SelfSigned создаёт самоподписанный сертификат, готовый для tls.Config.
+func SelfSigned(hosts []string, validFor time.Duration) (tls.Certificate, error) {
+	certPEM, keyPEM, err := GenerateSelfSigned(hosts, validFor)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
@@ -0,0 +1,80 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCert(t *testing.T, dir string) (certFile, keyFile string, certPEM []byte) {
+	t.Helper()
+	certPEM, keyPEM, err := GenerateSelfSigned([]string{"127.0.0.1", "localhost"}, time.Hour)
+	require.NoError(t, err)
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	return certFile, keyFile, certPEM
+}
+
+func TestReloaderServesHTTP2AndReloads(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, firstPEM := writeCert(t, dir)
+	reloader, err := NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{
+		TLSConfig: TLSConfig(reloader),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	}
+	go srv.ServeTLS(ln, "", "")
+	defer srv.Close()
+
+	get := func(trusted []byte) *http.Response {
+		pool := x509.NewCertPool()
+		require.True(t, pool.AppendCertsFromPEM(trusted))
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: pool},
+			ForceAttemptHTTP2: true,
+		}}
+		resp, err := client.Get("https://" + ln.Addr().String())
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := get(firstPEM)
+	assert.Equal(t, 2, resp.ProtoMajor)
+	first := resp.TLS.PeerCertificates[0].SerialNumber
+
+	_, _, secondPEM := writeCert(t, dir)
+	require.NoError(t, reloader.Reload())
+	resp = get(secondPEM)
+	assert.NotEqual(t, first, resp.TLS.PeerCertificates[0].SerialNumber)
+}
+
+func TestReloadKeepsCertificateOnError(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeCert(t, dir)
+	reloader, err := NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+	before, _ := reloader.GetCertificate(nil)
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("garbage"), 0o600))
+	assert.True(t, reloader.changed())
+	assert.Error(t, reloader.Reload())
+	after, _ := reloader.GetCertificate(nil)
+	assert.Same(t, before, after)
+}
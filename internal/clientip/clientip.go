@@ -0,0 +1,90 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Resolver определяет IP клиента, доверяя заголовкам прокси только от доверенных адресов.
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+func NewResolver(trusted []*net.IPNet) *Resolver {
+	return &Resolver{trusted: trusted}
+}
+
+// ParseCIDRs разбирает список подсетей через запятую. Одиночный IP трактуется как /32 или /128.
+func ParseCIDRs(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", item, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// ClientIP возвращает адрес клиента. Если запрос пришёл от доверенного прокси,
+// берётся крайний справа недоверенный адрес из X-Forwarded-For либо X-Real-IP.
+func (res *Resolver) ClientIP(r *http.Request) net.IP {
+	remote := RemoteIP(r)
+	if remote == nil || !res.isTrusted(remote) {
+		return remote
+	}
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break
+			}
+			if !res.isTrusted(ip) || i == 0 {
+				return ip
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip
+	}
+	return remote
+}
+
+func (res *Resolver) isTrusted(ip net.IP) bool {
+	for _, n := range res.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoteIP возвращает IP непосредственного отправителя запроса.
+func RemoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,45 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseCIDRs("10.0.0.0/8, 192.168.1.1")
+	require.NoError(t, err)
+	res := NewResolver(trusted)
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		realIP string
+		want   string
+	}{
+		{"Direct client", "203.0.113.5:1234", "", "", "203.0.113.5"},
+		{"Untrusted proxy headers ignored", "203.0.113.5:1234", "1.1.1.1", "2.2.2.2", "203.0.113.5"},
+		{"Trusted proxy with XFF", "10.1.2.3:80", "198.51.100.7, 10.0.0.2", "", "198.51.100.7"},
+		{"Spoofed XFF prefix", "10.1.2.3:80", "6.6.6.6, 198.51.100.7", "", "198.51.100.7"},
+		{"Trusted proxy with X-Real-IP", "192.168.1.1:80", "", "198.51.100.9", "198.51.100.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			assert.Equal(t, tt.want, res.ClientIP(req).String())
+		})
+	}
+
+	_, err = ParseCIDRs("not-a-cidr")
+	assert.Error(t, err)
+}
@@ -1,61 +1,211 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"log"
-	"net/url"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	ServerAddress string
-	BaseURL       string
-	FileName      string
-	DBConnection  string
-}
-
-func LoadConfig() (*Config, error) {
-	// Значения по умолчанию
-	cfg := Config{
-		ServerAddress: "localhost:8080",
-		BaseURL:       "http://localhost:8080",
-	}
-	flagAddr := flag.String("a", "", "HTTP server address")
-	flagBase := flag.String("b", "", "Base URL for shortened URLs")
-	flagFileName := flag.String("f", "", "File name")
-	flagFDBConnection := flag.String("d", "", "DB Connection")
-	flag.Parse()
-	err := godotenv.Load()
-	if err != nil {
-		log.Printf("Error loading .env file: %v", err)
+	ConfigFile              string        `yaml:"-"`
+	ServerAddress           string        `yaml:"server_address"`
+	BaseURL                 string        `yaml:"base_url"`
+	FileName                string        `yaml:"file_storage_path"`
+	DBConnection            string        `yaml:"database_dsn"`
+	RateLimitShorten        string        `yaml:"rate_limit_shorten"`
+	RateLimitRedirect       string        `yaml:"rate_limit_redirect"`
+	RateLimitAPI            string        `yaml:"rate_limit_api"`
+	RateLimitStore          string        `yaml:"rate_limit_store"`
+	TrustedProxies          string        `yaml:"trusted_proxies"`
+	URLSchemes              string        `yaml:"url_schemes"`
+	URLMaxLength            int           `yaml:"url_max_length"`
+	URLBlocklistFile        string        `yaml:"url_blocklist_file"`
+	URLAllowlistFile        string        `yaml:"url_allowlist_file"`
+	URLAllowPrivate         bool          `yaml:"url_allow_private"`
+	URLResolveHosts         bool          `yaml:"url_resolve_hosts"`
+	URLSortQuery            bool          `yaml:"url_sort_query"`
+	URLStripTracking        bool          `yaml:"url_strip_tracking"`
+	ReadTimeout             time.Duration `yaml:"server_read_timeout"`
+	WriteTimeout            time.Duration `yaml:"server_write_timeout"`
+	IdleTimeout             time.Duration `yaml:"server_idle_timeout"`
+	ShutdownTimeout         time.Duration `yaml:"shutdown_timeout"`
+	DBMaxOpenConns          int           `yaml:"db_max_open_conns"`
+	DBMaxIdleConns          int           `yaml:"db_max_idle_conns"`
+	DBConnMaxLifetime       time.Duration `yaml:"db_conn_max_lifetime"`
+	DBReplicas              string        `yaml:"database_replicas"`
+	DBReplicaCheckInterval  time.Duration `yaml:"db_replica_check_interval"`
+	DBShards                string        `yaml:"database_shards"`
+	ReadyDeleteQueueLimit   int           `yaml:"ready_delete_queue_limit"`
+	CacheAddress            string        `yaml:"cache_address"`
+	CacheSize               int           `yaml:"cache_size"`
+	CacheTTL                time.Duration `yaml:"cache_ttl"`
+	CacheNegativeTTL        time.Duration `yaml:"cache_negative_ttl"`
+	CacheShards             int           `yaml:"cache_shards"`
+	CacheLocalTTL           time.Duration `yaml:"cache_local_ttl"`
+	LogLevel                string        `yaml:"log_level"`
+	LogFormat               string        `yaml:"log_format"`
+	AccessLog               string        `yaml:"access_log"`
+	AccessLogFormat         string        `yaml:"access_log_format"`
+	AccessLogMaxSize        int           `yaml:"access_log_max_size"`
+	AccessLogMaxBackups     int           `yaml:"access_log_max_backups"`
+	AccessLogRedirectSample float64       `yaml:"access_log_redirect_sample"`
+	CookieName              string        `yaml:"cookie_name"`
+	CookieMaxAge            time.Duration `yaml:"cookie_max_age"`
+	CookieSecure            bool          `yaml:"cookie_secure"`
+	CookieHashKey           string        `yaml:"cookie_hash_key"`
+	CookieBlockKey          string        `yaml:"cookie_block_key"`
+	CORSOrigins             string        `yaml:"cors_origins"`
+	AdminToken              string        `yaml:"admin_token"`
+	TLSCertFile             string        `yaml:"tls_cert_file"`
+	TLSKeyFile              string        `yaml:"tls_key_file"`
+	TLSSelfSigned           bool          `yaml:"tls_self_signed"`
+	TLSRedirectAddr         string        `yaml:"tls_redirect_address"`
+	GRPCAddress             string        `yaml:"grpc_address"`
+	TrustedSubnet           string        `yaml:"trusted_subnet"`
+	TraceOutput             string        `yaml:"trace_output"`
+}
+
+// TLSEnabled сообщает, должен ли сервер принимать HTTPS.
+func (cfg *Config) TLSEnabled() bool {
+	return cfg.TLSCertFile != "" || cfg.TLSSelfSigned
+}
+
+// ReplicaDSNs возвращает DSN реплик для чтения из списка через запятую.
+func (cfg *Config) ReplicaDSNs() []string {
+	return splitDSNs(cfg.DBReplicas)
+}
+
+// ShardDSNs возвращает DSN шардов в порядке конфигурации; порядок определяет номера шардов.
+func (cfg *Config) ShardDSNs() []string {
+	return splitDSNs(cfg.DBShards)
+}
+
+func splitDSNs(list string) []string {
+	var dsns []string
+	for _, dsn := range strings.Split(list, ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			dsns = append(dsns, dsn)
+		}
+	}
+	return dsns
+}
+
+// Default возвращает конфигурацию со значениями по умолчанию.
+func Default() Config {
+	return Config{
+		ServerAddress:           "localhost:8080",
+		BaseURL:                 "http://localhost:8080",
+		RateLimitStore:          "memory",
+		URLSchemes:              "http,https",
+		URLMaxLength:            2048,
+		URLStripTracking:        true,
+		ReadTimeout:             5 * time.Second,
+		WriteTimeout:            10 * time.Second,
+		IdleTimeout:             15 * time.Second,
+		ShutdownTimeout:         10 * time.Second,
+		DBMaxOpenConns:          25,
+		DBMaxIdleConns:          25,
+		DBConnMaxLifetime:       5 * time.Minute,
+		DBReplicaCheckInterval:  5 * time.Second,
+		CacheTTL:                10 * time.Minute,
+		CacheNegativeTTL:        30 * time.Second,
+		CacheSize:               10000,
+		CacheShards:             16,
+		CacheLocalTTL:           time.Minute,
+		LogLevel:                "info",
+		LogFormat:               "console",
+		AccessLogFormat:         "combined",
+		AccessLogMaxSize:        100,
+		AccessLogMaxBackups:     5,
+		AccessLogRedirectSample: 1,
+		CookieName:              "session",
+		CookieMaxAge:            24 * time.Hour,
+		CORSOrigins:             "*",
+	}
+}
+
+// LoadConfig загружает конфигурацию процесса: аргументы командной строки,
+// переменные окружения (включая .env) и файловую систему ОС.
+func LoadConfig(args []string) (*Config, error) {
+	// Файл .env необязателен
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("load .env: %w", err)
 	}
+	return Load(args, os.Getenv, OSFileSystem{})
+}
+
+// Load собирает конфигурацию из источников в порядке приоритета:
+// флаги > переменные окружения > файл конфигурации > значения по умолчанию.
+// Не использует глобальное состояние, поэтому может вызываться многократно.
+func Load(args []string, getenv func(string) string, fsys FileSystem) (*Config, error) {
+	cfg := Default()
+	fields := cfg.fields()
 
-	if *flagAddr != "" {
-		cfg.ServerAddress = *flagAddr
-	} else if envAddr := os.Getenv("SERVER_ADDRESS"); envAddr != "" {
-		cfg.ServerAddress = envAddr
+	// Флаги разбираются первыми, но применяются последними
+	flagValues := make(map[string]string)
+	fs := flag.NewFlagSet("shortener", flag.ContinueOnError)
+	for _, f := range fields {
+		fs.Var(&rawFlag{name: f.flag, values: flagValues, isBool: f.value.isBool()}, f.flag, f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
 	}
 
-	if *flagBase != "" {
-		cfg.BaseURL = *flagBase
-	} else if envBase := os.Getenv("BASE_URL"); envBase != "" {
-		cfg.BaseURL = envBase
+	configFile := flagValues["c"]
+	if configFile == "" {
+		configFile = getenv("CONFIG")
+	}
+	if configFile != "" {
+		if err := loadFile(&cfg, fsys, configFile); err != nil {
+			return nil, err
+		}
 	}
-	if *flagFileName != "" {
-		cfg.FileName = *flagFileName
-	} else {
-		cfg.FileName = os.Getenv("FILE_STORAGE_PATH")
+	var errs []error
+	for _, f := range fields {
+		if envValue := getenv(f.env); envValue != "" {
+			if err := f.value.Set(envValue); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s: %w", f.env, err))
+			}
+		}
 	}
-	if *flagFDBConnection != "" {
-		cfg.DBConnection = *flagFDBConnection
-	} else {
-		cfg.DBConnection = os.Getenv("DATABASE_DSN")
+	for _, f := range fields {
+		if flagValue, ok := flagValues[f.flag]; ok {
+			if err := f.value.Set(flagValue); err != nil {
+				errs = append(errs, fmt.Errorf("invalid -%s: %w", f.flag, err))
+			}
+		}
 	}
-	if _, err := url.ParseRequestURI(cfg.BaseURL); err != nil {
-		return nil, fmt.Errorf("invalid base URL: %w", err)
+	cfg.ConfigFile = configFile
+
+	errs = append(errs, cfg.Validate(fsys))
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
 	return &cfg, nil
 }
+
+// loadFile читает YAML или JSON. JSON является подмножеством YAML, поэтому
+// оба формата разбираются одним декодером; в файле задаются только нужные поля.
+func loadFile(cfg *Config, fsys FileSystem, path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+	default:
+		return fmt.Errorf("unsupported config file format %q", filepath.Ext(path))
+	}
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Change — отличие одного поля между двумя конфигурациями.
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %q -> %q", c.Field, c.Old, c.New)
+}
+
+// Diff сравнивает конфигурации по полям файла конфигурации. Секреты скрываются.
+func Diff(oldCfg, newCfg Config) []Change {
+	var changes []Change
+	oldVal := reflect.ValueOf(oldCfg.Redacted())
+	newVal := reflect.ValueOf(newCfg.Redacted())
+	rawOld := reflect.ValueOf(oldCfg)
+	rawNew := reflect.ValueOf(newCfg)
+	t := oldVal.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("yaml")
+		if name == "" || name == "-" {
+			continue
+		}
+		// Сравниваем исходные значения, чтобы заметить смену секрета
+		if reflect.DeepEqual(rawOld.Field(i).Interface(), rawNew.Field(i).Interface()) {
+			continue
+		}
+		changes = append(changes, Change{
+			Field: name,
+			Old:   fmt.Sprint(oldVal.Field(i).Interface()),
+			New:   fmt.Sprint(newVal.Field(i).Interface()),
+		})
+	}
+	return changes
+}
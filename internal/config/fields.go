@@ -0,0 +1,150 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+// value — значение поля конфигурации, которое можно задать строкой из флага или окружения.
+type value interface {
+	Set(string) error
+	isBool() bool
+}
+
+type stringValue struct{ p *string }
+
+func (v stringValue) Set(s string) error { *v.p = s; return nil }
+func (v stringValue) isBool() bool       { return false }
+
+type intValue struct{ p *int }
+
+func (v intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*v.p = n
+	return nil
+}
+func (v intValue) isBool() bool { return false }
+
+type boolValue struct{ p *bool }
+
+func (v boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*v.p = b
+	return nil
+}
+func (v boolValue) isBool() bool { return true }
+
+type floatValue struct{ p *float64 }
+
+func (v floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*v.p = f
+	return nil
+}
+func (v floatValue) isBool() bool { return false }
+
+type durationValue struct{ p *time.Duration }
+
+func (v durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v.p = d
+	return nil
+}
+func (v durationValue) isBool() bool { return false }
+
+// field связывает поле конфигурации с флагом и переменной окружения.
+type field struct {
+	flag  string
+	env   string
+	usage string
+	value value
+}
+
+func (cfg *Config) fields() []field {
+	return []field{
+		{"c", "CONFIG", "Path to JSON or YAML config file", stringValue{&cfg.ConfigFile}},
+		{"a", "SERVER_ADDRESS", "HTTP server address", stringValue{&cfg.ServerAddress}},
+		{"b", "BASE_URL", "Base URL for shortened URLs", stringValue{&cfg.BaseURL}},
+		{"f", "FILE_STORAGE_PATH", "File name", stringValue{&cfg.FileName}},
+		{"d", "DATABASE_DSN", "DB Connection: Postgres DSN, sqlite:///path/to/urls.db or bolt:///path/to/urls.db", stringValue{&cfg.DBConnection}},
+		{"rate-shorten", "RATE_LIMIT_SHORTEN", "Rate limit for shortening endpoints, rate:burst", stringValue{&cfg.RateLimitShorten}},
+		{"rate-redirect", "RATE_LIMIT_REDIRECT", "Rate limit for redirects, rate:burst", stringValue{&cfg.RateLimitRedirect}},
+		{"rate-api", "RATE_LIMIT_API", "Rate limit for other API endpoints, rate:burst", stringValue{&cfg.RateLimitAPI}},
+		{"rate-store", "RATE_LIMIT_STORE", "Rate limit store: memory or postgres", stringValue{&cfg.RateLimitStore}},
+		{"trusted-proxies", "TRUSTED_PROXIES", "Comma-separated CIDRs of trusted proxies", stringValue{&cfg.TrustedProxies}},
+		{"url-schemes", "URL_SCHEMES", "Comma-separated allowed URL schemes", stringValue{&cfg.URLSchemes}},
+		{"url-max-length", "URL_MAX_LENGTH", "Maximum length of a URL to shorten", intValue{&cfg.URLMaxLength}},
+		{"url-blocklist", "URL_BLOCKLIST_FILE", "File with blocked domains", stringValue{&cfg.URLBlocklistFile}},
+		{"url-allowlist", "URL_ALLOWLIST_FILE", "File with allowed domains", stringValue{&cfg.URLAllowlistFile}},
+		{"url-allow-private", "URL_ALLOW_PRIVATE", "Allow URLs pointing to private and loopback addresses", boolValue{&cfg.URLAllowPrivate}},
+		{"url-resolve-hosts", "URL_RESOLVE_HOSTS", "Resolve hosts to detect private addresses", boolValue{&cfg.URLResolveHosts}},
+		{"url-sort-query", "URL_SORT_QUERY", "Sort query parameters when deduplicating URLs", boolValue{&cfg.URLSortQuery}},
+		{"url-strip-tracking", "URL_STRIP_TRACKING", "Ignore tracking parameters when deduplicating URLs", boolValue{&cfg.URLStripTracking}},
+		{"read-timeout", "SERVER_READ_TIMEOUT", "HTTP server read timeout", durationValue{&cfg.ReadTimeout}},
+		{"write-timeout", "SERVER_WRITE_TIMEOUT", "HTTP server write timeout", durationValue{&cfg.WriteTimeout}},
+		{"idle-timeout", "SERVER_IDLE_TIMEOUT", "HTTP server idle timeout", durationValue{&cfg.IdleTimeout}},
+		{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "Time to drain in-flight requests and background work on shutdown", durationValue{&cfg.ShutdownTimeout}},
+		{"db-max-open-conns", "DB_MAX_OPEN_CONNS", "Maximum open DB connections", intValue{&cfg.DBMaxOpenConns}},
+		{"db-max-idle-conns", "DB_MAX_IDLE_CONNS", "Maximum idle DB connections", intValue{&cfg.DBMaxIdleConns}},
+		{"db-conn-max-lifetime", "DB_CONN_MAX_LIFETIME", "Maximum lifetime of a DB connection", durationValue{&cfg.DBConnMaxLifetime}},
+		{"database-replicas", "DATABASE_REPLICAS", "Comma-separated Postgres DSNs of read replicas", stringValue{&cfg.DBReplicas}},
+		{"db-replica-check-interval", "DB_REPLICA_CHECK_INTERVAL", "Interval between read replica health checks", durationValue{&cfg.DBReplicaCheckInterval}},
+		{"database-shards", "DATABASE_SHARDS", "Comma-separated Postgres DSNs of shards; new shards are appended", stringValue{&cfg.DBShards}},
+		{"ready-delete-queue-limit", "READY_DELETE_QUEUE_LIMIT", "Delete queue depth above which /readyz reports not ready; 0 disables the check", intValue{&cfg.ReadyDeleteQueueLimit}},
+		{"cache-address", "CACHE_ADDRESS", "RESP (Redis protocol) cache address, redis://[:password@]host:port[/db]", stringValue{&cfg.CacheAddress}},
+		{"cache-size", "CACHE_SIZE", "Entries in the in-process LRU cache in front of storage and the RESP cache, 0 disables it", intValue{&cfg.CacheSize}},
+		{"cache-ttl", "CACHE_TTL", "Lifetime of cached redirects", durationValue{&cfg.CacheTTL}},
+		{"cache-negative-ttl", "CACHE_NEGATIVE_TTL", "Lifetime of cached unknown keys", durationValue{&cfg.CacheNegativeTTL}},
+		{"cache-shards", "CACHE_SHARDS", "Number of independently locked parts of the in-process cache", intValue{&cfg.CacheShards}},
+		{"cache-local-ttl", "CACHE_LOCAL_TTL", "Lifetime of in-process copies of RESP cache entries", durationValue{&cfg.CacheLocalTTL}},
+		{"log-level", "LOG_LEVEL", "Log level: debug, info, warn, error", stringValue{&cfg.LogLevel}},
+		{"log-format", "LOG_FORMAT", "Log format: console or json", stringValue{&cfg.LogFormat}},
+		{"access-log", "ACCESS_LOG", "Access log output: stdout or a file; empty disables it", stringValue{&cfg.AccessLog}},
+		{"access-log-format", "ACCESS_LOG_FORMAT", "Access log format: common, combined or json", stringValue{&cfg.AccessLogFormat}},
+		{"access-log-max-size", "ACCESS_LOG_MAX_SIZE", "Access log file size in megabytes before rotation; 0 disables rotation", intValue{&cfg.AccessLogMaxSize}},
+		{"access-log-max-backups", "ACCESS_LOG_MAX_BACKUPS", "Number of rotated access log files to keep", intValue{&cfg.AccessLogMaxBackups}},
+		{"access-log-redirect-sample", "ACCESS_LOG_REDIRECT_SAMPLE", "Fraction of successful redirects written to the access log", floatValue{&cfg.AccessLogRedirectSample}},
+		{"cookie-name", "COOKIE_NAME", "Session cookie name", stringValue{&cfg.CookieName}},
+		{"cookie-max-age", "COOKIE_MAX_AGE", "Session cookie lifetime", durationValue{&cfg.CookieMaxAge}},
+		{"cookie-secure", "COOKIE_SECURE", "Set Secure attribute on the session cookie", boolValue{&cfg.CookieSecure}},
+		{"cookie-hash-key", "COOKIE_HASH_KEY", "Hex-encoded cookie signing key", stringValue{&cfg.CookieHashKey}},
+		{"cookie-block-key", "COOKIE_BLOCK_KEY", "Hex-encoded cookie encryption key", stringValue{&cfg.CookieBlockKey}},
+		{"cors-origins", "CORS_ORIGINS", "Comma-separated allowed CORS origins, * for any", stringValue{&cfg.CORSOrigins}},
+		{"admin-token", "ADMIN_TOKEN", "Bearer token for admin endpoints; empty disables them", stringValue{&cfg.AdminToken}},
+		{"tls-cert", "TLS_CERT_FILE", "TLS certificate file; enables HTTPS", stringValue{&cfg.TLSCertFile}},
+		{"tls-key", "TLS_KEY_FILE", "TLS private key file", stringValue{&cfg.TLSKeyFile}},
+		{"tls-self-signed", "TLS_SELF_SIGNED", "Serve HTTPS with a generated self-signed certificate (development only)", boolValue{&cfg.TLSSelfSigned}},
+		{"tls-redirect", "TLS_REDIRECT_ADDRESS", "Address of a plain HTTP listener redirecting to HTTPS", stringValue{&cfg.TLSRedirectAddr}},
+		{"grpc-address", "GRPC_ADDRESS", "gRPC server address; empty disables gRPC", stringValue{&cfg.GRPCAddress}},
+		{"t", "TRUSTED_SUBNET", "CIDR allowed to read internal stats; empty denies everyone", stringValue{&cfg.TrustedSubnet}},
+		{"trace-output", "TRACE_OUTPUT", "Write OTLP/JSON spans to stdout or a file; empty disables export", stringValue{&cfg.TraceOutput}},
+	}
+}
+
+// rawFlag запоминает строковое значение флага, чтобы применить его после файла и окружения.
+type rawFlag struct {
+	name   string
+	values map[string]string
+	isBool bool
+}
+
+func (f *rawFlag) String() string { return "" }
+
+func (f *rawFlag) Set(s string) error {
+	f.values[f.name] = s
+	return nil
+}
+
+func (f *rawFlag) IsBoolFlag() bool { return f.isBool }
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// FileSystem — операции с файлами, которые нужны загрузчику конфигурации.
+type FileSystem interface {
+	ReadFile(name string) ([]byte, error)
+	// CheckWritable проверяет, что в файл можно писать (создавая каталоги при необходимости).
+	CheckWritable(name string) error
+}
+
+type OSFileSystem struct{}
+
+func (OSFileSystem) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (OSFileSystem) CheckWritable(name string) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	_, statErr := os.Stat(name)
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	file.Close()
+	if os.IsNotExist(statErr) {
+		// Не оставляем пустой файл, созданный только ради проверки
+		return os.Remove(name)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// Redacted возвращает копию конфигурации со скрытыми секретами.
+func (cfg Config) Redacted() Config {
+	cfg.DBConnection = redactDSN(cfg.DBConnection)
+	if replicas := cfg.ReplicaDSNs(); len(replicas) > 0 {
+		for i, dsn := range replicas {
+			replicas[i] = redactDSN(dsn)
+		}
+		cfg.DBReplicas = strings.Join(replicas, ",")
+	}
+	if shards := cfg.ShardDSNs(); len(shards) > 0 {
+		for i, dsn := range shards {
+			shards[i] = redactDSN(dsn)
+		}
+		cfg.DBShards = strings.Join(shards, ",")
+	}
+	cfg.CacheAddress = redactDSN(cfg.CacheAddress)
+	if cfg.CookieHashKey != "" {
+		cfg.CookieHashKey = redacted
+	}
+	if cfg.CookieBlockKey != "" {
+		cfg.CookieBlockKey = redacted
+	}
+	if cfg.AdminToken != "" {
+		cfg.AdminToken = redacted
+	}
+	return cfg
+}
+
+// redactDSN скрывает пароль как в URL-форме DSN, так и в форме key=value.
+func redactDSN(dsn string) string {
+	if dsn == "" {
+		return dsn
+	}
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+			return u.String()
+		}
+		return dsn
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
+
+// YAML возвращает конфигурацию без секретов в формате файла конфигурации.
+func (cfg Config) YAML() ([]byte, error) {
+	return yaml.Marshal(cfg.Redacted())
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dron1337/shortener/internal/accesslog"
+	"github.com/dron1337/shortener/internal/clientip"
+	"github.com/dron1337/shortener/internal/ratelimit"
+	"github.com/dron1337/shortener/internal/resp"
+	"go.uber.org/zap/zapcore"
+)
+
+// Validate проверяет все поля и возвращает все найденные ошибки сразу.
+func (cfg *Config) Validate(fsys FileSystem) error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if err := validateAddress(cfg.ServerAddress); err != nil {
+		add("invalid server address %q: %w", cfg.ServerAddress, err)
+	}
+	if u, err := url.ParseRequestURI(cfg.BaseURL); err != nil {
+		add("invalid base URL: %w", err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		add("invalid base URL: scheme must be http or https")
+	}
+	if cfg.DBConnection != "" {
+		if err := validateDSN(cfg.DBConnection); err != nil {
+			add("invalid database DSN: %w", err)
+		}
+	}
+	if cfg.FileName != "" && fsys != nil {
+		if err := fsys.CheckWritable(cfg.FileName); err != nil {
+			add("file storage path %q is not writable: %w", cfg.FileName, err)
+		}
+	}
+	if cfg.RateLimitStore != "memory" && cfg.RateLimitStore != "postgres" {
+		add("invalid rate limit store %q", cfg.RateLimitStore)
+	}
+	for _, limit := range []struct{ name, value string }{
+		{"shorten", cfg.RateLimitShorten},
+		{"redirect", cfg.RateLimitRedirect},
+		{"api", cfg.RateLimitAPI},
+	} {
+		if _, err := ratelimit.ParseLimit(limit.value); err != nil {
+			add("invalid %s rate limit: %w", limit.name, err)
+		}
+	}
+	if _, err := clientip.ParseCIDRs(cfg.TrustedProxies); err != nil {
+		add("invalid trusted proxies: %w", err)
+	}
+	if cfg.URLMaxLength < 0 {
+		add("URL max length must not be negative")
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server read timeout", cfg.ReadTimeout},
+		{"server write timeout", cfg.WriteTimeout},
+		{"server idle timeout", cfg.IdleTimeout},
+		{"shutdown timeout", cfg.ShutdownTimeout},
+		{"cookie max age", cfg.CookieMaxAge},
+	} {
+		if d.value <= 0 {
+			add("%s must be positive", d.name)
+		}
+	}
+	for _, dsn := range cfg.ReplicaDSNs() {
+		if strings.HasPrefix(dsn, "sqlite://") || strings.HasPrefix(dsn, "bolt://") {
+			add("database replicas must be Postgres DSNs")
+		} else if err := validateDSN(dsn); err != nil {
+			add("invalid database replica DSN: %w", err)
+		}
+	}
+	if cfg.DBReplicas != "" && cfg.DBReplicaCheckInterval <= 0 {
+		add("DB replica check interval must be positive")
+	}
+	for _, dsn := range cfg.ShardDSNs() {
+		if strings.HasPrefix(dsn, "sqlite://") || strings.HasPrefix(dsn, "bolt://") {
+			add("database shards must be Postgres DSNs")
+		} else if err := validateDSN(dsn); err != nil {
+			add("invalid database shard DSN: %w", err)
+		}
+	}
+	if cfg.DBShards != "" && (cfg.DBConnection != "" || cfg.DBReplicas != "") {
+		add("database shards cannot be combined with database DSN or replicas")
+	}
+	if cfg.DBMaxOpenConns < 0 || cfg.DBMaxIdleConns < 0 || cfg.DBConnMaxLifetime < 0 {
+		add("DB pool settings must not be negative")
+	}
+	if cfg.CacheAddress != "" {
+		if _, err := resp.ParseURL(cfg.CacheAddress); err != nil {
+			add("invalid cache address: %w", err)
+		}
+	}
+	if cfg.CacheSize < 0 {
+		add("cache size must not be negative")
+	}
+	if cfg.CacheShards < 1 {
+		add("cache shards must be positive")
+	}
+	if cfg.CacheTTL <= 0 || cfg.CacheNegativeTTL <= 0 || cfg.CacheLocalTTL <= 0 {
+		add("cache TTLs must be positive")
+	}
+	if cfg.ReadyDeleteQueueLimit < 0 {
+		add("ready delete queue limit must not be negative")
+	}
+	if _, err := zapcore.ParseLevel(cfg.LogLevel); err != nil {
+		add("invalid log level %q", cfg.LogLevel)
+	}
+	if cfg.LogFormat != "console" && cfg.LogFormat != "json" {
+		add("invalid log format %q", cfg.LogFormat)
+	}
+	if _, err := accesslog.ParseFormat(cfg.AccessLogFormat); err != nil {
+		add("invalid access log format %q", cfg.AccessLogFormat)
+	}
+	if cfg.AccessLogMaxSize < 0 || cfg.AccessLogMaxBackups < 0 {
+		add("access log rotation settings must not be negative")
+	}
+	if cfg.AccessLogRedirectSample < 0 || cfg.AccessLogRedirectSample > 1 {
+		add("access log redirect sample must be between 0 and 1")
+	}
+	if cfg.CookieName == "" {
+		add("cookie name must not be empty")
+	}
+	if _, err := hex.DecodeString(cfg.CookieHashKey); err != nil {
+		add("cookie hash key must be hex-encoded")
+	}
+	if _, err := hex.DecodeString(cfg.CookieBlockKey); err != nil {
+		add("cookie block key must be hex-encoded")
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		add("TLS certificate and key files must be set together")
+	}
+	if cfg.TLSSelfSigned && cfg.TLSCertFile != "" {
+		add("TLS self-signed mode conflicts with certificate files")
+	}
+	if cfg.TLSRedirectAddr != "" {
+		if !cfg.TLSEnabled() {
+			add("TLS redirect address requires TLS to be enabled")
+		} else if err := validateAddress(cfg.TLSRedirectAddr); err != nil {
+			add("invalid TLS redirect address %q: %w", cfg.TLSRedirectAddr, err)
+		}
+	}
+	if cfg.TrustedSubnet != "" {
+		if _, _, err := net.ParseCIDR(cfg.TrustedSubnet); err != nil {
+			add("invalid trusted subnet: %w", err)
+		}
+	}
+	if cfg.GRPCAddress != "" {
+		if err := validateAddress(cfg.GRPCAddress); err != nil {
+			add("invalid gRPC address %q: %w", cfg.GRPCAddress, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateAddress(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 0 || p > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// validateDSN принимает путь к файлу SQLite (sqlite://...) или bbolt (bolt://...), DSN Postgres в виде URL (postgres://...) или пар key=value.
+func validateDSN(dsn string) error {
+	for _, scheme := range []string{"sqlite://", "bolt://"} {
+		if path, ok := strings.CutPrefix(dsn, scheme); ok {
+			if path == "" {
+				return errors.New("missing database path")
+			}
+			return nil
+		}
+	}
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return err
+		}
+		if u.Host == "" {
+			return errors.New("missing host")
+		}
+		return nil
+	}
+	for _, part := range strings.Fields(dsn) {
+		if !strings.Contains(part, "=") {
+			return fmt.Errorf("expected key=value, got %q", part)
+		}
+	}
+	return nil
+}
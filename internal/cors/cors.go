@@ -0,0 +1,69 @@
+package cors
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// CORS добавляет заголовки Access-Control-* для разрешённых источников.
+// Список источников можно менять во время работы.
+type CORS struct {
+	origins atomic.Pointer[[]string]
+}
+
+// New принимает список источников через запятую; "*" разрешает любой источник.
+func New(origins string) *CORS {
+	c := &CORS{}
+	c.SetOrigins(origins)
+	return c
+}
+
+func (c *CORS) SetOrigins(origins string) {
+	var list []string
+	for _, o := range strings.Split(origins, ",") {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			list = append(list, o)
+		}
+	}
+	c.origins.Store(&list)
+}
+
+// allowedOrigin возвращает значение Access-Control-Allow-Origin или пустую строку.
+func (c *CORS) allowedOrigin(origin string) string {
+	for _, o := range *c.origins.Load() {
+		if o == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(o, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		allowed := c.allowedOrigin(origin)
+		if allowed != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+			if allowed != "*" {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allowed == "" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Encoding, Authorization")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,46 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	c := New("https://app.example, https://admin.example/")
+	handler := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(method, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/shorten", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := request("POST", "https://admin.example")
+	assert.Equal(t, "https://admin.example", rr.Header().Get("Access-Control-Allow-Origin"))
+
+	rr = request("POST", "https://evil.example")
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+
+	rr = request(http.MethodOptions, "https://app.example")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Contains(t, rr.Header().Get("Access-Control-Allow-Methods"), "POST")
+
+	rr = request(http.MethodOptions, "https://evil.example")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	c.SetOrigins("*")
+	rr = request("POST", "")
+	assert.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -3,6 +3,9 @@ package errors
 import "errors"
 
 var (
-	ErrURLNotFound = errors.New("URL not found")
-	ErrURLDeleted  = errors.New("URL is deleted")
+	ErrURLNotFound        = errors.New("URL not found")
+	ErrURLDeleted         = errors.New("URL is deleted")
+	ErrWorkspaceNotFound  = errors.New("workspace not found")
+	ErrNotWorkspaceMember = errors.New("user is not a workspace member")
+	ErrInvalidRole        = errors.New("invalid workspace role")
 )
@@ -0,0 +1,125 @@
+// Package lifecycle запускает и останавливает компоненты сервера в согласованном порядке.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrStopped возвращается из Start, если менеджер уже остановлен.
+var ErrStopped = errors.New("lifecycle: already stopped")
+
+// Hook — компонент с действиями при запуске и остановке. Любое из них может быть nil.
+// OnStop должен уложиться в срок контекста и при его истечении прервать работу принудительно.
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Manager запускает хуки в порядке регистрации и останавливает в обратном,
+// так что компонент останавливается раньше тех, от которых зависит.
+type Manager struct {
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	hooks   []Hook
+	started int
+	stopped bool
+	stopErr error
+	done    chan struct{}
+}
+
+func New(logger *zap.Logger) *Manager {
+	return &Manager{logger: logger, done: make(chan struct{})}
+}
+
+// Append регистрирует хук. Хуки, добавленные после Start, запустит следующий вызов Start.
+func (m *Manager) Append(hook Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook)
+}
+
+// Start запускает ещё не запущенные хуки. Если один из них завершился ошибкой,
+// уже запущенные останавливаются, а ошибка возвращается.
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return ErrStopped
+	}
+	for m.started < len(m.hooks) {
+		hook := m.hooks[m.started]
+		if hook.OnStart != nil {
+			if err := hook.OnStart(ctx); err != nil {
+				err = fmt.Errorf("start %s: %w", hook.Name, err)
+				m.stopped = true
+				m.stopErr = errors.Join(err, m.stopHooks(ctx))
+				close(m.done)
+				return m.stopErr
+			}
+		}
+		m.started++
+	}
+	return nil
+}
+
+// Stop останавливает запущенные хуки в обратном порядке. Ошибка одного хука не мешает
+// остановить остальные. Повторные вызовы возвращают результат первого.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return m.stopErr
+	}
+	m.stopped = true
+	m.stopErr = m.stopHooks(ctx)
+	close(m.done)
+	return m.stopErr
+}
+
+// stopHooks вызывается под mu.
+func (m *Manager) stopHooks(ctx context.Context) error {
+	var errs []error
+	for i := m.started - 1; i >= 0; i-- {
+		hook := m.hooks[i]
+		if hook.OnStop == nil {
+			continue
+		}
+		start := time.Now()
+		if err := hook.OnStop(ctx); err != nil {
+			m.logger.Error("Component stop failed", zap.String("component", hook.Name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("stop %s: %w", hook.Name, err))
+			continue
+		}
+		m.logger.Debug("Component stopped", zap.String("component", hook.Name), zap.Duration("duration", time.Since(start)))
+	}
+	m.started = 0
+	return errors.Join(errs...)
+}
+
+// Done закрывается, когда менеджер остановлен.
+func (m *Manager) Done() <-chan struct{} {
+	return m.done
+}
+
+// Wait ждёт wg, но не дольше срока ctx.
+func Wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,96 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"go.uber.org/zap"
+)
+
+func TestManager(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	t.Run("Stops in reverse order", func(t *testing.T) {
+		var events []string
+		m := New(zap.NewNop())
+		for _, name := range []string{"db", "worker", "http"} {
+			m.Append(Hook{
+				Name:    name,
+				OnStart: func(context.Context) error { events = append(events, "start "+name); return nil },
+				OnStop:  func(context.Context) error { events = append(events, "stop "+name); return nil },
+			})
+		}
+		require.NoError(t, m.Start(context.Background()))
+		require.NoError(t, m.Stop(context.Background()))
+		assert.Equal(t, []string{"start db", "start worker", "start http", "stop http", "stop worker", "stop db"}, events)
+
+		select {
+		case <-m.Done():
+		default:
+			t.Fatal("Done не закрыт после Stop")
+		}
+		assert.ErrorIs(t, m.Start(context.Background()), ErrStopped)
+		assert.NoError(t, m.Stop(context.Background()), "повторный Stop возвращает результат первого")
+	})
+
+	t.Run("Failed start rolls back", func(t *testing.T) {
+		var stopped []string
+		m := New(zap.NewNop())
+		m.Append(Hook{Name: "db", OnStop: func(context.Context) error { stopped = append(stopped, "db"); return nil }})
+		m.Append(Hook{Name: "http", OnStart: func(context.Context) error { return errors.New("address in use") }})
+		m.Append(Hook{Name: "never", OnStop: func(context.Context) error { stopped = append(stopped, "never"); return nil }})
+
+		err := m.Start(context.Background())
+		assert.ErrorContains(t, err, "start http: address in use")
+		assert.Equal(t, []string{"db"}, stopped)
+	})
+
+	t.Run("Stop errors do not stop others", func(t *testing.T) {
+		var stopped []string
+		m := New(zap.NewNop())
+		m.Append(Hook{Name: "db", OnStop: func(context.Context) error { stopped = append(stopped, "db"); return nil }})
+		m.Append(Hook{Name: "cache", OnStop: func(context.Context) error { return errors.New("flush failed") }})
+		require.NoError(t, m.Start(context.Background()))
+
+		err := m.Stop(context.Background())
+		assert.ErrorContains(t, err, "stop cache: flush failed")
+		assert.Equal(t, []string{"db"}, stopped)
+	})
+
+	t.Run("Concurrent Stop waits for the first", func(t *testing.T) {
+		release := make(chan struct{})
+		m := New(zap.NewNop())
+		m.Append(Hook{Name: "slow", OnStop: func(context.Context) error { <-release; return nil }})
+		require.NoError(t, m.Start(context.Background()))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, m.Stop(context.Background()))
+			}()
+		}
+		close(release)
+		wg.Wait()
+	})
+}
+
+func TestWait(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, Wait(ctx, &wg), context.DeadlineExceeded)
+
+	wg.Done()
+	assert.NoError(t, Wait(context.Background(), &wg))
+}
@@ -1,14 +1,23 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"os"
 	"time"
 
+	"github.com/dron1337/shortener/internal/tracing"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-var Log *zap.Logger = zap.NewNop()
+// atomicLevel — уровень логера сервиса; меняется на лету через SetLevel.
+var atomicLevel = zap.NewAtomicLevel()
+
+// RequestIDHeader — заголовок с идентификатором запроса.
+const RequestIDHeader = "X-Request-ID"
 
 type (
 	// берём структуру для хранения сведений об ответе
@@ -37,46 +46,126 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	r.responseData.status = statusCode // захватываем код статуса
 }
 
-// Initialize инициализирует синглтон логера с необходимым уровнем логирования.
-func Initialize(level string) error {
-	// преобразуем текстовый уровень логирования в zap.AtomicLevel
-	lvl, err := zap.ParseAtomicLevel(level)
+// New создаёт логер сервиса с уровнем level и форматом json или console.
+func New(level, format string) (*zap.Logger, error) {
+	lvl, err := zapcore.ParseLevel(level)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	encoderCfg := zap.NewProductionEncoderConfig()
+	encoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	var encoder zapcore.Encoder
+	switch format {
+	case "json":
+		encoder = zapcore.NewJSONEncoder(encoderCfg)
+	case "console", "":
+		encoderCfg.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderCfg)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
 	}
-	// создаём новую конфигурацию логера
-	cfg := zap.NewDevelopmentConfig()
-	// устанавливаем уровень
-	cfg.Level = lvl
-	// создаём логер на основе конфигурации
-	zl, err := cfg.Build()
+	atomicLevel.SetLevel(lvl)
+	core := zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), atomicLevel)
+	return zap.New(core, zap.AddCaller()), nil
+}
+
+// SetLevel меняет уровень логирования на лету.
+func SetLevel(lvl string) error {
+	parsed, err := zap.ParseAtomicLevel(lvl)
 	if err != nil {
 		return err
 	}
-	// устанавливаем синглтон
-	Log = zl
+	atomicLevel.SetLevel(parsed.Level())
 	return nil
 }
 
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		responseData := &responseData{
-			status: 0,
-			size:   0,
+type loggerKey struct{}
+type requestIDKey struct{}
+
+// WithLogger кладёт логер запроса в контекст.
+func WithLogger(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext возвращает логер запроса (с request_id и trace_id) или fallback вне запроса.
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return fallback
+}
+
+// WithRequestID запоминает идентификатор запроса в контексте.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает идентификатор текущего запроса.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID возвращает входящий идентификатор, если он безопасен для логов, иначе новый.
+func NewRequestID(incoming string) string {
+	if incoming != "" && len(incoming) <= 128 {
+		valid := true
+		for _, c := range incoming {
+			if c < 0x21 || c > 0x7e {
+				valid = false
+				break
+			}
 		}
-		lw := loggingResponseWriter{
-			ResponseWriter: w, // встраиваем оригинальный http.ResponseWriter
-			responseData:   responseData,
+		if valid {
+			return incoming
 		}
-		next.ServeHTTP(&lw, r)
-		duration := time.Since(start)
-		Log.Info("got incoming HTTP request",
-			zap.String("method", r.Method),
-			zap.String("path", r.URL.Path),
-			zap.String("duration", fmt.Sprintf("%.2fs", duration.Seconds())),
-			zap.Int("size", responseData.size),
-			zap.Int("status", responseData.status),
-		)
-	})
+	}
+	return uuid.NewString()
+}
+
+// ForRequest дополняет логер полями запроса из контекста.
+func ForRequest(ctx context.Context, base *zap.Logger) *zap.Logger {
+	fields := make([]zap.Field, 0, 3)
+	if id := RequestID(ctx); id != "" {
+		fields = append(fields, zap.String("request_id", id))
+	}
+	if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() {
+		fields = append(fields, zap.String("trace_id", sc.TraceID.String()), zap.String("span_id", sc.SpanID.String()))
+	}
+	return base.With(fields...)
+}
+
+// Middleware присваивает запросу X-Request-ID, кладёт в контекст логер с его полями
+// и пишет отладочную строку о каждом запросе; журнал доступа ведёт пакет accesslog.
+func Middleware(base *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			requestID := NewRequestID(r.Header.Get(RequestIDHeader))
+			w.Header().Set(RequestIDHeader, requestID)
+			ctx := WithRequestID(r.Context(), requestID)
+			l := ForRequest(ctx, base)
+			ctx = WithLogger(ctx, l)
+
+			responseData := &responseData{
+				status: 0,
+				size:   0,
+			}
+			lw := loggingResponseWriter{
+				ResponseWriter: w, // встраиваем оригинальный http.ResponseWriter
+				responseData:   responseData,
+			}
+			next.ServeHTTP(&lw, r.WithContext(ctx))
+			if responseData.status == 0 {
+				responseData.status = http.StatusOK
+			}
+			l.Debug("got incoming HTTP request",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Duration("duration", time.Since(start)),
+				zap.Int("size", responseData.size),
+				zap.Int("status", responseData.status),
+			)
+		})
+	}
 }
@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMiddlewareRequestID(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	var inner string
+	handler := Middleware(zap.New(core))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = RequestID(r.Context())
+		FromContext(r.Context(), zap.NewNop()).Info("inside handler")
+	}))
+
+	t.Run("Incoming ID is propagated", func(t *testing.T) {
+		logs.TakeAll()
+		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		req.Header.Set(RequestIDHeader, "req-123")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, "req-123", rr.Header().Get(RequestIDHeader))
+		assert.Equal(t, "req-123", inner)
+		entries := logs.TakeAll()
+		require.Len(t, entries, 2)
+		for _, e := range entries {
+			assert.Equal(t, "req-123", e.ContextMap()["request_id"])
+		}
+	})
+
+	t.Run("Missing or unsafe ID is generated", func(t *testing.T) {
+		for _, incoming := range []string{"", "bad id\n", strings.Repeat("a", 200)} {
+			req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+			req.Header.Set(RequestIDHeader, incoming)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			got := rr.Header().Get(RequestIDHeader)
+			assert.NotEmpty(t, got)
+			assert.NotEqual(t, incoming, got)
+			assert.Equal(t, got, inner)
+		}
+	})
+}
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Cookie", "token=secret")
+	h.Set("Authorization", "Bearer secret")
+	h.Set("Accept", "text/plain")
+
+	redacted := RedactHeaders(h)
+	assert.Equal(t, "[REDACTED]", redacted.Get("Cookie"))
+	assert.Equal(t, "[REDACTED]", redacted.Get("Authorization"))
+	assert.Equal(t, "text/plain", redacted.Get("Accept"))
+	assert.Equal(t, "token=secret", h.Get("Cookie"), "исходные заголовки не меняются")
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("debug", "json")
+	assert.NoError(t, err)
+	_, err = New("debug", "xml")
+	assert.Error(t, err)
+	_, err = New("loud", "console")
+	assert.Error(t, err)
+}
@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// sensitiveHeaders — заголовки, значения которых не должны попадать в логи.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+const redacted = "[REDACTED]"
+
+// RedactHeaders возвращает копию заголовков со скрытыми учётными данными и куками.
+func RedactHeaders(h http.Header) http.Header {
+	clean := h.Clone()
+	for _, name := range sensitiveHeaders {
+		if values := clean.Values(name); len(values) > 0 {
+			masked := make([]string, len(values))
+			for i := range masked {
+				masked[i] = redacted
+			}
+			clean[http.CanonicalHeaderKey(name)] = masked
+		}
+	}
+	return clean
+}
+
+// Headers — поле zap с заголовками запроса после редактирования.
+func Headers(h http.Header) zap.Field {
+	return zap.Any("headers", RedactHeaders(h))
+}
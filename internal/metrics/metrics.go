@@ -0,0 +1,282 @@
+// Package metrics — минимальная реализация метрик в текстовом формате Prometheus.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets — границы гистограмм длительности в секундах по умолчанию.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry хранит метрики и отдаёт их в текстовом формате Prometheus.
+type Registry struct {
+	mu         sync.Mutex
+	names      map[string]bool
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic(fmt.Sprintf("metrics: duplicate metric %q", name))
+	}
+	r.names[name] = true
+	r.collectors = append(r.collectors, c)
+}
+
+// Write выводит все метрики в порядке регистрации.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// desc — общее описание метрики с метками.
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d *desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.typ)
+}
+
+// series — значения меток одного временного ряда.
+type series []string
+
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs форматирует метки, extra добавляется в конец (например, le для бакетов).
+func (d *desc) labelPairs(values series, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(d.labels)+1)
+	for i, label := range d.labels {
+		pairs = append(pairs, label+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec — монотонно растущие счётчики с метками.
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labels series
+	value  float64
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name: name, help: help, typ: "counter", labels: labels}, values: make(map[string]*counterValue)}
+	r.register(name, c)
+	return c
+}
+
+// Add увеличивает счётчик с указанными значениями меток.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[key]
+	if !ok {
+		v = &counterValue{labels: append(series(nil), labelValues...)}
+		c.values[key] = v
+	}
+	v.value += delta
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value возвращает текущее значение счётчика.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.values[key]; ok {
+		return v.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.header(w)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.name)
+		return
+	}
+	for _, key := range sortedKeys(c.values) {
+		v := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(v.labels), formatFloat(v.value))
+	}
+}
+
+// HistogramVec — гистограммы с метками.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels series
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, typ: "histogram", labels: labels},
+		buckets: buckets,
+		values:  make(map[string]*histogramValue),
+	}
+	r.register(name, h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{labels: append(series(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		v.counts[i]++
+	}
+	v.sum += value
+	v.count++
+}
+
+// Count возвращает число наблюдений.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if v, ok := h.values[key]; ok {
+		return v.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.header(w)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		v := h.values[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += v.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(v.labels, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(v.labels, "le", "+Inf"), v.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(v.labels), formatFloat(v.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(v.labels), v.count)
+	}
+}
+
+// valueFunc — метрика без меток, значение которой вычисляется при каждом запросе.
+type valueFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc регистрирует текущее значение, например глубину очереди.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &valueFunc{desc: desc{name: name, help: help, typ: "gauge"}, fn: fn})
+}
+
+// NewCounterFunc регистрирует счётчик, который ведётся вне реестра, например в sql.DBStats.
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(name, &valueFunc{desc: desc{name: name, help: help, typ: "counter"}, fn: fn})
+}
+
+func (f *valueFunc) write(w *bufio.Writer) {
+	f.header(w)
+	fmt.Fprintf(w, "%s %s\n", f.name, formatFloat(f.fn()))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
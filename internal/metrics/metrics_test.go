@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounterVec("requests_total", "Total requests.", "route", "status")
+	latency := reg.NewHistogramVec("latency_seconds", "Request latency.", []float64{0.5, 0.1}, "route")
+	reg.NewGaugeFunc("queue_depth", "Queued items.", func() float64 { return 3 })
+
+	requests.Inc("/{key}", "307")
+	requests.Inc("/{key}", "307")
+	requests.Inc(`/a"b`, "200")
+	latency.Observe(0.05, "/")
+	latency.Observe(0.3, "/")
+	latency.Observe(2, "/")
+
+	var out strings.Builder
+	require.NoError(t, reg.Write(&out))
+	assert.Equal(t, `# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{route="/a\"b",status="200"} 1
+requests_total{route="/{key}",status="307"} 2
+# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/",le="0.1"} 1
+latency_seconds_bucket{route="/",le="0.5"} 2
+latency_seconds_bucket{route="/",le="+Inf"} 3
+latency_seconds_sum{route="/"} 2.35
+latency_seconds_count{route="/"} 3
+# HELP queue_depth Queued items.
+# TYPE queue_depth gauge
+queue_depth 3
+`, out.String())
+	assert.Equal(t, float64(2), requests.Value("/{key}", "307"))
+}
+
+func TestDuplicateAndLabelMismatchPanic(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.NewCounterVec("c_total", "help", "a")
+	assert.Panics(t, func() { reg.NewCounterVec("c_total", "help") })
+	assert.Panics(t, func() { c.Inc() })
+}
+
+func TestHandler(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounterVec("up_total", "help").Inc()
+	rr := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, rr.Header().Get("Content-Type"), "version=0.0.4")
+	assert.Contains(t, rr.Body.String(), "up_total 1\n")
+}
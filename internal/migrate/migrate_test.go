@@ -0,0 +1,87 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/dron1337/shortener/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingBackend отказывает на Import с номером failAt, имитируя обрыв переноса.
+type failingBackend struct {
+	Backend
+	calls, failAt int
+}
+
+func (b *failingBackend) Import(ctx context.Context, records []store.Record, overwrite bool) (store.ImportResult, error) {
+	if b.calls++; b.calls == b.failAt {
+		return store.ImportResult{}, errors.New("connection reset")
+	}
+	return b.Backend.Import(ctx, records, overwrite)
+}
+
+func openTest(t *testing.T, spec string) Backend {
+	t.Helper()
+	b, closeFn, err := Open(spec, store.PoolConfig{})
+	require.NoError(t, err)
+	t.Cleanup(func() { closeFn(context.Background()) })
+	return b
+}
+
+func TestOpen(t *testing.T) {
+	for _, spec := range []string{"file", "file:", "redis:localhost:6379"} {
+		_, _, err := Open(spec, store.PoolConfig{})
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	source := openTest(t, "file:"+filepath.Join(dir, "urls.json"))
+	for i := range 25 {
+		url := fmt.Sprintf("https://example.com/%d", i)
+		require.NoError(t, source.Save(ctx, "user", url, url, fmt.Sprintf("key%05d", i)))
+	}
+	_, err := source.Import(ctx, []store.Record{{ShortKey: "deleted", OriginalURL: "https://example.com/gone", UserID: "user", IsDeleted: true}}, false)
+	require.NoError(t, err)
+	target := openTest(t, "sqlite:"+filepath.Join(dir, "urls.db"))
+	checkpoint := filepath.Join(dir, "checkpoint")
+	opts := Options{BatchSize: 10, CheckpointPath: checkpoint, Migration: MigrationID("a", "b")}
+
+	_, err = Copy(ctx, source, &failingBackend{Backend: target, failAt: 2}, opts)
+	require.Error(t, err)
+	cp, err := LoadCheckpoint(checkpoint, opts.Migration)
+	require.NoError(t, err)
+	assert.Equal(t, 10, cp.Copied, "контрольная точка — после последней записанной пачки")
+
+	_, err = LoadCheckpoint(checkpoint, MigrationID("a", "c"))
+	assert.Error(t, err, "чужая контрольная точка не подходит")
+
+	var progress []int
+	opts.Progress = func(copied int, _ store.ImportResult) { progress = append(progress, copied) }
+	result, err := Copy(ctx, source, target, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 10, result.Resumed)
+	assert.Equal(t, store.ImportResult{Imported: 16}, result.ImportResult)
+	assert.Equal(t, []int{20, 26}, progress)
+
+	report, err := Verify(ctx, source, target, 100)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+	assert.Equal(t, 26, report.SourceCount)
+	assert.Equal(t, 26, report.TargetCount)
+	assert.Equal(t, 26, report.Sampled)
+
+	_, err = target.Import(ctx, []store.Record{{ShortKey: "key00003", OriginalURL: "https://other.example.com", UserID: "user"}}, true)
+	require.NoError(t, err)
+	report, err = Verify(ctx, source, target, 100)
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, []string{"key00003"}, report.Mismatches)
+}
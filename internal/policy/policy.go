@@ -0,0 +1,279 @@
+package policy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Violation — причина, по которой URL отклонён политикой.
+type Violation struct {
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return v.Reason
+}
+
+func violation(format string, args ...any) *Violation {
+	return &Violation{Reason: fmt.Sprintf(format, args...)}
+}
+
+type Options struct {
+	Schemes       []string
+	MaxLength     int
+	BlocklistFile string
+	AllowlistFile string
+	AllowPrivate  bool
+	ResolveHosts  bool
+	// SelfURLs — адреса самого сервиса, ссылки на которые создали бы петлю редиректов.
+	SelfURLs []string
+}
+
+type domainLists struct {
+	block []string
+	allow []string
+}
+
+// Policy проверяет целевые URL перед сокращением.
+type Policy struct {
+	opts      Options
+	schemes   map[string]bool
+	selfHosts atomic.Pointer[map[string]bool]
+	lists     atomic.Pointer[domainLists]
+	lookup    func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+	mu       sync.Mutex
+	modTimes map[string]time.Time
+}
+
+func New(opts Options) (*Policy, error) {
+	p := &Policy{
+		opts:     opts,
+		schemes:  make(map[string]bool),
+		lookup:   net.DefaultResolver.LookupIPAddr,
+		modTimes: make(map[string]time.Time),
+	}
+	for _, scheme := range opts.Schemes {
+		if scheme = strings.ToLower(strings.TrimSpace(scheme)); scheme != "" {
+			p.schemes[scheme] = true
+		}
+	}
+	p.SetSelfURLs(opts.SelfURLs)
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetSelfURLs задаёт адреса сервиса для обнаружения петель.
+func (p *Policy) SetSelfURLs(selfURLs []string) {
+	hosts := make(map[string]bool)
+	for _, raw := range selfURLs {
+		if u, err := url.Parse(raw); err == nil && u.Host != "" {
+			hosts[hostPort(u)] = true
+		} else if raw != "" {
+			// Адрес сервера задаётся как host:port без схемы
+			hosts[hostPort(&url.URL{Scheme: "http", Host: raw})] = true
+		}
+	}
+	p.selfHosts.Store(&hosts)
+}
+
+// Reload перечитывает списки доменов. При ошибке остаются прежние списки.
+func (p *Policy) Reload() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.load(p.opts.BlocklistFile, p.opts.AllowlistFile)
+}
+
+// SetFiles переключает политику на другие файлы списков. Если их не удалось
+// прочитать, политика продолжает работать со старыми файлами.
+func (p *Policy) SetFiles(blocklistFile, allowlistFile string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.load(blocklistFile, allowlistFile); err != nil {
+		return err
+	}
+	p.opts.BlocklistFile = blocklistFile
+	p.opts.AllowlistFile = allowlistFile
+	return nil
+}
+
+func (p *Policy) load(blocklistFile, allowlistFile string) error {
+	block, blockMod, err := readDomains(blocklistFile)
+	if err != nil {
+		return fmt.Errorf("load blocklist: %w", err)
+	}
+	allow, allowMod, err := readDomains(allowlistFile)
+	if err != nil {
+		return fmt.Errorf("load allowlist: %w", err)
+	}
+	p.modTimes = map[string]time.Time{
+		blocklistFile: blockMod,
+		allowlistFile: allowMod,
+	}
+	p.lists.Store(&domainLists{block: block, allow: allow})
+	return nil
+}
+
+// Watch перечитывает списки при изменении файлов, пока не отменён ctx.
+func (p *Policy) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !p.changed() {
+				continue
+			}
+			if err := p.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (p *Policy) changed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, path := range []string{p.opts.BlocklistFile, p.opts.AllowlistFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(p.modTimes[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check возвращает *Violation, если URL нельзя сокращать.
+func (p *Policy) Check(ctx context.Context, raw string) error {
+	if p.opts.MaxLength > 0 && len(raw) > p.opts.MaxLength {
+		return violation("URL is longer than %d characters", p.opts.MaxLength)
+	}
+	u, err := url.ParseRequestURI(raw)
+	if err != nil {
+		return violation("malformed URL")
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if len(p.schemes) > 0 && !p.schemes[scheme] {
+		return violation("scheme %q is not allowed", scheme)
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return violation("URL has no host")
+	}
+	if (*p.selfHosts.Load())[hostPort(u)] {
+		return violation("URL points to the shortener itself")
+	}
+	lists := p.lists.Load()
+	if len(lists.allow) > 0 && !matchDomain(host, lists.allow) {
+		return violation("domain %s is not in the allowlist", host)
+	}
+	if matchDomain(host, lists.block) {
+		return violation("domain %s is blocked", host)
+	}
+	if !p.opts.AllowPrivate {
+		if err := p.checkPrivate(ctx, host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Policy) checkPrivate(ctx context.Context, host string) error {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return violation("URL targets a private or loopback address")
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if isPrivate(ip) {
+			return violation("URL targets a private or loopback address")
+		}
+		return nil
+	}
+	if !p.opts.ResolveHosts {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	addrs, err := p.lookup(ctx, host)
+	if err != nil {
+		// Неразрешимый домен не указывает на внутреннюю сеть
+		return nil
+	}
+	for _, addr := range addrs {
+		if isPrivate(addr.IP) {
+			return violation("host %s resolves to a private or loopback address", host)
+		}
+	}
+	return nil
+}
+
+func isPrivate(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+func matchDomain(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func hostPort(u *url.URL) string {
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	port := u.Port()
+	if port == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "https":
+			port = "443"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// readDomains читает файл со списком доменов: по одному на строку, # — комментарий.
+func readDomains(path string) ([]string, time.Time, error) {
+	if path == "" {
+		return nil, time.Time{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var domains []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(line), "*."), "."))
+		if line != "" {
+			domains = append(domains, line)
+		}
+	}
+	return domains, info.ModTime(), scanner.Err()
+}
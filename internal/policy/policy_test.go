@@ -0,0 +1,98 @@
+package policy
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	blocklist := filepath.Join(dir, "blocklist.txt")
+	require.NoError(t, os.WriteFile(blocklist, []byte("# spam\nevil.com\n*.bad.org\n"), 0644))
+
+	p, err := New(Options{
+		Schemes:       []string{"http", "https"},
+		MaxLength:     64,
+		BlocklistFile: blocklist,
+		SelfURLs:      []string{"http://short.ly", "localhost:8080"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://example.com/page", true},
+		{"javascript:alert(1)", false},
+		{"file:///etc/passwd", false},
+		{"ftp://example.com/file", false},
+		{"https://example.com/" + string(make([]byte, 64)), false},
+		{"https://evil.com/x", false},
+		{"https://sub.evil.com/x", false},
+		{"https://notevil.com/x", true},
+		{"https://www.bad.org", false},
+		{"http://short.ly/abc", false},
+		{"HTTP://SHORT.LY:80/abc", false},
+		{"https://short.ly/abc", true},
+		{"http://127.0.0.1/admin", false},
+		{"http://10.0.0.5/", false},
+		{"http://[::1]:8080/", false},
+		{"http://localhost:9000/", false},
+		{"http://8.8.8.8/", true},
+	}
+	for _, tt := range tests {
+		err := p.Check(context.Background(), tt.url)
+		if tt.ok {
+			assert.NoError(t, err, tt.url)
+		} else {
+			var v *Violation
+			assert.ErrorAs(t, err, &v, tt.url)
+		}
+	}
+}
+
+func TestAllowlistAndResolve(t *testing.T) {
+	dir := t.TempDir()
+	allowlist := filepath.Join(dir, "allowlist.txt")
+	require.NoError(t, os.WriteFile(allowlist, []byte("example.com\ninternal.example\n"), 0644))
+
+	p, err := New(Options{AllowlistFile: allowlist, ResolveHosts: true})
+	require.NoError(t, err)
+	p.lookup = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		if host == "internal.example" {
+			return []net.IPAddr{{IP: net.ParseIP("192.168.1.10")}}, nil
+		}
+		return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+	}
+
+	assert.NoError(t, p.Check(context.Background(), "https://docs.example.com/"))
+	assert.Error(t, p.Check(context.Background(), "https://other.net/"))
+	assert.Error(t, p.Check(context.Background(), "https://internal.example/"))
+}
+
+func TestWatchReloadsLists(t *testing.T) {
+	dir := t.TempDir()
+	blocklist := filepath.Join(dir, "blocklist.txt")
+	require.NoError(t, os.WriteFile(blocklist, []byte("evil.com\n"), 0644))
+	p, err := New(Options{BlocklistFile: blocklist})
+	require.NoError(t, err)
+	assert.NoError(t, p.Check(context.Background(), "https://spam.net/"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Watch(ctx, 10*time.Millisecond, nil)
+
+	require.NoError(t, os.WriteFile(blocklist, []byte("evil.com\nspam.net\n"), 0644))
+	future := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(blocklist, future, future))
+	assert.Eventually(t, func() bool {
+		return p.Check(context.Background(), "https://spam.net/") != nil
+	}, time.Second, 10*time.Millisecond)
+}
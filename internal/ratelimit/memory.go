@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryStore хранит корзины в памяти процесса.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	idleTTL   time.Duration
+	lastSweep time.Time
+}
+
+func NewMemoryStore(idleTTL time.Duration) *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		idleTTL: idleTTL,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+	b, exists := s.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+	tokens, res := take(b.tokens, b.last, now, limit)
+	b.tokens = tokens
+	b.last = now
+	return res, nil
+}
+
+// sweep удаляет давно неиспользуемые корзины, чтобы карта не росла бесконечно.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.idleTTL {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > s.idleTTL {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// PostgresStore хранит корзины в общей таблице, чтобы лимит действовал на все экземпляры сервиса.
+// Строки, не обновлявшиеся дольше idleTTL, периодически удаляются до вызова Close.
+type PostgresStore struct {
+	db      *sql.DB
+	idleTTL time.Duration
+	onError func(error)
+	stop    context.CancelFunc
+	sweeps  sync.WaitGroup
+}
+
+// NewPostgresStore создаёт таблицу корзин и запускает её очистку раз в idleTTL.
+// Ошибки очистки передаются в onError, если он задан.
+func NewPostgresStore(db *sql.DB, idleTTL time.Duration, onError func(error)) (*PostgresStore, error) {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS rate_limits (
+			key TEXT PRIMARY KEY,
+			tokens DOUBLE PRECISION NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL
+	);
+`)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &PostgresStore{db: db, idleTTL: idleTTL, onError: onError, stop: cancel}
+	s.sweeps.Add(1)
+	go func() {
+		defer s.sweeps.Done()
+		ticker := time.NewTicker(idleTTL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if err := s.sweep(ctx, now); err != nil && ctx.Err() == nil && s.onError != nil {
+					s.onError(err)
+				}
+			}
+		}
+	}()
+	return s, nil
+}
+
+// sweep удаляет корзины, которые не обновлялись дольше idleTTL: они уже полные,
+// и без строки Take заведёт такую же заново.
+func (s *PostgresStore) sweep(ctx context.Context, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM rate_limits WHERE updated_at < $1", now.Add(-s.idleTTL))
+	return err
+}
+
+// Close останавливает очистку; соединение с базой остаётся открытым.
+func (s *PostgresStore) Close(ctx context.Context) error {
+	s.stop()
+	s.sweeps.Wait()
+	return nil
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO NOTHING",
+		key, float64(limit.Burst), now)
+	if err != nil {
+		return Result{}, err
+	}
+	var tokens float64
+	var last time.Time
+	err = tx.QueryRowContext(ctx,
+		"SELECT tokens, updated_at FROM rate_limits WHERE key = $1 FOR UPDATE", key).
+		Scan(&tokens, &last)
+	if err != nil {
+		return Result{}, err
+	}
+	tokens, res := take(tokens, last, now, limit)
+	_, err = tx.ExecContext(ctx,
+		"UPDATE rate_limits SET tokens = $2, updated_at = $3 WHERE key = $1", key, tokens, now)
+	if err != nil {
+		return Result{}, err
+	}
+	return res, tx.Commit()
+}
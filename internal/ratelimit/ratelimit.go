@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dron1337/shortener/internal/auth"
+	"github.com/dron1337/shortener/internal/clientip"
+)
+
+// Limit описывает token bucket: Rate токенов в секунду и ёмкость Burst.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit разбирает лимит в формате "rate:burst", например "5:20".
+// Пустая строка означает отсутствие ограничения.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Limit{}, nil
+	}
+	rate, burst, found := strings.Cut(s, ":")
+	if !found {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: expected rate:burst", s)
+	}
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil || r <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate in %q", s)
+	}
+	b, err := strconv.Atoi(burst)
+	if err != nil || b <= 0 {
+		return Limit{}, fmt.Errorf("invalid burst in %q", s)
+	}
+	return Limit{Rate: r, Burst: b}, nil
+}
+
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// Result — итог попытки взять токен.
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// Store хранит состояние корзин.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// take применяет алгоритм token bucket к сохранённому состоянию.
+func take(tokens float64, last, now time.Time, limit Limit) (float64, Result) {
+	elapsed := now.Sub(last).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	tokens = math.Min(float64(limit.Burst), tokens+elapsed*limit.Rate)
+	res := Result{}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+	}
+	res.Remaining = int(tokens)
+	res.Reset = time.Duration((float64(limit.Burst) - tokens) / limit.Rate * float64(time.Second))
+	return tokens, res
+}
+
+// Limiter — middleware, ограничивающий частоту запросов по группам маршрутов.
+type Limiter struct {
+	store    Store
+	limits   atomic.Pointer[map[string]Limit]
+	group    func(*http.Request) string
+	resolver *clientip.Resolver
+	onError  func(error)
+	now      func() time.Time
+}
+
+func NewLimiter(store Store, limits map[string]Limit, group func(*http.Request) string, resolver *clientip.Resolver, onError func(error)) *Limiter {
+	l := &Limiter{
+		store:    store,
+		group:    group,
+		resolver: resolver,
+		onError:  onError,
+		now:      time.Now,
+	}
+	l.SetLimits(limits)
+	return l
+}
+
+// SetLimits атомарно заменяет лимиты групп, не сбрасывая состояние корзин.
+func (l *Limiter) SetLimits(limits map[string]Limit) {
+	l.limits.Store(&limits)
+}
+
+// Close останавливает фоновую работу хранилища корзин, если она у него есть.
+func (l *Limiter) Close(ctx context.Context) error {
+	if c, ok := l.store.(interface{ Close(context.Context) error }); ok {
+		return c.Close(ctx)
+	}
+	return nil
+}
+
+// key возвращает ключ корзины: пользователь из проверенной куки либо IP клиента.
+func (l *Limiter) key(r *http.Request) string {
+	if isNew, _ := r.Context().Value(auth.NewUserKey).(bool); !isNew {
+		if userID, ok := r.Context().Value(auth.UserIDKey).(string); ok && userID != "" {
+			return "user:" + userID
+		}
+	}
+	return "ip:" + l.resolver.ClientIP(r).String()
+}
+
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		group := l.group(r)
+		limit, ok := (*l.limits.Load())[group]
+		if !ok || !limit.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		res, err := l.store.Take(r.Context(), group+":"+l.key(r), limit, l.now())
+		if err != nil {
+			// При недоступном хранилище пропускаем запрос, а не блокируем сервис
+			if l.onError != nil {
+				l.onError(err)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dron1337/shortener/internal/auth"
+	"github.com/dron1337/shortener/internal/clientip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("2.5:10")
+	require.NoError(t, err)
+	assert.Equal(t, Limit{Rate: 2.5, Burst: 10}, limit)
+
+	limit, err = ParseLimit("")
+	require.NoError(t, err)
+	assert.False(t, limit.Enabled())
+
+	for _, bad := range []string{"5", "x:1", "1:0", "-1:5"} {
+		_, err := ParseLimit(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	store := NewMemoryStore(time.Minute)
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Now()
+	ctx := context.Background()
+
+	res, _ := store.Take(ctx, "k", limit, now)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+	res, _ = store.Take(ctx, "k", limit, now)
+	assert.True(t, res.Allowed)
+	res, _ = store.Take(ctx, "k", limit, now)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+
+	res, _ = store.Take(ctx, "k", limit, now.Add(time.Second))
+	assert.True(t, res.Allowed)
+	res, _ = store.Take(ctx, "other", limit, now)
+	assert.True(t, res.Allowed)
+}
+
+func TestLimiterMiddleware(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore(time.Minute),
+		map[string]Limit{"shorten": {Rate: 1, Burst: 1}},
+		func(r *http.Request) string { return r.URL.Path[1:] },
+		clientip.NewResolver(nil), nil)
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(path, userID string, isNew bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, nil)
+		req.RemoteAddr = "203.0.113.1:5000"
+		ctx := context.WithValue(req.Context(), auth.UserIDKey, userID)
+		ctx = context.WithValue(ctx, auth.NewUserKey, isNew)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	t.Run("Limited group", func(t *testing.T) {
+		rr := request("/shorten", "user-1", false)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "1", rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+
+		rr = request("/shorten", "user-1", false)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+
+		rr = request("/shorten", "user-2", false)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("New users are keyed by IP", func(t *testing.T) {
+		rr := request("/shorten", "fresh-1", true)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		rr = request("/shorten", "fresh-2", true)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	})
+
+	t.Run("Unlimited group", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			rr := request("/redirect", "user-1", false)
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+		}
+	})
+}
+
+// Запросы очистки переносимы, поэтому таблица корзин в тесте живёт в SQLite.
+func TestPostgresStoreSweep(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "limits.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	store, err := NewPostgresStore(db, time.Minute, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for key, updated := range map[string]time.Time{"idle": now.Add(-2 * time.Minute), "active": now.Add(-time.Second)} {
+		_, err := db.Exec("INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, 1, $2)", key, updated)
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.sweep(ctx, now))
+	var keys []string
+	rows, err := db.Query("SELECT key FROM rate_limits")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		require.NoError(t, rows.Scan(&key))
+		keys = append(keys, key)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"active"}, keys)
+	require.NoError(t, store.Close(ctx))
+}
@@ -0,0 +1,213 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options — параметры подключения.
+type Options struct {
+	Addr     string
+	Password string
+	DB       int
+	// Timeout ограничивает команду, если у контекста нет своего срока.
+	Timeout time.Duration
+	// MaxIdle — число соединений, которые остаются открытыми между командами.
+	MaxIdle int
+}
+
+// ParseURL разбирает адрес вида redis://[:password@]host:port[/db] или просто host:port.
+func ParseURL(raw string) (Options, error) {
+	opts := Options{Timeout: 500 * time.Millisecond, MaxIdle: 16}
+	if !strings.Contains(raw, "://") {
+		raw = "redis://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return opts, err
+	}
+	if u.Scheme != "redis" {
+		return opts, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		return opts, errors.New("missing port")
+	}
+	opts.Addr = u.Host
+	if u.User != nil {
+		opts.Password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if opts.DB, err = strconv.Atoi(db); err != nil || opts.DB < 0 {
+			return opts, fmt.Errorf("invalid database %q", db)
+		}
+	}
+	return opts, nil
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// Client выполняет команды через пул соединений. Безопасен для конкурентного использования.
+type Client struct {
+	opts Options
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+func NewClient(opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 500 * time.Millisecond
+	}
+	if opts.MaxIdle <= 0 {
+		opts.MaxIdle = 16
+	}
+	return &Client{opts: opts}
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+
+	dialer := net.Dialer{Timeout: c.opts.Timeout}
+	nc, err := dialer.DialContext(ctx, "tcp", c.opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	cn.SetDeadline(c.deadline(ctx))
+	if c.opts.Password != "" {
+		if _, err := cn.do([]string{"AUTH", c.opts.Password}); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := cn.do([]string{"SELECT", strconv.Itoa(c.opts.DB)}); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("select: %w", err)
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || len(c.idle) >= c.opts.MaxIdle {
+		cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+func (c *Client) deadline(ctx context.Context) time.Time {
+	if d, ok := ctx.Deadline(); ok {
+		return d
+	}
+	return time.Now().Add(c.opts.Timeout)
+}
+
+// do отправляет команду и возвращает ответ; Error сервера возвращается как ошибка.
+func (cn *conn) do(args []string) (any, error) {
+	if err := writeCommand(cn.w, args); err != nil {
+		return nil, err
+	}
+	reply, err := readReply(cn.r)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(Error); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// Do выполняет произвольную команду.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cn.SetDeadline(c.deadline(ctx))
+	reply, err := cn.do(args)
+	var serverErr Error
+	if err != nil && !errors.As(err, &serverErr) {
+		// Сетевая ошибка или сбой протокола: соединение больше нельзя использовать
+		cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+// Get возвращает значение ключа; ok == false, если ключа нет.
+func (c *Client) Get(ctx context.Context, key string) (string, bool, error) {
+	reply, err := c.Do(ctx, "GET", key)
+	if err != nil || reply == nil {
+		return "", false, err
+	}
+	s, ok := reply.(string)
+	if !ok {
+		return "", false, fmt.Errorf("resp: unexpected GET reply %T", reply)
+	}
+	return s, true, nil
+}
+
+// Set записывает значение; ttl <= 0 означает без срока.
+func (c *Client) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	args := []string{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := c.Do(ctx, args...)
+	return err
+}
+
+// Delete удаляет ключи.
+func (c *Client) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := c.Do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// Close закрывает простаивающие соединения; новые команды завершатся ошибкой.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for _, cn := range c.idle {
+		cn.Close()
+	}
+	c.idle = nil
+	return nil
+}
@@ -0,0 +1,130 @@
+// Package resp реализует минимальное подмножество протокола Redis (RESP2):
+// клиент для кэша и встроенный сервер, который заменяет Redis в тестах и при локальном запуске.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Error — ответ сервера с ошибкой (-ERR ...). Соединение после него остаётся рабочим.
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// maxBulkLen ограничивает размер строки, чтобы испорченный поток не вызвал огромную аллокацию.
+const maxBulkLen = 512 << 20
+
+var errProtocol = errors.New("resp: protocol error")
+
+// writeCommand пишет команду массивом bulk-строк.
+func writeCommand(w *bufio.Writer, args []string) error {
+	w.WriteByte('*')
+	w.WriteString(strconv.Itoa(len(args)))
+	w.WriteString("\r\n")
+	for _, arg := range args {
+		writeBulk(w, arg)
+	}
+	return w.Flush()
+}
+
+func writeBulk(w *bufio.Writer, s string) {
+	w.WriteByte('$')
+	w.WriteString(strconv.Itoa(len(s)))
+	w.WriteString("\r\n")
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func writeNull(w *bufio.Writer) {
+	w.WriteString("$-1\r\n")
+}
+
+func writeSimple(w *bufio.Writer, s string) {
+	w.WriteByte('+')
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func writeError(w *bufio.Writer, s string) {
+	w.WriteByte('-')
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func writeInt(w *bufio.Writer, n int64) {
+	w.WriteByte(':')
+	w.WriteString(strconv.FormatInt(n, 10))
+	w.WriteString("\r\n")
+}
+
+// readLine читает строку без завершающего \r\n.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errProtocol
+	}
+	return line[:len(line)-2], nil
+}
+
+// readReply читает одно значение: string для простых и bulk-строк, nil для null,
+// int64 для целых, []any для массивов и Error для ошибок сервера.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errProtocol
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, errProtocol
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n > maxBulkLen {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[n] != '\r' || buf[n+1] != '\n' {
+			return nil, errProtocol
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n > maxBulkLen {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("%w: unexpected type %q", errProtocol, line[0])
+}
@@ -0,0 +1,139 @@
+package resp
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestClientServer(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	ctx := context.Background()
+
+	server, err := NewServer("127.0.0.1:0", "")
+	require.NoError(t, err)
+	defer server.Close()
+	var mu sync.Mutex
+	now := time.Now()
+	server.now = func() time.Time { mu.Lock(); defer mu.Unlock(); return now }
+
+	client := NewClient(Options{Addr: server.Addr()})
+	defer client.Close()
+
+	t.Run("Get and set", func(t *testing.T) {
+		require.NoError(t, client.Ping(ctx))
+		_, ok, err := client.Get(ctx, "missing")
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		require.NoError(t, client.Set(ctx, "key", "value\r\nwith newline", 0))
+		v, ok, err := client.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "value\r\nwith newline", v)
+
+		require.NoError(t, client.Delete(ctx, "key", "other"))
+		_, ok, _ = client.Get(ctx, "key")
+		assert.False(t, ok)
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		require.NoError(t, client.Set(ctx, "ttl", "v", 2*time.Second))
+		_, ok, _ := client.Get(ctx, "ttl")
+		assert.True(t, ok)
+		mu.Lock()
+		now = now.Add(3 * time.Second)
+		mu.Unlock()
+		_, ok, _ = client.Get(ctx, "ttl")
+		assert.False(t, ok)
+	})
+
+	t.Run("Server error keeps connection", func(t *testing.T) {
+		_, err := client.Do(ctx, "NOSUCH")
+		var serverErr Error
+		assert.ErrorAs(t, err, &serverErr)
+		_, err = client.Do(ctx, "SET", "k", "v", "PX", "abc")
+		assert.Error(t, err)
+		assert.NoError(t, client.Ping(ctx))
+	})
+
+	t.Run("Databases are isolated", func(t *testing.T) {
+		other := NewClient(Options{Addr: server.Addr(), DB: 2})
+		defer other.Close()
+		require.NoError(t, client.Set(ctx, "shared", "db0", 0))
+		_, ok, err := other.Get(ctx, "shared")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Concurrent use", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, client.Set(ctx, "concurrent", "v", 0))
+				_, _, err := client.Get(ctx, "concurrent")
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestAuth(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	ctx := context.Background()
+	server, err := NewServer("127.0.0.1:0", "secret")
+	require.NoError(t, err)
+	defer server.Close()
+
+	anonymous := NewClient(Options{Addr: server.Addr()})
+	defer anonymous.Close()
+	_, _, err = anonymous.Get(ctx, "k")
+	assert.ErrorContains(t, err, "NOAUTH")
+
+	wrong := NewClient(Options{Addr: server.Addr(), Password: "nope"})
+	defer wrong.Close()
+	assert.ErrorContains(t, wrong.Ping(ctx), "WRONGPASS")
+
+	opts, err := ParseURL("redis://:secret@" + server.Addr() + "/1")
+	require.NoError(t, err)
+	client := NewClient(opts)
+	defer client.Close()
+	assert.NoError(t, client.Set(ctx, "k", "v", 0))
+}
+
+func TestClosedServer(t *testing.T) {
+	server, err := NewServer("127.0.0.1:0", "")
+	require.NoError(t, err)
+	client := NewClient(Options{Addr: server.Addr(), Timeout: 100 * time.Millisecond})
+	defer client.Close()
+	require.NoError(t, client.Ping(context.Background()))
+	require.NoError(t, server.Close())
+
+	assert.Error(t, client.Ping(context.Background()))
+}
+
+func TestParseURL(t *testing.T) {
+	opts, err := ParseURL("localhost:6379")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:6379", opts.Addr)
+	assert.Equal(t, 0, opts.DB)
+
+	opts, err = ParseURL("redis://:pw@cache:6380/3")
+	require.NoError(t, err)
+	assert.Equal(t, "cache:6380", opts.Addr)
+	assert.Equal(t, "pw", opts.Password)
+	assert.Equal(t, 3, opts.DB)
+
+	for _, bad := range []string{"http://cache:6379", "redis://cache", "redis://cache:6379/x"} {
+		_, err := ParseURL(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,272 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	value   string
+	expires time.Time
+}
+
+// Server — встроенный RESP-сервер с командами, которые нужны кэшу:
+// PING, AUTH, SELECT, GET, SET (EX/PX), DEL, EXISTS, DBSIZE, FLUSHALL.
+// Заменяет Redis в тестах и при локальном запуске.
+type Server struct {
+	ln       net.Listener
+	password string
+
+	mu     sync.Mutex
+	dbs    map[int]map[string]entry
+	conns  map[net.Conn]struct{}
+	closed bool
+	now    func() time.Time
+
+	wg sync.WaitGroup
+}
+
+// NewServer начинает слушать addr (например, "127.0.0.1:0"). Пустой password отключает AUTH.
+func NewServer(addr, password string) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		ln:       ln,
+		password: password,
+		dbs:      make(map[int]map[string]entry),
+		conns:    make(map[net.Conn]struct{}),
+		now:      time.Now,
+	}
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+// Addr возвращает фактический адрес сервера.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close останавливает сервер и закрывает все соединения.
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			c.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.serve(c)
+	}
+}
+
+// session — состояние одного соединения.
+type session struct {
+	db     int
+	authed bool
+}
+
+func (s *Server) serve(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+	r, w := bufio.NewReader(c), bufio.NewWriter(c)
+	sess := &session{authed: s.password == ""}
+	for {
+		req, err := readReply(r)
+		if err != nil {
+			return
+		}
+		args, ok := commandArgs(req)
+		if !ok {
+			writeError(w, "ERR Protocol error: expected array of bulk strings")
+			w.Flush()
+			return
+		}
+		s.exec(w, sess, args)
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func commandArgs(req any) ([]string, bool) {
+	items, ok := req.([]any)
+	if !ok || len(items) == 0 {
+		return nil, false
+	}
+	args := make([]string, len(items))
+	for i, item := range items {
+		if args[i], ok = item.(string); !ok {
+			return nil, false
+		}
+	}
+	return args, true
+}
+
+var errSyntax = errors.New("ERR syntax error")
+
+func (s *Server) exec(w *bufio.Writer, sess *session, args []string) {
+	cmd := strings.ToUpper(args[0])
+	if !sess.authed && cmd != "AUTH" && cmd != "PING" {
+		writeError(w, "NOAUTH Authentication required.")
+		return
+	}
+	if arity, ok := minArity[cmd]; !ok {
+		writeError(w, "ERR unknown command '"+args[0]+"'")
+		return
+	} else if len(args) < arity {
+		writeError(w, "ERR wrong number of arguments for '"+strings.ToLower(cmd)+"' command")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db := s.db(sess.db)
+	switch cmd {
+	case "PING":
+		if len(args) > 1 {
+			writeBulk(w, args[1])
+		} else {
+			writeSimple(w, "PONG")
+		}
+	case "AUTH":
+		pass := args[len(args)-1]
+		if s.password == "" {
+			writeError(w, "ERR Client sent AUTH, but no password is set")
+		} else if pass != s.password {
+			writeError(w, "WRONGPASS invalid username-password pair")
+		} else {
+			sess.authed = true
+			writeSimple(w, "OK")
+		}
+	case "SELECT":
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 || n > 15 {
+			writeError(w, "ERR DB index is out of range")
+			return
+		}
+		sess.db = n
+		writeSimple(w, "OK")
+	case "GET":
+		if e, ok := s.lookup(db, args[1]); ok {
+			writeBulk(w, e.value)
+		} else {
+			writeNull(w)
+		}
+	case "SET":
+		e := entry{value: args[2]}
+		if err := s.parseExpiry(&e, args[3:]); err != nil {
+			writeError(w, err.Error())
+			return
+		}
+		db[args[1]] = e
+		writeSimple(w, "OK")
+	case "DEL", "EXISTS":
+		var n int64
+		for _, key := range args[1:] {
+			if _, ok := s.lookup(db, key); ok {
+				n++
+				if cmd == "DEL" {
+					delete(db, key)
+				}
+			}
+		}
+		writeInt(w, n)
+	case "DBSIZE":
+		var n int64
+		for key := range db {
+			if _, ok := s.lookup(db, key); ok {
+				n++
+			}
+		}
+		writeInt(w, n)
+	case "FLUSHALL":
+		s.dbs = make(map[int]map[string]entry)
+		writeSimple(w, "OK")
+	}
+}
+
+// minArity — минимальное число аргументов команды вместе с её именем.
+var minArity = map[string]int{
+	"PING": 1, "AUTH": 2, "SELECT": 2, "GET": 2, "SET": 3,
+	"DEL": 2, "EXISTS": 2, "DBSIZE": 1, "FLUSHALL": 1,
+}
+
+// db возвращает базу по номеру; вызывается под mu.
+func (s *Server) db(n int) map[string]entry {
+	db, ok := s.dbs[n]
+	if !ok {
+		db = make(map[string]entry)
+		s.dbs[n] = db
+	}
+	return db
+}
+
+// lookup возвращает живую запись, удаляя просроченную; вызывается под mu.
+func (s *Server) lookup(db map[string]entry, key string) (entry, bool) {
+	e, ok := db[key]
+	if !ok {
+		return entry{}, false
+	}
+	if !e.expires.IsZero() && !s.now().Before(e.expires) {
+		delete(db, key)
+		return entry{}, false
+	}
+	return e, true
+}
+
+func (s *Server) parseExpiry(e *entry, opts []string) error {
+	for i := 0; i < len(opts); i++ {
+		var unit time.Duration
+		switch strings.ToUpper(opts[i]) {
+		case "EX":
+			unit = time.Second
+		case "PX":
+			unit = time.Millisecond
+		default:
+			return errSyntax
+		}
+		if i+1 >= len(opts) {
+			return errSyntax
+		}
+		n, err := strconv.ParseInt(opts[i+1], 10, 64)
+		if err != nil || n <= 0 {
+			return errors.New("ERR invalid expire time in 'set' command")
+		}
+		e.expires = s.now().Add(time.Duration(n) * unit)
+		i++
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package store
+
+import (
+	"context"
+	stderrors "errors"
+	"strings"
+	"time"
+
+	"github.com/dron1337/shortener/internal/errors"
+)
+
+// Cache — хранилище для CacheStore: RESP-сервер (resp.Client) или LRUCache.
+type Cache interface {
+	Get(ctx context.Context, key string) (string, bool, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// TieredCache — локальный кэш процесса перед общим (RESP-сервером).
+// Горячие ключи читаются из локального кэша без сетевого запроса; записи,
+// прочитанные из общего кэша, копируются в локальный не дольше чем на nearTTL,
+// чтобы инвалидация на другом экземпляре сервиса устаревала быстро.
+// Ошибки общего кэша передаются в onError и считаются промахом.
+type TieredCache struct {
+	near    Cache
+	far     Cache
+	nearTTL time.Duration
+	onError func(error)
+}
+
+func NewTieredCache(near, far Cache, nearTTL time.Duration, onError func(error)) *TieredCache {
+	return &TieredCache{near: near, far: far, nearTTL: nearTTL, onError: onError}
+}
+
+func (c *TieredCache) report(err error) {
+	if err != nil && c.onError != nil {
+		c.onError(err)
+	}
+}
+
+// localTTL ограничивает срок локальной копии.
+func (c *TieredCache) localTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || ttl > c.nearTTL {
+		return c.nearTTL
+	}
+	return ttl
+}
+
+func (c *TieredCache) Get(ctx context.Context, key string) (string, bool, error) {
+	if value, ok, err := c.near.Get(ctx, key); err == nil && ok {
+		return value, true, nil
+	}
+	value, ok, err := c.far.Get(ctx, key)
+	if err != nil {
+		c.report(err)
+		return "", false, nil
+	}
+	if ok {
+		c.near.Set(ctx, key, value, c.nearTTL)
+	}
+	return value, ok, nil
+}
+
+func (c *TieredCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	c.report(c.far.Set(ctx, key, value, ttl))
+	return c.near.Set(ctx, key, value, c.localTTL(ttl))
+}
+
+// Delete удаляет ключи из обоих кэшей; ошибка общего кэша возвращается,
+// потому что в нём могла остаться устаревшая запись.
+func (c *TieredCache) Delete(ctx context.Context, keys ...string) error {
+	return stderrors.Join(c.far.Delete(ctx, keys...), c.near.Delete(ctx, keys...))
+}
+
+// Значения в кэше: исходный URL с префиксом или отметка об отсутствии либо удалении.
+const (
+	cachedURLPrefix = "u:"
+	cachedNotFound  = "n"
+	cachedDeleted   = "d"
+)
+
+// CacheOptions — параметры CacheStore.
+type CacheOptions struct {
+	// Prefix отделяет ключи сервиса, если RESP-сервер общий.
+	Prefix string
+	TTL    time.Duration
+	// NegativeTTL — срок хранения отметки «ссылки нет»; обычно короче TTL.
+	NegativeTTL time.Duration
+	// OnError получает ошибки кэша; они не прерывают запрос, он уходит в хранилище.
+	OnError func(error)
+}
+
+// CacheStore — хранилище с кэшем чтения поверх любого Storage.
+// Кэшируются найденные, удалённые и несуществующие ссылки.
+type CacheStore struct {
+	backend Storage
+	cache   Cache
+	opts    CacheOptions
+}
+
+var _ Storage = (*CacheStore)(nil)
+
+func NewCacheStore(backend Storage, cache Cache, opts CacheOptions) *CacheStore {
+	if opts.Prefix == "" {
+		opts.Prefix = "shortener:url:"
+	}
+	return &CacheStore{backend: backend, cache: cache, opts: opts}
+}
+
+// Backend возвращает хранилище под кэшем.
+func (s *CacheStore) Backend() Storage {
+	return s.backend
+}
+
+func (s *CacheStore) report(err error) {
+	if err != nil && s.opts.OnError != nil {
+		s.opts.OnError(err)
+	}
+}
+
+func (s *CacheStore) GetOriginalURL(ctx context.Context, shortKey string) (string, error) {
+	cacheKey := s.opts.Prefix + shortKey
+	value, ok, err := s.cache.Get(ctx, cacheKey)
+	s.report(err)
+	if ok {
+		switch {
+		case strings.HasPrefix(value, cachedURLPrefix):
+			return strings.TrimPrefix(value, cachedURLPrefix), nil
+		case value == cachedDeleted:
+			return "", errors.ErrURLDeleted
+		case value == cachedNotFound:
+			return "", errors.ErrURLNotFound
+		}
+	}
+
+	originalURL, err := s.backend.GetOriginalURL(ctx, shortKey)
+	switch {
+	case err == nil:
+		s.report(s.cache.Set(ctx, cacheKey, cachedURLPrefix+originalURL, s.opts.TTL))
+	case stderrors.Is(err, errors.ErrURLDeleted):
+		s.report(s.cache.Set(ctx, cacheKey, cachedDeleted, s.opts.TTL))
+	case stderrors.Is(err, errors.ErrURLNotFound):
+		s.report(s.cache.Set(ctx, cacheKey, cachedNotFound, s.opts.NegativeTTL))
+	}
+	return originalURL, err
+}
+
+// Save сохраняет ссылку и сразу кладёт её в кэш, заменяя возможную отметку об отсутствии.
+func (s *CacheStore) Save(ctx context.Context, userID, originalURL, canonicalURL, shortKey string) error {
+	if err := s.backend.Save(ctx, userID, originalURL, canonicalURL, shortKey); err != nil {
+		s.report(s.cache.Delete(ctx, s.opts.Prefix+shortKey))
+		return err
+	}
+	s.report(s.cache.Set(ctx, s.opts.Prefix+shortKey, cachedURLPrefix+originalURL, s.opts.TTL))
+	return nil
+}
+
+func (s *CacheStore) GetShortKey(ctx context.Context, canonicalURL string) string {
+	return s.backend.GetShortKey(ctx, canonicalURL)
+}
+
+func (s *CacheStore) Stats(ctx context.Context) (Stats, error) {
+	return s.backend.Stats(ctx)
+}
+
+// Invalidate удаляет ключи из кэша; вызывается после удаления или изменения ссылок в обход Save.
+func (s *CacheStore) Invalidate(ctx context.Context, shortKeys ...string) error {
+	if len(shortKeys) == 0 {
+		return nil
+	}
+	cacheKeys := make([]string, len(shortKeys))
+	for i, key := range shortKeys {
+		cacheKeys[i] = s.opts.Prefix + key
+	}
+	return s.cache.Delete(ctx, cacheKeys...)
+}
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dron1337/shortener/internal/errors"
+	"github.com/dron1337/shortener/internal/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+// countingStorage считает обращения к хранилищу за ссылками.
+type countingStorage struct {
+	*InMemoryStorage
+	mu    sync.Mutex
+	reads int
+}
+
+func (s *countingStorage) GetOriginalURL(ctx context.Context, shortKey string) (string, error) {
+	s.mu.Lock()
+	s.reads++
+	s.mu.Unlock()
+	return s.InMemoryStorage.GetOriginalURL(ctx, shortKey)
+}
+
+func (s *countingStorage) Reads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads
+}
+
+func TestCacheStore(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	ctx := context.Background()
+
+	newServer := func(t *testing.T) *resp.Server {
+		server, err := resp.NewServer("127.0.0.1:0", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { server.Close() })
+		return server
+	}
+	newClient := func(t *testing.T, server *resp.Server) *resp.Client {
+		client := resp.NewClient(resp.Options{Addr: server.Addr(), Timeout: time.Second})
+		t.Cleanup(func() { client.Close() })
+		return client
+	}
+	opts := CacheOptions{TTL: time.Minute, NegativeTTL: time.Minute}
+
+	t.Run("Read-through", func(t *testing.T) {
+		backend := &countingStorage{InMemoryStorage: NewInMemoryStorage()}
+		require.NoError(t, backend.Save(ctx, "user", "https://example.com", "https://example.com", "abc"))
+		s := NewCacheStore(backend, newClient(t, newServer(t)), opts)
+
+		for range 3 {
+			u, err := s.GetOriginalURL(ctx, "abc")
+			require.NoError(t, err)
+			assert.Equal(t, "https://example.com", u)
+		}
+		assert.Equal(t, 1, backend.Reads())
+	})
+
+	t.Run("Negative caching", func(t *testing.T) {
+		backend := &countingStorage{InMemoryStorage: NewInMemoryStorage()}
+		s := NewCacheStore(backend, newClient(t, newServer(t)), opts)
+
+		for range 2 {
+			_, err := s.GetOriginalURL(ctx, "missing")
+			assert.ErrorIs(t, err, errors.ErrURLNotFound)
+		}
+		assert.Equal(t, 1, backend.Reads())
+
+		// Save заменяет отметку об отсутствии
+		require.NoError(t, s.Save(ctx, "user", "https://example.com", "https://example.com", "missing"))
+		u, err := s.GetOriginalURL(ctx, "missing")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", u)
+		assert.Equal(t, 1, backend.Reads())
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		backend := &countingStorage{InMemoryStorage: NewInMemoryStorage()}
+		require.NoError(t, backend.Save(ctx, "user", "https://example.com", "https://example.com", "abc"))
+		s := NewCacheStore(backend, newClient(t, newServer(t)), opts)
+
+		_, err := s.GetOriginalURL(ctx, "abc")
+		require.NoError(t, err)
+		require.NoError(t, s.Invalidate(ctx, "abc"))
+		_, err = s.GetOriginalURL(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, 2, backend.Reads())
+	})
+
+	t.Run("Local tier without RESP server", func(t *testing.T) {
+		server := newServer(t)
+		backend := &countingStorage{InMemoryStorage: NewInMemoryStorage()}
+		require.NoError(t, backend.Save(ctx, "user", "https://example.com", "https://example.com", "abc"))
+		var mu sync.Mutex
+		var cacheErrors int
+		onError := func(error) { mu.Lock(); cacheErrors++; mu.Unlock() }
+		cache := NewTieredCache(NewShardedLRU(10, 2), newClient(t, server), time.Minute, onError)
+		s := NewCacheStore(backend, cache, opts)
+
+		require.NoError(t, server.Close())
+		for range 3 {
+			u, err := s.GetOriginalURL(ctx, "abc")
+			require.NoError(t, err)
+			assert.Equal(t, "https://example.com", u)
+		}
+		assert.Equal(t, 1, backend.Reads(), "при недоступном сервере работает локальный кэш")
+		mu.Lock()
+		assert.Positive(t, cacheErrors)
+		mu.Unlock()
+	})
+
+	t.Run("Unavailable cache", func(t *testing.T) {
+		server := newServer(t)
+		backend := &countingStorage{InMemoryStorage: NewInMemoryStorage()}
+		require.NoError(t, backend.Save(ctx, "user", "https://example.com", "https://example.com", "abc"))
+		s := NewCacheStore(backend, newClient(t, server), opts)
+
+		require.NoError(t, server.Close())
+		u, err := s.GetOriginalURL(ctx, "abc")
+		require.NoError(t, err, "ошибка кэша не прерывает запрос")
+		assert.Equal(t, "https://example.com", u)
+	})
+}
+
+func TestLRUCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := NewLRUCache(2)
+	c.now = func() time.Time { return now }
+
+	c.Set(ctx, "a", "1", 0)
+	c.Set(ctx, "b", "2", 0)
+	_, ok, _ := c.Get(ctx, "a")
+	require.True(t, ok)
+	c.Set(ctx, "c", "3", 0)
+
+	_, ok, _ = c.Get(ctx, "b")
+	assert.False(t, ok, "вытесняется давно не читанная запись")
+	_, ok, _ = c.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Len())
+
+	c.Set(ctx, "ttl", "v", time.Second)
+	now = now.Add(2 * time.Second)
+	_, ok, _ = c.Get(ctx, "ttl")
+	assert.False(t, ok)
+}
+
+func TestShardedLRU(t *testing.T) {
+	ctx := context.Background()
+	c := NewShardedLRU(64, 4)
+
+	for i := range 100 {
+		c.Set(ctx, strconv.Itoa(i), "v", 0)
+	}
+	stats := c.Stats()
+	assert.LessOrEqual(t, stats.Entries, 64)
+	assert.Equal(t, uint64(100-stats.Entries), stats.Evictions)
+
+	c.Set(ctx, "hot", "v", 0)
+	_, ok, _ := c.Get(ctx, "hot")
+	assert.True(t, ok)
+	_, ok, _ = c.Get(ctx, "cold")
+	assert.False(t, ok)
+	require.NoError(t, c.Delete(ctx, "hot"))
+	_, ok, _ = c.Get(ctx, "hot")
+	assert.False(t, ok)
+
+	stats = c.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+}
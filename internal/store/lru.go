@@ -0,0 +1,166 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type lruEntry struct {
+	key     string
+	value   string
+	expires time.Time
+}
+
+// LRUCache — кэш в памяти процесса, вытесняющий давно не читанные записи.
+// Используется вместо RESP-сервера или как запасной кэш при его недоступности.
+type LRUCache struct {
+	capacity int
+	now      func() time.Time
+
+	mu        sync.Mutex
+	order     *list.List
+	items     map[string]*list.Element
+	evictions uint64
+}
+
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{
+		capacity: capacity,
+		now:      time.Now,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUCache) Get(ctx context.Context, key string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return "", false, nil
+	}
+	e := el.Value.(*lruEntry)
+	if !e.expires.IsZero() && !c.now().Before(e.expires) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return "", false, nil
+	}
+	c.order.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (c *LRUCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.now().Add(ttl)
+	}
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+		return nil
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+		c.evictions++
+	}
+	return nil
+}
+
+func (c *LRUCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.order.Remove(el)
+			delete(c.items, key)
+		}
+	}
+	return nil
+}
+
+// Len возвращает число записей, включая ещё не удалённые просроченные.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// CacheStats — счётчики ShardedLRU.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+// ShardedLRU делит записи между несколькими LRUCache по хэшу ключа, чтобы
+// конкурентные чтения горячих ссылок не упирались в одну блокировку.
+type ShardedLRU struct {
+	shards []*LRUCache
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewShardedLRU создаёт кэш на capacity записей, разделённых на shards частей.
+func NewShardedLRU(capacity, shards int) *ShardedLRU {
+	shards = max(1, min(shards, capacity))
+	c := &ShardedLRU{shards: make([]*LRUCache, shards)}
+	for i := range c.shards {
+		// Остаток распределяется по первым частям, чтобы сумма совпала с capacity
+		size := capacity / shards
+		if i < capacity%shards {
+			size++
+		}
+		c.shards[i] = NewLRUCache(size)
+	}
+	return c
+}
+
+func (c *ShardedLRU) shard(key string) *LRUCache {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return c.shards[h.Sum32()%uint32(len(c.shards))]
+}
+
+func (c *ShardedLRU) Get(ctx context.Context, key string) (string, bool, error) {
+	value, ok, err := c.shard(key).Get(ctx, key)
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return value, ok, err
+}
+
+func (c *ShardedLRU) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return c.shard(key).Set(ctx, key, value, ttl)
+}
+
+func (c *ShardedLRU) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		c.shard(key).Delete(ctx, key)
+	}
+	return nil
+}
+
+// Stats возвращает счётчики попаданий, промахов и вытеснений.
+func (c *ShardedLRU) Stats() CacheStats {
+	stats := CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+	for _, s := range c.shards {
+		s.mu.Lock()
+		stats.Evictions += s.evictions
+		stats.Entries += s.order.Len()
+		s.mu.Unlock()
+	}
+	return stats
+}
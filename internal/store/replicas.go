@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+)
+
+// replica — реплика для чтения и результат её последней проверки.
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// writeTracker отмечает, что в рамках запроса уже была запись.
+type writeTracker struct {
+	wrote atomic.Bool
+}
+
+type writeTrackerKey struct{}
+
+// TrackWrites возвращает контекст запроса, в котором после первой записи
+// все чтения идут в основную базу: реплика могла ещё не получить изменения.
+func TrackWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writeTrackerKey{}, &writeTracker{})
+}
+
+// WithPrimary направляет все чтения в ctx в основную базу.
+func WithPrimary(ctx context.Context) context.Context {
+	t := &writeTracker{}
+	t.wrote.Store(true)
+	return context.WithValue(ctx, writeTrackerKey{}, t)
+}
+
+func markWrite(ctx context.Context) {
+	if t, ok := ctx.Value(writeTrackerKey{}).(*writeTracker); ok {
+		t.wrote.Store(true)
+	}
+}
+
+func pinnedToPrimary(ctx context.Context) bool {
+	t, ok := ctx.Value(writeTrackerKey{}).(*writeTracker)
+	return ok && t.wrote.Load()
+}
+
+// SetReplicas подключает реплики для чтения ссылок и запускает их проверку раз в checkInterval.
+// Вызывается до начала работы с хранилищем; реплики закрываются в Close.
+func (s *PostgresStorage) SetReplicas(dbs []*sql.DB, checkInterval time.Duration) {
+	for _, db := range dbs {
+		r := &replica{db: db}
+		r.healthy.Store(true)
+		s.replicas = append(s.replicas, r)
+	}
+	if len(s.replicas) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopChecks = cancel
+	s.checks.Add(1)
+	go func() {
+		defer s.checks.Done()
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			s.checkReplicas(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *PostgresStorage) checkReplicas(ctx context.Context) {
+	for _, r := range s.replicas {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		r.healthy.Store(r.db.PingContext(ctx) == nil)
+		cancel()
+	}
+}
+
+// HealthyReplicas возвращает число доступных реплик и общее число реплик.
+func (s *PostgresStorage) HealthyReplicas() (healthy, total int) {
+	for _, r := range s.replicas {
+		if r.healthy.Load() {
+			healthy++
+		}
+	}
+	return healthy, len(s.replicas)
+}
+
+// reader выбирает базу для чтения: следующую доступную реплику по кругу, а если
+// доступных нет или в запросе уже была запись, — основную. Возвращает nil вместо реплики,
+// если выбрана основная база.
+func (s *PostgresStorage) reader(ctx context.Context) (*sql.DB, *replica) {
+	if len(s.replicas) == 0 || pinnedToPrimary(ctx) {
+		return s.db, nil
+	}
+	start := s.next.Add(1)
+	for i := range uint64(len(s.replicas)) {
+		r := s.replicas[(start+i)%uint64(len(s.replicas))]
+		if r.healthy.Load() {
+			return r.db, r
+		}
+	}
+	return s.db, nil
+}
+
+// queryRow читает одну строку с реплики. При ошибке соединения реплика помечается
+// недоступной, и запрос повторяется на основной базе; при missOnPrimary туда же
+// повторяется и пустой результат — строка могла ещё не дойти до реплики.
+func (s *PostgresStorage) queryRow(ctx context.Context, missOnPrimary bool, dest []any, query string, args ...any) error {
+	db, r := s.reader(ctx)
+	err := db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if r == nil || err == nil || ctx.Err() != nil {
+		return err
+	}
+	if err == sql.ErrNoRows {
+		if !missOnPrimary {
+			return err
+		}
+	} else {
+		r.healthy.Store(false)
+	}
+	return s.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+}
+
+// queryRows выполняет запрос на реплике и читает строки через scan. При ошибке реплика
+// помечается недоступной, и запрос с чтением повторяется на основной базе.
+func (s *PostgresStorage) queryRows(ctx context.Context, scan func(*sql.Rows) error, query string, args ...any) error {
+	db, r := s.reader(ctx)
+	err := runQuery(ctx, db, scan, query, args...)
+	if r == nil || err == nil || ctx.Err() != nil {
+		return err
+	}
+	r.healthy.Store(false)
+	return runQuery(ctx, s.db, scan, query, args...)
+}
+
+func runQuery(ctx context.Context, db *sql.DB, scan func(*sql.Rows) error, query string, args ...any) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return scan(rows)
+}
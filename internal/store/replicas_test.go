@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dron1337/shortener/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+// Запросы чтения PostgresStorage переносимы, поэтому в тестах маршрутизации
+// основная база и реплики — отдельные файлы SQLite с разным содержимым.
+func testReplicaDB(t *testing.T, keys ...string) *sql.DB {
+	t.Helper()
+	s, err := OpenSQLite(SQLiteScheme + filepath.Join(t.TempDir(), "urls.db"))
+	require.NoError(t, err)
+	for _, key := range keys {
+		url := "https://" + key + ".example.com"
+		require.NoError(t, s.Save(context.Background(), "user", url, url, key))
+	}
+	return s.DB()
+}
+
+func TestPostgresStorage_Replicas(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	ctx := context.Background()
+
+	t.Run("Reads go to replicas in turn", func(t *testing.T) {
+		s := NewPostgresStorage(testReplicaDB(t, "primary"))
+		s.SetReplicas([]*sql.DB{testReplicaDB(t, "r1"), testReplicaDB(t, "r2")}, time.Hour)
+		defer s.Close(ctx)
+
+		found := 0
+		for range 4 {
+			if s.GetShortKey(ctx, "https://r1.example.com") != "" {
+				found++
+			}
+		}
+		assert.Equal(t, 2, found, "ключ есть только на одной из двух реплик")
+		assert.Empty(t, s.GetShortKey(ctx, "https://primary.example.com"), "GetShortKey не повторяется на основной базе")
+	})
+
+	t.Run("Missing URL is retried on primary", func(t *testing.T) {
+		s := NewPostgresStorage(testReplicaDB(t, "fresh"))
+		s.SetReplicas([]*sql.DB{testReplicaDB(t)}, time.Hour)
+		defer s.Close(ctx)
+
+		u, err := s.GetOriginalURL(ctx, "fresh")
+		require.NoError(t, err)
+		assert.Equal(t, "https://fresh.example.com", u)
+		_, err = s.GetOriginalURL(ctx, "missing")
+		assert.ErrorIs(t, err, errors.ErrURLNotFound)
+	})
+
+	t.Run("Read after write is pinned to primary", func(t *testing.T) {
+		s := NewPostgresStorage(testReplicaDB(t))
+		s.SetReplicas([]*sql.DB{testReplicaDB(t)}, time.Hour)
+		defer s.Close(ctx)
+
+		reqCtx := TrackWrites(ctx)
+		assert.Empty(t, s.GetShortKey(reqCtx, "https://new.example.com"))
+		require.NoError(t, s.Save(reqCtx, "user", "https://new.example.com", "https://new.example.com", "new"))
+		assert.Equal(t, "new", s.GetShortKey(reqCtx, "https://new.example.com"))
+		urls, err := s.GetURLsByUser(reqCtx, "user", "http://localhost")
+		require.NoError(t, err)
+		assert.Len(t, urls, 1)
+
+		assert.Empty(t, s.GetShortKey(TrackWrites(ctx), "https://new.example.com"), "другой запрос читает реплику")
+		assert.Equal(t, "new", s.GetShortKey(WithPrimary(ctx), "https://new.example.com"))
+	})
+
+	t.Run("Failed replica falls back to primary", func(t *testing.T) {
+		replica := testReplicaDB(t)
+		s := NewPostgresStorage(testReplicaDB(t, "key"))
+		s.SetReplicas([]*sql.DB{replica}, time.Hour)
+		defer s.Close(ctx)
+
+		require.NoError(t, replica.Close())
+		assert.Equal(t, "key", s.GetShortKey(ctx, "https://key.example.com"))
+		healthy, total := s.HealthyReplicas()
+		assert.Equal(t, 0, healthy)
+		assert.Equal(t, 1, total)
+	})
+
+	t.Run("Failed replica falls back to primary for user URLs", func(t *testing.T) {
+		replica := testReplicaDB(t)
+		s := NewPostgresStorage(testReplicaDB(t, "key"))
+		s.SetReplicas([]*sql.DB{replica}, time.Hour)
+		defer s.Close(ctx)
+
+		require.NoError(t, replica.Close())
+		urls, err := s.GetURLsByUser(ctx, "user", "http://localhost")
+		require.NoError(t, err)
+		require.Len(t, urls, 1)
+		assert.Equal(t, "http://localhost/key", urls[0].ShortURL)
+		healthy, _ := s.HealthyReplicas()
+		assert.Equal(t, 0, healthy)
+	})
+
+	t.Run("Health check restores replica", func(t *testing.T) {
+		db := testReplicaDB(t)
+		s := NewPostgresStorage(testReplicaDB(t))
+		s.replicas = append(s.replicas, &replica{db: db})
+		defer s.Close(ctx)
+
+		healthy, _ := s.HealthyReplicas()
+		assert.Equal(t, 0, healthy)
+		s.checkReplicas(ctx)
+		healthy, _ = s.HealthyReplicas()
+		assert.Equal(t, 1, healthy)
+	})
+}
@@ -0,0 +1,551 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/dron1337/shortener/internal/errors"
+)
+
+// ShardHintLen — длина префикса ключа, который служит подсказкой шарда:
+// шард выбирается по хэшу префикса, поэтому его знает любой, у кого есть ключ,
+// а при изменении списка шардов ссылки переносятся целыми группами с общим префиксом.
+const ShardHintLen = 2
+
+// ringVNodes — число точек каждого шарда на кольце; сглаживает неравномерность хэша.
+const ringVNodes = 128
+
+// ShardHint возвращает подсказку шарда из ключа.
+func ShardHint(shortKey string) string {
+	if len(shortKey) > ShardHintLen {
+		return shortKey[:ShardHintLen]
+	}
+	return shortKey
+}
+
+func hash32(s string) uint32 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	// Финальное перемешивание из MurmurHash3: у FNV коротких строк старшие биты почти совпадают
+	sum := h.Sum64()
+	sum ^= sum >> 33
+	sum *= 0xff51afd7ed558ccd
+	sum ^= sum >> 33
+	sum *= 0xc4ceb9fe1a85ec53
+	sum ^= sum >> 33
+	return uint32(sum)
+}
+
+// HashRing — консистентное хэширование: при добавлении шарда к нему переходит
+// примерно 1/n ключей, остальные остаются на месте.
+type HashRing struct {
+	points []uint32
+	owners map[uint32]int
+}
+
+// NewHashRing строит кольцо для shards шардов. Точки шарда зависят только от его
+// номера, поэтому новые шарды добавляются в конец списка, а существующие не переставляются.
+func NewHashRing(shards int) *HashRing {
+	r := &HashRing{owners: make(map[uint32]int, shards*ringVNodes)}
+	for shard := range shards {
+		for v := range ringVNodes {
+			point := hash32("shard-" + strconv.Itoa(shard) + "#" + strconv.Itoa(v))
+			if _, taken := r.owners[point]; taken {
+				continue
+			}
+			r.owners[point] = shard
+			r.points = append(r.points, point)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// Locate возвращает номер шарда для ключа.
+func (r *HashRing) Locate(shortKey string) int {
+	h := hash32(ShardHint(shortKey))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// ShardedStorage распределяет ссылки между несколькими базами Postgres по ключу.
+// Поиск по URL, списки пользователя и статистика опрашивают все шарды.
+// Рабочие пространства и участники хранятся в первом шарде, а строка пространства
+// копируется во все шарды, чтобы ссылки в них проходили проверку внешнего ключа.
+type ShardedStorage struct {
+	shards []*PostgresStorage
+	ring   *HashRing
+}
+
+func NewShardedStorage(shards []*PostgresStorage) *ShardedStorage {
+	return &ShardedStorage{shards: shards, ring: NewHashRing(len(shards))}
+}
+
+// OpenShards подключается ко всем шардам и создаёт в них недостающие таблицы.
+// Без любого из шардов часть ключей недоступна, поэтому ошибка одного закрывает остальные.
+func OpenShards(dsns []string, pool PoolConfig) (*ShardedStorage, error) {
+	shards := make([]*PostgresStorage, 0, len(dsns))
+	for i, dsn := range dsns {
+		db, err := CreateDBConnection(dsn, pool)
+		if err != nil {
+			for _, shard := range shards {
+				shard.Close(context.Background())
+			}
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+		shards = append(shards, NewPostgresStorage(db))
+	}
+	return NewShardedStorage(shards), nil
+}
+
+// Shards возвращает шарды в порядке конфигурации.
+func (s *ShardedStorage) Shards() []*PostgresStorage {
+	return s.shards
+}
+
+// ShardFor возвращает номер шарда для ключа.
+func (s *ShardedStorage) ShardFor(shortKey string) int {
+	return s.ring.Locate(shortKey)
+}
+
+func (s *ShardedStorage) shard(shortKey string) *PostgresStorage {
+	return s.shards[s.ShardFor(shortKey)]
+}
+
+// home — шард с рабочими пространствами.
+func (s *ShardedStorage) home() *PostgresStorage {
+	return s.shards[0]
+}
+
+// each выполняет fn на всех шардах параллельно и объединяет ошибки с номерами шардов.
+func (s *ShardedStorage) each(fn func(i int, shard *PostgresStorage) error) error {
+	errs := make([]error, len(s.shards))
+	var wg sync.WaitGroup
+	for i, shard := range s.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(i, shard); err != nil {
+				errs[i] = fmt.Errorf("shard %d: %w", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return stderrors.Join(errs...)
+}
+
+// groupByShard раскладывает ключи по шардам.
+func (s *ShardedStorage) groupByShard(keys []string) map[int][]string {
+	groups := make(map[int][]string)
+	for _, key := range keys {
+		i := s.ShardFor(key)
+		groups[i] = append(groups[i], key)
+	}
+	return groups
+}
+
+func (s *ShardedStorage) Save(ctx context.Context, userID, originalURL, canonicalURL, shortKey string) error {
+	return s.shard(shortKey).Save(ctx, userID, originalURL, canonicalURL, shortKey)
+}
+
+func (s *ShardedStorage) GetOriginalURL(ctx context.Context, shortKey string) (string, error) {
+	return s.shard(shortKey).GetOriginalURL(ctx, shortKey)
+}
+
+// GetShortKey ищет URL во всех шардах; при совпадении в нескольких побеждает шард с меньшим номером.
+func (s *ShardedStorage) GetShortKey(ctx context.Context, canonicalURL string) string {
+	found := make([]string, len(s.shards))
+	s.each(func(i int, shard *PostgresStorage) error {
+		found[i] = shard.GetShortKey(ctx, canonicalURL)
+		return nil
+	})
+	for _, key := range found {
+		if key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+func (s *ShardedStorage) GetURLsByUser(ctx context.Context, userID, baseURL string) ([]ResponseURLs, error) {
+	parts := make([][]ResponseURLs, len(s.shards))
+	err := s.each(func(i int, shard *PostgresStorage) (err error) {
+		parts[i], err = shard.GetURLsByUser(ctx, userID, baseURL)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result []ResponseURLs
+	for _, part := range parts {
+		result = append(result, part...)
+	}
+	return result, nil
+}
+
+// Stats суммирует ссылки шардов; пользователи с ссылками в нескольких шардах считаются один раз.
+func (s *ShardedStorage) Stats(ctx context.Context) (Stats, error) {
+	urls := make([]int, len(s.shards))
+	users := make([][]string, len(s.shards))
+	err := s.each(func(i int, shard *PostgresStorage) error {
+		stats, err := shard.Stats(ctx)
+		if err != nil {
+			return err
+		}
+		urls[i] = stats.URLs
+		users[i], err = shard.activeUsers(ctx)
+		return err
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+	var stats Stats
+	distinct := make(map[string]struct{})
+	for i := range s.shards {
+		stats.URLs += urls[i]
+		for _, u := range users[i] {
+			distinct[u] = struct{}{}
+		}
+	}
+	stats.Users = len(distinct)
+	return stats, nil
+}
+
+// DeleteUserURLs удаляет ключи в их шардах параллельно.
+func (s *ShardedStorage) DeleteUserURLs(ctx context.Context, userID string, urls []string) error {
+	groups := s.groupByShard(urls)
+	return s.each(func(i int, shard *PostgresStorage) error {
+		if keys := groups[i]; len(keys) > 0 {
+			return shard.DeleteUserURLs(ctx, userID, keys)
+		}
+		return nil
+	})
+}
+
+func (s *ShardedStorage) CheckConnection(ctx context.Context) error {
+	return s.each(func(_ int, shard *PostgresStorage) error {
+		return shard.CheckConnection(ctx)
+	})
+}
+
+// PendingDeletes возвращает суммарную глубину очередей удаления.
+func (s *ShardedStorage) PendingDeletes() int64 {
+	var n int64
+	for _, shard := range s.shards {
+		n += shard.PendingDeletes()
+	}
+	return n
+}
+
+func (s *ShardedStorage) Close(ctx context.Context) error {
+	return s.each(func(_ int, shard *PostgresStorage) error {
+		return shard.Close(ctx)
+	})
+}
+
+func (s *ShardedStorage) CreateWorkspace(ctx context.Context, ownerID, name string) (Workspace, error) {
+	ws, err := s.home().CreateWorkspace(ctx, ownerID, name)
+	if err != nil {
+		return Workspace{}, err
+	}
+	err = s.each(func(i int, shard *PostgresStorage) error {
+		if i == 0 {
+			return nil
+		}
+		return shard.ensureWorkspace(ctx, ws)
+	})
+	return ws, err
+}
+
+func (s *ShardedStorage) GetMemberRole(ctx context.Context, workspaceID, userID string) (Role, error) {
+	return s.home().GetMemberRole(ctx, workspaceID, userID)
+}
+
+func (s *ShardedStorage) GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error) {
+	return s.home().GetWorkspaceMembers(ctx, workspaceID)
+}
+
+func (s *ShardedStorage) SetWorkspaceMember(ctx context.Context, workspaceID, userID string, role Role) error {
+	return s.home().SetWorkspaceMember(ctx, workspaceID, userID, role)
+}
+
+func (s *ShardedStorage) RemoveWorkspaceMember(ctx context.Context, workspaceID, userID string) error {
+	return s.home().RemoveWorkspaceMember(ctx, workspaceID, userID)
+}
+
+func (s *ShardedStorage) SaveToWorkspace(ctx context.Context, workspaceID, userID, originalURL, canonicalURL, shortKey string) error {
+	shard := s.shard(shortKey)
+	if shard != s.home() {
+		// В шарде, добавленном после создания пространства, строки пространства ещё нет
+		ws, err := s.home().getWorkspace(ctx, workspaceID)
+		if err != nil {
+			return err
+		}
+		if err := shard.ensureWorkspace(ctx, ws); err != nil {
+			return err
+		}
+	}
+	return shard.SaveToWorkspace(ctx, workspaceID, userID, originalURL, canonicalURL, shortKey)
+}
+
+func (s *ShardedStorage) GetWorkspaceURLs(ctx context.Context, workspaceID, baseURL string) ([]ResponseURLs, error) {
+	if err := s.home().checkWorkspace(ctx, workspaceID); err != nil {
+		return nil, err
+	}
+	parts := make([][]ResponseURLs, len(s.shards))
+	err := s.each(func(i int, shard *PostgresStorage) (err error) {
+		parts[i], err = shard.GetWorkspaceURLs(ctx, workspaceID, baseURL)
+		// Пространство, созданное до добавления шарда, появится в нём при переносе ссылок
+		if stderrors.Is(err, errors.ErrWorkspaceNotFound) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result []ResponseURLs
+	for _, part := range parts {
+		result = append(result, part...)
+	}
+	return result, nil
+}
+
+func (s *ShardedStorage) DeleteWorkspaceURLs(ctx context.Context, workspaceID string, keys []string) error {
+	groups := s.groupByShard(keys)
+	return s.each(func(i int, shard *PostgresStorage) error {
+		if keys := groups[i]; len(keys) > 0 {
+			return shard.DeleteWorkspaceURLs(ctx, workspaceID, keys)
+		}
+		return nil
+	})
+}
+
+func (s *ShardedStorage) TransferURLs(ctx context.Context, userID, workspaceID string, keys []string) (int, error) {
+	ws, err := s.home().getWorkspace(ctx, workspaceID)
+	if err != nil {
+		return 0, err
+	}
+	groups := s.groupByShard(keys)
+	moved := make([]int, len(s.shards))
+	err = s.each(func(i int, shard *PostgresStorage) (err error) {
+		keys := groups[i]
+		if len(keys) == 0 {
+			return nil
+		}
+		if err := shard.ensureWorkspace(ctx, ws); err != nil {
+			return err
+		}
+		moved[i], err = shard.TransferURLs(ctx, userID, workspaceID, keys)
+		return err
+	})
+	total := 0
+	for _, n := range moved {
+		total += n
+	}
+	return total, err
+}
+
+// Export выгружает шарды по очереди.
+func (s *ShardedStorage) Export(ctx context.Context, fn func(Record) error) error {
+	for i, shard := range s.shards {
+		if err := shard.Export(ctx, fn); err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Import раскладывает пачку по шардам и загружает части параллельно.
+func (s *ShardedStorage) Import(ctx context.Context, records []Record, overwrite bool) (ImportResult, error) {
+	groups := make(map[int][]Record)
+	for _, rec := range records {
+		i := s.ShardFor(rec.ShortKey)
+		groups[i] = append(groups[i], rec)
+	}
+	results := make([]ImportResult, len(s.shards))
+	err := s.each(func(i int, shard *PostgresStorage) (err error) {
+		if len(groups[i]) > 0 {
+			results[i], err = shard.Import(ctx, groups[i], overwrite)
+		}
+		return err
+	})
+	var result ImportResult
+	for _, r := range results {
+		result.Add(r)
+	}
+	return result, err
+}
+
+// RebalanceStats — итог Rebalance.
+type RebalanceStats struct {
+	Scanned int
+	Moved   int
+}
+
+// Rebalance переносит ссылки, которые после изменения списка шардов оказались не в своём шарде.
+// Строка сначала записывается в новый шард и только потом удаляется из старого,
+// поэтому прерванный перенос можно запустить снова. При dryRun ничего не меняется.
+// progress, если задан, вызывается после каждой пачки из batchSize строк.
+func (s *ShardedStorage) Rebalance(ctx context.Context, batchSize int, dryRun bool, progress func(shard int, stats RebalanceStats)) (RebalanceStats, error) {
+	var total RebalanceStats
+	// Границы фиксируются заранее: строки, записанные после начала прохода,
+	// в том числе перенесённые им самим, уже размещены по новому кольцу
+	lastIDs := make([]int64, len(s.shards))
+	for i, shard := range s.shards {
+		var err error
+		if lastIDs[i], err = shard.lastRowID(ctx); err != nil {
+			return total, fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	for i, shard := range s.shards {
+		var afterID int64
+		for {
+			rows, err := shard.scanRows(ctx, afterID, lastIDs[i], batchSize)
+			if err != nil {
+				return total, fmt.Errorf("shard %d: %w", i, err)
+			}
+			if len(rows) == 0 {
+				break
+			}
+			for _, row := range rows {
+				afterID = row.id
+				total.Scanned++
+				target := s.ShardFor(row.ShortKey)
+				if target == i {
+					continue
+				}
+				if !dryRun {
+					if err := s.moveRow(ctx, shard, s.shards[target], row); err != nil {
+						return total, fmt.Errorf("move %q from shard %d to %d: %w", row.ShortKey, i, target, err)
+					}
+				}
+				total.Moved++
+			}
+			if progress != nil {
+				progress(i, total)
+			}
+		}
+	}
+	return total, nil
+}
+
+func (s *ShardedStorage) moveRow(ctx context.Context, from, to *PostgresStorage, row urlRow) error {
+	if row.WorkspaceID.Valid {
+		ws, err := from.getWorkspace(ctx, row.WorkspaceID.String)
+		if err != nil {
+			return err
+		}
+		if err := to.ensureWorkspace(ctx, ws); err != nil {
+			return err
+		}
+	}
+	if err := to.insertRow(ctx, row); err != nil {
+		return err
+	}
+	return from.deleteRow(ctx, row.ShortKey)
+}
+
+// urlRow — строка short_urls целиком, для переноса между базами.
+type urlRow struct {
+	id           int64
+	UserID       string
+	OriginalURL  string
+	ShortKey     string
+	IsDeleted    bool
+	CanonicalURL sql.NullString
+	WorkspaceID  sql.NullString
+	CreatedAt    sql.NullTime
+	UpdatedAt    sql.NullTime
+	DeletedAt    sql.NullTime
+}
+
+// activeUsers возвращает пользователей, у которых есть неудалённые ссылки.
+func (s *PostgresStorage) activeUsers(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT DISTINCT user_id FROM short_urls WHERE is_deleted IS NOT TRUE")
+	if err != nil {
+		return nil, fmt.Errorf("db users error: %w", err)
+	}
+	defer rows.Close()
+	var users []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		users = append(users, userID)
+	}
+	return users, rows.Err()
+}
+
+func (s *PostgresStorage) getWorkspace(ctx context.Context, workspaceID string) (Workspace, error) {
+	ws := Workspace{ID: workspaceID}
+	err := s.db.QueryRowContext(ctx,
+		"SELECT name, owner_id FROM workspaces WHERE id = $1", workspaceID).Scan(&ws.Name, &ws.OwnerID)
+	if err == sql.ErrNoRows {
+		return Workspace{}, errors.ErrWorkspaceNotFound
+	}
+	return ws, err
+}
+
+// ensureWorkspace добавляет строку пространства, если её ещё нет; участники в шард не копируются.
+func (s *PostgresStorage) ensureWorkspace(ctx context.Context, ws Workspace) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO workspaces (id, name, owner_id) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING",
+		ws.ID, ws.Name, ws.OwnerID)
+	return err
+}
+
+func (s *PostgresStorage) lastRowID(ctx context.Context) (int64, error) {
+	var id int64
+	err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(uuid), 0) FROM short_urls").Scan(&id)
+	return id, err
+}
+
+// scanRows читает до limit строк с uuid в диапазоне (afterID, untilID].
+func (s *PostgresStorage) scanRows(ctx context.Context, afterID, untilID int64, limit int) ([]urlRow, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT uuid, user_id, original_url, short_key, COALESCE(is_deleted, false), canonical_url, workspace_id,
+			created_at, updated_at, deleted_at
+		FROM short_urls WHERE uuid > $1 AND uuid <= $2 ORDER BY uuid LIMIT $3`, afterID, untilID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []urlRow
+	for rows.Next() {
+		var r urlRow
+		if err := rows.Scan(&r.id, &r.UserID, &r.OriginalURL, &r.ShortKey, &r.IsDeleted, &r.CanonicalURL, &r.WorkspaceID,
+			&r.CreatedAt, &r.UpdatedAt, &r.DeletedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+// insertRow записывает перенесённую строку; уже перенесённый ключ пропускается.
+func (s *PostgresStorage) insertRow(ctx context.Context, r urlRow) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO short_urls (user_id, original_url, short_key, is_deleted, canonical_url, workspace_id,
+			created_at, updated_at, deleted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (short_key) DO NOTHING`,
+		r.UserID, r.OriginalURL, r.ShortKey, r.IsDeleted, r.CanonicalURL, r.WorkspaceID,
+		r.CreatedAt, r.UpdatedAt, r.DeletedAt)
+	return err
+}
+
+func (s *PostgresStorage) deleteRow(ctx context.Context, shortKey string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM short_urls WHERE short_key = $1", shortKey)
+	return err
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKeys возвращает n ключей с разными подсказками шарда.
+func testKeys(n int) []string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%c%cKEY%03d", alphabet[i%len(alphabet)], alphabet[(i*7)%len(alphabet)], i)
+	}
+	return keys
+}
+
+func testShards(t *testing.T, n int) []*PostgresStorage {
+	t.Helper()
+	shards := make([]*PostgresStorage, n)
+	for i := range shards {
+		shards[i] = NewPostgresStorage(testReplicaDB(t))
+	}
+	return shards
+}
+
+func shardHas(t *testing.T, shard *PostgresStorage, key string) bool {
+	t.Helper()
+	var n int
+	require.NoError(t, shard.DB().QueryRow("SELECT COUNT(*) FROM short_urls WHERE short_key = $1", key).Scan(&n))
+	return n == 1
+}
+
+func TestHashRing(t *testing.T) {
+	keys := testKeys(1000)
+	three, four := NewHashRing(3), NewHashRing(4)
+
+	counts := make([]int, 3)
+	moved := 0
+	for _, key := range keys {
+		from, to := three.Locate(key), four.Locate(key)
+		assert.Equal(t, from, NewHashRing(3).Locate(key), "размещение детерминировано")
+		assert.Equal(t, three.Locate(ShardHint(key)+"other"), from, "шард определяется подсказкой")
+		counts[from]++
+		if from != to {
+			moved++
+			assert.Equal(t, 3, to, "ключи переходят только на новый шард")
+		}
+	}
+	for i, n := range counts {
+		assert.Greater(t, n, 150, "шард %d почти пуст", i)
+	}
+	assert.Less(t, moved, 450, "при добавлении шарда переезжает около четверти ключей")
+}
+
+func TestShardedStorage(t *testing.T) {
+	ctx := context.Background()
+	shards := testShards(t, 3)
+	s := NewShardedStorage(shards)
+	defer s.Close(ctx)
+
+	keys := testKeys(60)
+	for i, key := range keys {
+		url := "https://" + key + ".example.com"
+		require.NoError(t, s.Save(ctx, fmt.Sprintf("user-%d", i%2), url, url, key))
+	}
+
+	used := make(map[int]bool)
+	for _, key := range keys {
+		got, err := s.GetOriginalURL(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, "https://"+key+".example.com", got)
+		assert.True(t, shardHas(t, shards[s.ShardFor(key)], key))
+		used[s.ShardFor(key)] = true
+		assert.Equal(t, key, s.GetShortKey(ctx, "https://"+key+".example.com"))
+	}
+	assert.Len(t, used, 3, "ключи распределены по всем шардам")
+	assert.Empty(t, s.GetShortKey(ctx, "https://missing.example.com"))
+
+	urls, err := s.GetURLsByUser(ctx, "user-0", "http://localhost")
+	require.NoError(t, err)
+	assert.Len(t, urls, 30)
+
+	stats, err := s.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Stats{URLs: 60, Users: 2}, stats)
+	require.NoError(t, s.CheckConnection(ctx))
+}
+
+func TestShardedStorage_Rebalance(t *testing.T) {
+	ctx := context.Background()
+	shards := testShards(t, 3)
+	before := NewShardedStorage(shards[:2])
+
+	ws, err := before.CreateWorkspace(ctx, "owner", "team")
+	require.NoError(t, err)
+	keys := testKeys(100)
+	for _, key := range keys {
+		url := "https://" + key + ".example.com"
+		require.NoError(t, before.SaveToWorkspace(ctx, ws.ID, "owner", url, url, key))
+	}
+
+	after := NewShardedStorage(shards)
+	defer after.Close(ctx)
+	misplaced := 0
+	for _, key := range keys {
+		if after.ShardFor(key) == 2 {
+			misplaced++
+		}
+	}
+	require.Positive(t, misplaced)
+
+	stats, err := after.Rebalance(ctx, 7, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, RebalanceStats{Scanned: 100, Moved: misplaced}, stats)
+	assert.Empty(t, after.GetShortKey(ctx, "https://nothing.example.com"))
+
+	batches := 0
+	stats, err = after.Rebalance(ctx, 7, false, func(int, RebalanceStats) { batches++ })
+	require.NoError(t, err)
+	assert.Equal(t, RebalanceStats{Scanned: 100, Moved: misplaced}, stats)
+	assert.Positive(t, batches)
+
+	for _, key := range keys {
+		got, err := after.GetOriginalURL(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, "https://"+key+".example.com", got)
+		for i, shard := range shards {
+			assert.Equal(t, i == after.ShardFor(key), shardHas(t, shard, key), "ключ %s в шарде %d", key, i)
+		}
+	}
+	urls, err := after.GetWorkspaceURLs(ctx, ws.ID, "http://localhost")
+	require.NoError(t, err)
+	assert.Len(t, urls, 100)
+
+	stats, err = after.Rebalance(ctx, 7, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Moved, "повторный запуск ничего не переносит")
+}
@@ -4,28 +4,91 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/dron1337/shortener/internal/errors"
+	"github.com/google/uuid"
 )
 
 type InMemoryStorage struct {
-	mu   sync.RWMutex
-	data map[string]map[string]string
+	mu         sync.RWMutex
+	data       map[string]map[string]string
+	canonical  map[string]string
+	workspaces map[string]*memoryWorkspace
+	// times хранит время создания и изменения по ключу ссылки.
+	times map[string]Timestamps
+	// deleted — мягко удалённые ключи: ссылка больше не открывается, но ключ остаётся занятым.
+	deleted map[string]struct{}
+}
+type memoryWorkspace struct {
+	Workspace
+	members map[string]Role
+	keys    map[string]struct{}
 }
 type ResponseURLs struct {
 	OriginalURL string `json:"original_url"`
 	ShortURL    string `json:"short_url"`
+	Timestamps
+}
+
+// Timestamps — время создания, последнего изменения и удаления ссылки в UTC.
+// У ссылок, созданных до появления этих полей, время неизвестно и равно нулю.
+type Timestamps struct {
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+}
+
+// now возвращает время для Timestamps. Время передаётся в запросы параметром,
+// а не берётся из часов базы, чтобы все хранилища вели себя одинаково.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// created возвращает Timestamps только что сохранённой ссылки.
+func created() Timestamps {
+	t := now()
+	return Timestamps{CreatedAt: t, UpdatedAt: t}
+}
+
+// Stats — сводные показатели хранилища.
+type Stats struct {
+	URLs  int `json:"urls"`
+	Users int `json:"users"`
+}
+
+// Storage — операции со ссылками, общие для всех хранилищ.
+type Storage interface {
+	Save(ctx context.Context, userID, originalURL, canonicalURL, shortKey string) error
+	GetOriginalURL(ctx context.Context, shortKey string) (string, error)
+	GetShortKey(ctx context.Context, canonicalURL string) string
+	Stats(ctx context.Context) (Stats, error)
 }
 
+var (
+	_ Storage = (*InMemoryStorage)(nil)
+	_ Storage = (*FileStorage)(nil)
+	_ Storage = (*PostgresStorage)(nil)
+	_ Storage = (*SQLiteStorage)(nil)
+	_ Storage = (*BoltStorage)(nil)
+	_ Storage = (*ShardedStorage)(nil)
+)
+
 func NewInMemoryStorage() *InMemoryStorage {
 	return &InMemoryStorage{
-		data: make(map[string]map[string]string),
+		data:       make(map[string]map[string]string),
+		canonical:  make(map[string]string),
+		workspaces: make(map[string]*memoryWorkspace),
+		times:      make(map[string]Timestamps),
+		deleted:    make(map[string]struct{}),
 	}
 }
 
-func (s *InMemoryStorage) Save(ctx context.Context, userID, originalURL, shortKey string) error {
+func (s *InMemoryStorage) Save(ctx context.Context, userID, originalURL, canonicalURL, shortKey string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.canonical[shortKey] = canonicalURL
+	s.times[shortKey] = created()
 	if user, exists := s.data[userID]; exists {
 		user[shortKey] = originalURL
 	} else {
@@ -35,30 +98,52 @@ func (s *InMemoryStorage) Save(ctx context.Context, userID, originalURL, shortKe
 	return nil
 }
 
+func (s *InMemoryStorage) Stats(ctx context.Context) (Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var stats Stats
+	for _, urls := range s.data {
+		n := 0
+		for shortKey := range urls {
+			if _, deleted := s.deleted[shortKey]; !deleted {
+				n++
+			}
+		}
+		if n > 0 {
+			stats.Users++
+			stats.URLs += n
+		}
+	}
+	return stats, nil
+}
+
 func (s *InMemoryStorage) GetOriginalURL(ctx context.Context, shortKey string) (string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	for _, properties := range s.data {
 		if url, exists := properties[shortKey]; exists {
+			if _, deleted := s.deleted[shortKey]; deleted {
+				return "", errors.ErrURLDeleted
+			}
 			return url, nil
 		}
 	}
 	return "", errors.ErrURLNotFound
 }
-func (s *InMemoryStorage) GetShortKey(ctx context.Context, originalURL string) string {
+func (s *InMemoryStorage) GetShortKey(ctx context.Context, canonicalURL string) string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	for _, properties := range s.data {
-		for key, val := range properties {
-			if val == originalURL {
-				return key
-			}
+	for key, val := range s.canonical {
+		if val == canonicalURL {
+			return key
 		}
 	}
 	return ""
 }
 
 func (s *InMemoryStorage) GetURLsByUser(ctx context.Context, userID, baseURL string) []ResponseURLs {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	var result []ResponseURLs
 	userData, exists := s.data[userID]
 	if !exists {
@@ -66,11 +151,243 @@ func (s *InMemoryStorage) GetURLsByUser(ctx context.Context, userID, baseURL str
 	}
 
 	for shortKey, originalURL := range userData {
+		if _, deleted := s.deleted[shortKey]; deleted {
+			continue
+		}
 		result = append(result, ResponseURLs{
 			OriginalURL: originalURL,
 			ShortURL:    fmt.Sprintf("%s/%s", baseURL, shortKey),
+			Timestamps:  s.times[shortKey],
 		})
 	}
 
 	return result
 }
+
+func (s *InMemoryStorage) CreateWorkspace(ctx context.Context, ownerID, name string) (Workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ws := &memoryWorkspace{
+		Workspace: Workspace{ID: uuid.New().String(), Name: name, OwnerID: ownerID},
+		members:   map[string]Role{ownerID: RoleAdmin},
+		keys:      make(map[string]struct{}),
+	}
+	s.workspaces[ws.ID] = ws
+	return ws.Workspace, nil
+}
+
+func (s *InMemoryStorage) GetMemberRole(ctx context.Context, workspaceID, userID string) (Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ws, exists := s.workspaces[workspaceID]
+	if !exists {
+		return "", errors.ErrWorkspaceNotFound
+	}
+	role, exists := ws.members[userID]
+	if !exists {
+		return "", errors.ErrNotWorkspaceMember
+	}
+	return role, nil
+}
+
+func (s *InMemoryStorage) GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ws, exists := s.workspaces[workspaceID]
+	if !exists {
+		return nil, errors.ErrWorkspaceNotFound
+	}
+	members := make([]WorkspaceMember, 0, len(ws.members))
+	for userID, role := range ws.members {
+		members = append(members, WorkspaceMember{UserID: userID, Role: role})
+	}
+	return members, nil
+}
+
+func (s *InMemoryStorage) SetWorkspaceMember(ctx context.Context, workspaceID, userID string, role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ws, exists := s.workspaces[workspaceID]
+	if !exists {
+		return errors.ErrWorkspaceNotFound
+	}
+	ws.members[userID] = role
+	return nil
+}
+
+func (s *InMemoryStorage) RemoveWorkspaceMember(ctx context.Context, workspaceID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ws, exists := s.workspaces[workspaceID]
+	if !exists {
+		return errors.ErrWorkspaceNotFound
+	}
+	if _, exists := ws.members[userID]; !exists {
+		return errors.ErrNotWorkspaceMember
+	}
+	delete(ws.members, userID)
+	return nil
+}
+
+func (s *InMemoryStorage) SaveToWorkspace(ctx context.Context, workspaceID, userID, originalURL, canonicalURL, shortKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ws, exists := s.workspaces[workspaceID]
+	if !exists {
+		return errors.ErrWorkspaceNotFound
+	}
+	if _, exists := s.data[userID]; !exists {
+		s.data[userID] = make(map[string]string)
+	}
+	s.data[userID][shortKey] = originalURL
+	s.canonical[shortKey] = canonicalURL
+	s.times[shortKey] = created()
+	ws.keys[shortKey] = struct{}{}
+	return nil
+}
+
+func (s *InMemoryStorage) GetWorkspaceURLs(ctx context.Context, workspaceID, baseURL string) ([]ResponseURLs, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ws, exists := s.workspaces[workspaceID]
+	if !exists {
+		return nil, errors.ErrWorkspaceNotFound
+	}
+	var result []ResponseURLs
+	for _, userData := range s.data {
+		for shortKey, originalURL := range userData {
+			if _, deleted := s.deleted[shortKey]; deleted {
+				continue
+			}
+			if _, inWorkspace := ws.keys[shortKey]; inWorkspace {
+				result = append(result, ResponseURLs{
+					OriginalURL: originalURL,
+					ShortURL:    fmt.Sprintf("%s/%s", baseURL, shortKey),
+					Timestamps:  s.times[shortKey],
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *InMemoryStorage) DeleteWorkspaceURLs(ctx context.Context, workspaceID string, keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ws, exists := s.workspaces[workspaceID]
+	if !exists {
+		return errors.ErrWorkspaceNotFound
+	}
+	for _, key := range keys {
+		if _, inWorkspace := ws.keys[key]; !inWorkspace {
+			continue
+		}
+		if _, deleted := s.deleted[key]; deleted {
+			continue
+		}
+		s.deleted[key] = struct{}{}
+		t := s.times[key]
+		t.DeletedAt = now()
+		t.UpdatedAt = t.DeletedAt
+		s.times[key] = t
+	}
+	return nil
+}
+
+func (s *InMemoryStorage) TransferURLs(ctx context.Context, userID, workspaceID string, keys []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ws, exists := s.workspaces[workspaceID]
+	if !exists {
+		return 0, errors.ErrWorkspaceNotFound
+	}
+	moved := 0
+	for _, key := range keys {
+		if _, owned := s.data[userID][key]; !owned {
+			continue
+		}
+		if _, deleted := s.deleted[key]; deleted {
+			continue
+		}
+		// Ссылка может принадлежать только одному пространству
+		for _, other := range s.workspaces {
+			delete(other.keys, key)
+		}
+		ws.keys[key] = struct{}{}
+		t := s.times[key]
+		t.UpdatedAt = now()
+		s.times[key] = t
+		moved++
+	}
+	return moved, nil
+}
+
+// Export выгружает снимок ссылок вместе с мягко удалёнными.
+func (s *InMemoryStorage) Export(ctx context.Context, fn func(Record) error) error {
+	s.mu.RLock()
+	var records []Record
+	for userID, urls := range s.data {
+		for shortKey, originalURL := range urls {
+			_, deleted := s.deleted[shortKey]
+			records = append(records, Record{
+				ShortKey:     shortKey,
+				OriginalURL:  originalURL,
+				CanonicalURL: s.canonical[shortKey],
+				UserID:       userID,
+				IsDeleted:    deleted,
+				Timestamps:   s.times[shortKey],
+			})
+		}
+	}
+	s.mu.RUnlock()
+	for _, rec := range records {
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import сохраняет и удалённые ссылки: их ключи остаются занятыми, а переход отвечает 410.
+func (s *InMemoryStorage) Import(ctx context.Context, records []Record, overwrite bool) (ImportResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result ImportResult
+	for _, rec := range records {
+		owner, exists := s.owner(rec.ShortKey)
+		switch {
+		case exists && !overwrite:
+			result.Skipped++
+			continue
+		case exists:
+			delete(s.data[owner], rec.ShortKey)
+			delete(s.deleted, rec.ShortKey)
+			for _, ws := range s.workspaces {
+				delete(ws.keys, rec.ShortKey)
+			}
+			result.Overwritten++
+		default:
+			result.Imported++
+		}
+		if _, ok := s.data[rec.UserID]; !ok {
+			s.data[rec.UserID] = make(map[string]string)
+		}
+		s.data[rec.UserID][rec.ShortKey] = rec.OriginalURL
+		if rec.IsDeleted {
+			s.deleted[rec.ShortKey] = struct{}{}
+		}
+		s.canonical[rec.ShortKey] = canonicalOrOriginal(rec)
+		s.times[rec.ShortKey] = rec.Timestamps
+	}
+	return result, nil
+}
+
+// owner возвращает пользователя, которому принадлежит ключ.
+func (s *InMemoryStorage) owner(shortKey string) (string, bool) {
+	for userID, urls := range s.data {
+		if _, ok := urls[shortKey]; ok {
+			return userID, true
+		}
+	}
+	return "", false
+}
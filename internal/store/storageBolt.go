@@ -0,0 +1,401 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dron1337/shortener/internal/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltScheme — префикс DATABASE_DSN, который выбирает встроенное хранилище bbolt.
+const BoltScheme = "bolt://"
+
+// IsBoltDSN сообщает, указывает ли DSN на файл bbolt.
+func IsBoltDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, BoltScheme)
+}
+
+// Бакеты BoltStorage. Вложенные бакеты users и workspaces хранят множества ключей
+// пользователя и пространства, чтобы выборки не просматривали все ссылки.
+var (
+	boltURLs          = []byte("urls")           // short_key → boltRecord
+	boltCanonical     = []byte("canonical")      // canonical_url → short_key
+	boltUsers         = []byte("users")          // user_id → {short_key}
+	boltWorkspaces    = []byte("workspaces")     // id → Workspace
+	boltMembers       = []byte("members")        // workspace_id → {user_id → role}
+	boltWorkspaceURLs = []byte("workspace_urls") // workspace_id → {short_key}
+)
+
+type boltRecord struct {
+	OriginalURL  string `json:"original_url"`
+	CanonicalURL string `json:"canonical_url,omitempty"`
+	UserID       string `json:"user_id"`
+	WorkspaceID  string `json:"workspace_id,omitempty"`
+	IsDeleted    bool   `json:"is_deleted,omitempty"`
+	Timestamps
+}
+
+// BatchItem — ссылка для SaveBatch.
+type BatchItem struct {
+	OriginalURL  string
+	CanonicalURL string
+	ShortKey     string
+}
+
+// BoltStorage хранит ссылки в одном файле bbolt (B+-дерево с транзакциями):
+// поиск по ключу, URL и пользователю — O(log n), в отличие от FileStorage.
+// Удаление мягкое, как в PostgresStorage.
+type BoltStorage struct {
+	db *bolt.DB
+}
+
+// OpenBolt открывает файл по DSN вида bolt:///path/to/urls.db и создаёт бакеты.
+// Файл блокируется: второй процесс получит ошибку через секунду ожидания.
+func OpenBolt(dsn string) (*BoltStorage, error) {
+	path := strings.TrimPrefix(dsn, BoltScheme)
+	if path == "" {
+		return nil, fmt.Errorf("missing bolt database path")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening bolt database: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltURLs, boltCanonical, boltUsers, boltWorkspaces, boltMembers, boltWorkspaceURLs} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStorage{db: db}, nil
+}
+
+// Close закрывает файл. Фоновых операций у BoltStorage нет, поэтому ctx не используется.
+func (s *BoltStorage) Close(ctx context.Context) error {
+	return s.db.Close()
+}
+
+// CheckConnection проверяет, что файл открыт и читается.
+func (s *BoltStorage) CheckConnection(ctx context.Context) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltURLs) == nil {
+			return fmt.Errorf("bucket %s is missing", boltURLs)
+		}
+		return nil
+	})
+}
+
+func getRecord(tx *bolt.Tx, shortKey string) (boltRecord, bool, error) {
+	var rec boltRecord
+	data := tx.Bucket(boltURLs).Get([]byte(shortKey))
+	if data == nil {
+		return rec, false, nil
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, false, fmt.Errorf("corrupted record %q: %w", shortKey, err)
+	}
+	return rec, true, nil
+}
+
+func putRecord(tx *bolt.Tx, shortKey string, rec boltRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(boltURLs).Put([]byte(shortKey), data)
+}
+
+// addToSet добавляет key во вложенный бакет owner бакета parent.
+func addToSet(tx *bolt.Tx, parent []byte, owner, key string) error {
+	set, err := tx.Bucket(parent).CreateBucketIfNotExists([]byte(owner))
+	if err != nil {
+		return err
+	}
+	return set.Put([]byte(key), nil)
+}
+
+// insert сохраняет новую ссылку; занятый ключ — ошибка, как нарушение UNIQUE в Postgres.
+func insert(tx *bolt.Tx, shortKey string, rec boltRecord) error {
+	if _, ok, err := getRecord(tx, shortKey); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("short key %q already exists", shortKey)
+	}
+	if err := putRecord(tx, shortKey, rec); err != nil {
+		return err
+	}
+	if rec.CanonicalURL != "" {
+		canonical := tx.Bucket(boltCanonical)
+		// Первая ссылка на URL остаётся основной, как в GetShortKey Postgres с LIMIT 1
+		if canonical.Get([]byte(rec.CanonicalURL)) == nil {
+			if err := canonical.Put([]byte(rec.CanonicalURL), []byte(shortKey)); err != nil {
+				return err
+			}
+		}
+	}
+	// У записей из файлового хранилища нет владельца, а bbolt не принимает пустое имя бакета
+	if rec.UserID != "" {
+		if err := addToSet(tx, boltUsers, rec.UserID, shortKey); err != nil {
+			return err
+		}
+	}
+	if rec.WorkspaceID != "" {
+		return addToSet(tx, boltWorkspaceURLs, rec.WorkspaceID, shortKey)
+	}
+	return nil
+}
+
+func (s *BoltStorage) Save(ctx context.Context, userID, originalURL, canonicalURL, shortKey string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return insert(tx, shortKey, boltRecord{OriginalURL: originalURL, CanonicalURL: canonicalURL, UserID: userID, Timestamps: created()})
+	})
+}
+
+// SaveBatch сохраняет все ссылки одной транзакцией: при ошибке не сохраняется ни одна.
+func (s *BoltStorage) SaveBatch(ctx context.Context, userID string, items []BatchItem) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, item := range items {
+			rec := boltRecord{OriginalURL: item.OriginalURL, CanonicalURL: item.CanonicalURL, UserID: userID, Timestamps: created()}
+			if err := insert(tx, item.ShortKey, rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStorage) GetOriginalURL(ctx context.Context, shortKey string) (string, error) {
+	var rec boltRecord
+	var ok bool
+	err := s.db.View(func(tx *bolt.Tx) (err error) {
+		rec, ok, err = getRecord(tx, shortKey)
+		return err
+	})
+	switch {
+	case err != nil:
+		return "", fmt.Errorf("bolt get error: %w", err)
+	case !ok:
+		return "", errors.ErrURLNotFound
+	case rec.IsDeleted:
+		return "", errors.ErrURLDeleted
+	}
+	return rec.OriginalURL, nil
+}
+
+func (s *BoltStorage) GetShortKey(ctx context.Context, canonicalURL string) string {
+	var shortKey string
+	s.db.View(func(tx *bolt.Tx) error {
+		shortKey = string(tx.Bucket(boltCanonical).Get([]byte(canonicalURL)))
+		return nil
+	})
+	return shortKey
+}
+
+// GetURLsByUser возвращает неудалённые ссылки пользователя в порядке ключей.
+func (s *BoltStorage) GetURLsByUser(ctx context.Context, userID, baseURL string) ([]ResponseURLs, error) {
+	var result []ResponseURLs
+	err := s.db.View(func(tx *bolt.Tx) error {
+		set := tx.Bucket(boltUsers).Bucket([]byte(userID))
+		if set == nil {
+			return nil
+		}
+		return set.ForEach(func(key, _ []byte) error {
+			rec, ok, err := getRecord(tx, string(key))
+			if err != nil || !ok || rec.IsDeleted {
+				return err
+			}
+			result = append(result, ResponseURLs{
+				OriginalURL: rec.OriginalURL,
+				ShortURL:    fmt.Sprintf("%s/%s", baseURL, key),
+				Timestamps:  Timestamps{CreatedAt: rec.CreatedAt, UpdatedAt: rec.UpdatedAt},
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bolt list error: %w", err)
+	}
+	return result, nil
+}
+
+// DeleteUserURLs помечает удалёнными ссылки, принадлежащие пользователю; чужие ключи пропускаются.
+func (s *BoltStorage) DeleteUserURLs(ctx context.Context, userID string, urls []string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		set := tx.Bucket(boltUsers).Bucket([]byte(userID))
+		if set == nil {
+			return nil
+		}
+		for _, key := range urls {
+			if set.Get([]byte(key)) == nil {
+				continue
+			}
+			if err := markDeleted(tx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func markDeleted(tx *bolt.Tx, shortKey string) error {
+	rec, ok, err := getRecord(tx, shortKey)
+	if err != nil || !ok || rec.IsDeleted {
+		return err
+	}
+	rec.IsDeleted = true
+	rec.DeletedAt = now()
+	rec.UpdatedAt = rec.DeletedAt
+	return putRecord(tx, shortKey, rec)
+}
+
+// Stats считает неудалённые ссылки и пользователей, у которых они есть.
+func (s *BoltStorage) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	users := make(map[string]struct{})
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltURLs).ForEach(func(key, data []byte) error {
+			var rec boltRecord
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return fmt.Errorf("corrupted record %q: %w", key, err)
+			}
+			if !rec.IsDeleted {
+				stats.URLs++
+				users[rec.UserID] = struct{}{}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return Stats{}, fmt.Errorf("bolt stats error: %w", err)
+	}
+	stats.Users = len(users)
+	return stats, nil
+}
+
+// boltExportBatch — число ссылок, читаемых одной транзакцией Export: долгая
+// транзакция чтения мешала бы файлу расти при записи.
+const boltExportBatch = 1000
+
+// Export читает ссылки по порядку ключей короткими транзакциями.
+func (s *BoltStorage) Export(ctx context.Context, fn func(Record) error) error {
+	var after []byte
+	for {
+		var batch []Record
+		err := s.db.View(func(tx *bolt.Tx) error {
+			c := tx.Bucket(boltURLs).Cursor()
+			key, data := c.First()
+			if after != nil {
+				key, data = c.Seek(after)
+				if key != nil && string(key) == string(after) {
+					key, data = c.Next()
+				}
+			}
+			for ; key != nil && len(batch) < boltExportBatch; key, data = c.Next() {
+				var rec boltRecord
+				if err := json.Unmarshal(data, &rec); err != nil {
+					return fmt.Errorf("corrupted record %q: %w", key, err)
+				}
+				batch = append(batch, Record{
+					ShortKey:     string(key),
+					OriginalURL:  rec.OriginalURL,
+					CanonicalURL: rec.CanonicalURL,
+					UserID:       rec.UserID,
+					IsDeleted:    rec.IsDeleted,
+					Timestamps:   rec.Timestamps,
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("bolt export error: %w", err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		for _, rec := range batch {
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+		after = []byte(batch[len(batch)-1].ShortKey)
+	}
+}
+
+// Import загружает пачку одной транзакцией.
+func (s *BoltStorage) Import(ctx context.Context, records []Record, overwrite bool) (ImportResult, error) {
+	var result ImportResult
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		result = ImportResult{}
+		for _, r := range records {
+			old, exists, err := getRecord(tx, r.ShortKey)
+			if err != nil {
+				return err
+			}
+			if exists {
+				if !overwrite {
+					result.Skipped++
+					continue
+				}
+				if err := remove(tx, r.ShortKey, old); err != nil {
+					return err
+				}
+				result.Overwritten++
+			} else {
+				result.Imported++
+			}
+			rec := boltRecord{
+				OriginalURL:  r.OriginalURL,
+				CanonicalURL: canonicalOrOriginal(r),
+				UserID:       r.UserID,
+				IsDeleted:    r.IsDeleted,
+				Timestamps:   r.Timestamps,
+			}
+			if err := insert(tx, r.ShortKey, rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportResult{}, err
+	}
+	return result, nil
+}
+
+// remove удаляет ссылку вместе с записями в индексах.
+func remove(tx *bolt.Tx, shortKey string, rec boltRecord) error {
+	key := []byte(shortKey)
+	canonical := tx.Bucket(boltCanonical)
+	if rec.CanonicalURL != "" && string(canonical.Get([]byte(rec.CanonicalURL))) == shortKey {
+		if err := canonical.Delete([]byte(rec.CanonicalURL)); err != nil {
+			return err
+		}
+	}
+	if set := tx.Bucket(boltUsers).Bucket([]byte(rec.UserID)); set != nil {
+		if err := set.Delete(key); err != nil {
+			return err
+		}
+	}
+	if rec.WorkspaceID != "" {
+		if set := tx.Bucket(boltWorkspaceURLs).Bucket([]byte(rec.WorkspaceID)); set != nil {
+			if err := set.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Bucket(boltURLs).Delete(key)
+}
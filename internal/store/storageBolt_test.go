@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/dron1337/shortener/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltStorage(t *testing.T) {
+	ctx := context.Background()
+	dsn := BoltScheme + filepath.Join(t.TempDir(), "data", "urls.db")
+	s, err := OpenBolt(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close(ctx) })
+
+	t.Run("Save and get", func(t *testing.T) {
+		require.NoError(t, s.Save(ctx, "user1", "https://Example.com/a", "https://example.com/a", "abc"))
+		u, err := s.GetOriginalURL(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, "https://Example.com/a", u)
+		assert.Equal(t, "abc", s.GetShortKey(ctx, "https://example.com/a"))
+		assert.Empty(t, s.GetShortKey(ctx, "https://example.com/missing"))
+
+		assert.Error(t, s.Save(ctx, "user1", "https://other.com", "https://other.com", "abc"), "ключ уникален")
+		_, err = s.GetOriginalURL(ctx, "missing")
+		assert.ErrorIs(t, err, errors.ErrURLNotFound)
+	})
+
+	t.Run("Batch save is atomic", func(t *testing.T) {
+		err := s.SaveBatch(ctx, "user1", []BatchItem{
+			{OriginalURL: "https://batch.com/1", CanonicalURL: "https://batch.com/1", ShortKey: "b1"},
+			{OriginalURL: "https://batch.com/2", CanonicalURL: "https://batch.com/2", ShortKey: "abc"},
+		})
+		assert.Error(t, err)
+		_, err = s.GetOriginalURL(ctx, "b1")
+		assert.ErrorIs(t, err, errors.ErrURLNotFound, "при ошибке не сохраняется ни одна ссылка")
+
+		require.NoError(t, s.SaveBatch(ctx, "user1", []BatchItem{
+			{OriginalURL: "https://batch.com/1", CanonicalURL: "https://batch.com/1", ShortKey: "b1"},
+			{OriginalURL: "https://batch.com/2", CanonicalURL: "https://batch.com/2", ShortKey: "b2"},
+		}))
+		assert.Equal(t, "b2", s.GetShortKey(ctx, "https://batch.com/2"))
+		urls, err := s.GetURLsByUser(ctx, "user1", "http://localhost")
+		require.NoError(t, err)
+		assert.Len(t, urls, 3)
+	})
+
+	t.Run("Soft delete", func(t *testing.T) {
+		require.NoError(t, s.Save(ctx, "user2", "https://example.com/b", "https://example.com/b", "del"))
+		require.NoError(t, s.DeleteUserURLs(ctx, "user1", []string{"del"}), "чужие ссылки не удаляются")
+		_, err := s.GetOriginalURL(ctx, "del")
+		require.NoError(t, err)
+
+		require.NoError(t, s.DeleteUserURLs(ctx, "user2", []string{"del", "missing"}))
+		_, err = s.GetOriginalURL(ctx, "del")
+		assert.ErrorIs(t, err, errors.ErrURLDeleted)
+		urls, err := s.GetURLsByUser(ctx, "user2", "http://localhost")
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		stats, err := s.Stats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, Stats{URLs: 3, Users: 1}, stats)
+	})
+
+	t.Run("Workspaces", func(t *testing.T) {
+		ws, err := s.CreateWorkspace(ctx, "owner", "team")
+		require.NoError(t, err)
+		role, err := s.GetMemberRole(ctx, ws.ID, "owner")
+		require.NoError(t, err)
+		assert.Equal(t, RoleAdmin, role)
+
+		require.NoError(t, s.SetWorkspaceMember(ctx, ws.ID, "member", RoleViewer))
+		require.NoError(t, s.SetWorkspaceMember(ctx, ws.ID, "member", RoleEditor))
+		members, err := s.GetWorkspaceMembers(ctx, ws.ID)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []WorkspaceMember{{"owner", RoleAdmin}, {"member", RoleEditor}}, members)
+
+		require.NoError(t, s.SaveToWorkspace(ctx, ws.ID, "member", "https://ws.com", "https://ws.com", "ws1"))
+		n, err := s.TransferURLs(ctx, "user1", ws.ID, []string{"abc", "b1", "del"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		urls, err := s.GetWorkspaceURLs(ctx, ws.ID, "http://localhost")
+		require.NoError(t, err)
+		assert.Len(t, urls, 3)
+
+		require.NoError(t, s.DeleteWorkspaceURLs(ctx, ws.ID, []string{"ws1"}))
+		_, err = s.GetOriginalURL(ctx, "ws1")
+		assert.ErrorIs(t, err, errors.ErrURLDeleted)
+
+		require.NoError(t, s.RemoveWorkspaceMember(ctx, ws.ID, "member"))
+		_, err = s.GetMemberRole(ctx, ws.ID, "member")
+		assert.ErrorIs(t, err, errors.ErrNotWorkspaceMember)
+		_, err = s.GetMemberRole(ctx, "missing", "owner")
+		assert.ErrorIs(t, err, errors.ErrWorkspaceNotFound)
+		_, err = s.TransferURLs(ctx, "user1", "missing", []string{"b2"})
+		assert.ErrorIs(t, err, errors.ErrWorkspaceNotFound)
+	})
+
+	t.Run("Import ownerless record", func(t *testing.T) {
+		records := []Record{{ShortKey: "nouser", OriginalURL: "https://a.example"}}
+		result, err := s.Import(ctx, records, false)
+		require.NoError(t, err, "у записей файлового хранилища нет user_id")
+		assert.Equal(t, ImportResult{Imported: 1}, result)
+		result, err = s.Import(ctx, records, true)
+		require.NoError(t, err)
+		assert.Equal(t, ImportResult{Overwritten: 1}, result)
+		u, err := s.GetOriginalURL(ctx, "nouser")
+		require.NoError(t, err)
+		assert.Equal(t, "https://a.example", u)
+	})
+
+	t.Run("Reopen keeps data", func(t *testing.T) {
+		require.NoError(t, s.Close(ctx))
+		s, err = OpenBolt(dsn)
+		require.NoError(t, err)
+		u, err := s.GetOriginalURL(ctx, "b2")
+		require.NoError(t, err)
+		assert.Equal(t, "https://batch.com/2", u)
+		_, err = s.GetOriginalURL(ctx, "del")
+		assert.ErrorIs(t, err, errors.ErrURLDeleted)
+	})
+}
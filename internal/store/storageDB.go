@@ -3,9 +3,10 @@ package store
 import (
 	"context"
 	"database/sql"
+	stderrors "errors"
 	"fmt"
-	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dron1337/shortener/internal/errors"
@@ -14,22 +15,69 @@ import (
 
 type PostgresStorage struct {
 	db *sql.DB
+	// pendingDeletes — число ссылок, поставленных в очередь на удаление и ещё не обработанных.
+	pendingDeletes atomic.Int64
+	// workers отслеживает горутины удаления, чтобы Close дождался их.
+	workers sync.WaitGroup
+	// stopCtx отменяется в Close и прерывает удаление, не успевшее завершиться.
+	stopCtx context.Context
+	stop    context.CancelFunc
+
+	// replicas принимают чтения ссылок, next — счётчик для выбора по кругу.
+	replicas []*replica
+	next     atomic.Uint64
+	// checks отслеживает горутину проверки реплик, stopChecks её останавливает.
+	checks     sync.WaitGroup
+	stopChecks context.CancelFunc
 }
 
 func NewPostgresStorage(db *sql.DB) *PostgresStorage {
-	return &PostgresStorage{db: db}
+	s := &PostgresStorage{db: db}
+	s.stopCtx, s.stop = context.WithCancel(context.Background())
+	return s
 }
 
-func (s *PostgresStorage) Save(ctx context.Context, userID, originalURL, shortKey string) error {
-	fmt.Println("Save PostgresStorage")
+// Close дожидается фоновых удалений (не дольше срока ctx) и закрывает пулы соединений.
+func (s *PostgresStorage) Close(ctx context.Context) error {
+	if s.stopChecks != nil {
+		s.stopChecks()
+		s.checks.Wait()
+	}
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("pending deletes interrupted: %w", ctx.Err())
+	}
+	s.stop()
+	<-done
+	errs := []error{err, s.db.Close()}
+	for _, r := range s.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return stderrors.Join(errs...)
+}
+
+// DB возвращает пул соединений для компонентов, которым нужна та же база.
+func (s *PostgresStorage) DB() *sql.DB {
+	return s.db
+}
+
+func (s *PostgresStorage) Save(ctx context.Context, userID, originalURL, canonicalURL, shortKey string) error {
+	markWrite(ctx)
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 	_, err = tx.ExecContext(ctx,
-		"INSERT INTO short_urls (original_url, short_key,user_id) VALUES ($1, $2, $3)",
-		originalURL, shortKey, userID)
+		"INSERT INTO short_urls (original_url, canonical_url, short_key, user_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $5)",
+		originalURL, canonicalURL, shortKey, userID, now())
 	if err != nil {
 		tx.Rollback()
 		return err
@@ -40,9 +88,8 @@ func (s *PostgresStorage) Save(ctx context.Context, userID, originalURL, shortKe
 func (s *PostgresStorage) GetOriginalURL(ctx context.Context, shortKey string) (string, error) {
 	var originalURL string
 	var isDeleted bool
-	err := s.db.QueryRowContext(ctx,
-		"SELECT original_url,is_deleted FROM short_urls WHERE short_key = $1", shortKey).
-		Scan(&originalURL, &isDeleted)
+	err := s.queryRow(ctx, true, []any{&originalURL, &isDeleted},
+		"SELECT original_url,is_deleted FROM short_urls WHERE short_key = $1", shortKey)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return "", errors.ErrURLNotFound
@@ -54,23 +101,109 @@ func (s *PostgresStorage) GetOriginalURL(ctx context.Context, shortKey string) (
 	}
 	return originalURL, nil
 }
-func (s *PostgresStorage) GetShortKey(ctx context.Context, originalURL string) string {
+
+// Stats считает неудалённые ссылки и пользователей, у которых они есть.
+func (s *PostgresStorage) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*), COUNT(DISTINCT user_id) FROM short_urls WHERE is_deleted IS NOT TRUE").
+		Scan(&stats.URLs, &stats.Users)
+	if err != nil {
+		return Stats{}, fmt.Errorf("db stats error: %w", err)
+	}
+	return stats, nil
+}
+
+// GetShortKey ищет ссылку по каноническому URL. Записи, созданные до появления
+// canonical_url, сравниваются по исходному адресу.
+func (s *PostgresStorage) GetShortKey(ctx context.Context, canonicalURL string) string {
 	var existingShortKey string
-	s.db.QueryRowContext(ctx,
-		"SELECT short_key FROM short_urls WHERE original_url = $1", originalURL).Scan(&existingShortKey)
+	s.queryRow(ctx, false, []any{&existingShortKey},
+		"SELECT short_key FROM short_urls WHERE canonical_url = $1 OR (canonical_url IS NULL AND original_url = $1) LIMIT 1",
+		canonicalURL)
 	return existingShortKey
 }
-func CreateDBConnection(connStr string) (*sql.DB, error) {
+
+// GetURLsByUser возвращает неудалённые ссылки пользователя; читает с реплики, если она есть,
+// а при её ошибке — с основной базы.
+func (s *PostgresStorage) GetURLsByUser(ctx context.Context, userID, baseURL string) ([]ResponseURLs, error) {
+	var result []ResponseURLs
+	err := s.queryRows(ctx, func(rows *sql.Rows) (err error) {
+		result, err = scanURLs(rows, baseURL)
+		return err
+	}, "SELECT short_key, original_url, created_at, updated_at FROM short_urls WHERE user_id = $1 AND is_deleted = false ORDER BY uuid",
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("db list error: %w", err)
+	}
+	return result, nil
+}
+
+// scanURLs читает строки short_key, original_url, created_at, updated_at.
+func scanURLs(rows *sql.Rows, baseURL string) ([]ResponseURLs, error) {
+	defer rows.Close()
+	var result []ResponseURLs
+	for rows.Next() {
+		var shortKey, originalURL string
+		var createdAt, updatedAt sql.NullTime
+		if err := rows.Scan(&shortKey, &originalURL, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, ResponseURLs{
+			OriginalURL: originalURL,
+			ShortURL:    fmt.Sprintf("%s/%s", baseURL, shortKey),
+			Timestamps:  timestamps(createdAt, updatedAt, sql.NullTime{}),
+		})
+	}
+	return result, rows.Err()
+}
+
+// timestamps переводит столбцы времени в Timestamps; NULL — неизвестное время.
+func timestamps(createdAt, updatedAt, deletedAt sql.NullTime) Timestamps {
+	utc := func(t sql.NullTime) time.Time {
+		if !t.Valid {
+			return time.Time{}
+		}
+		return t.Time.UTC()
+	}
+	return Timestamps{CreatedAt: utc(createdAt), UpdatedAt: utc(updatedAt), DeletedAt: utc(deletedAt)}
+}
+
+// nullTime записывает нулевое время как NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// PoolConfig — настройки пула соединений с базой.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// OpenDB подключается к Postgres без изменения схемы; так открываются реплики,
+// которые доступны только для чтения.
+func OpenDB(connStr string, pool PoolConfig) (*sql.DB, error) {
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, fmt.Errorf("error opening DB connection: %w", err)
 	}
 	if err = db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("error pinging DB: %w", err)
 	}
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	return db, nil
+}
+
+// CreateDBConnection подключается к основной базе и создаёт недостающие таблицы.
+func CreateDBConnection(connStr string, pool PoolConfig) (*sql.DB, error) {
+	db, err := OpenDB(connStr, pool)
+	if err != nil {
+		return nil, err
+	}
 	_, err = db.Exec(`
 	CREATE TABLE IF NOT EXISTS short_urls (
 			uuid SERIAL PRIMARY KEY,
@@ -79,6 +212,26 @@ func CreateDBConnection(connStr string) (*sql.DB, error) {
 			short_key VARCHAR(10) UNIQUE NOT NULL,
 			is_deleted BOOLEAN DEFAULT FALSE
 	);
+	CREATE TABLE IF NOT EXISTS workspaces (
+			id CHAR(36) PRIMARY KEY,
+			name TEXT NOT NULL,
+			owner_id CHAR(36) NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS workspace_members (
+			workspace_id CHAR(36) NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+			user_id CHAR(36) NOT NULL,
+			role VARCHAR(16) NOT NULL,
+			PRIMARY KEY (workspace_id, user_id)
+	);
+	ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS workspace_id CHAR(36) REFERENCES workspaces(id);
+	CREATE INDEX IF NOT EXISTS short_urls_workspace_id_idx ON short_urls (workspace_id);
+	ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS canonical_url TEXT;
+	CREATE INDEX IF NOT EXISTS short_urls_canonical_url_idx ON short_urls (canonical_url);
+	CREATE INDEX IF NOT EXISTS short_urls_original_url_idx ON short_urls (original_url);
+	ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+	ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+	ALTER TABLE short_urls ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+	CREATE INDEX IF NOT EXISTS short_urls_user_id_created_at_idx ON short_urls (user_id, created_at);
 `)
 	if err != nil {
 		return nil, err
@@ -98,20 +251,32 @@ func (s *PostgresStorage) CheckConnection(ctx context.Context) error {
 
 	return nil
 }
-func (s *PostgresStorage) DeleteUserURLs(ctx context.Context, userID string, urls []string) {
+
+// PendingDeletes возвращает глубину очереди удаления.
+func (s *PostgresStorage) PendingDeletes() int64 {
+	return s.pendingDeletes.Load()
+}
+
+// DeleteUserURLs помечает ссылки пользователя удалёнными и возвращает ошибки всех пакетов.
+func (s *PostgresStorage) DeleteUserURLs(ctx context.Context, userID string, urls []string) error {
+	markWrite(ctx)
 	var wg sync.WaitGroup
 	chunks := splitURLs(urls, 4)
 	errCh := make(chan error, len(chunks))
+	s.pendingDeletes.Add(int64(len(urls)))
 	for _, batch := range chunks {
 		wg.Add(1)
+		s.workers.Add(1)
 		go func(batch []string) {
+			defer s.workers.Done()
 			defer wg.Done()
+			defer s.pendingDeletes.Add(-int64(len(batch)))
 			select {
 			case <-ctx.Done():
 				errCh <- ctx.Err()
 				return
 			default:
-				errCh <- s.updateDeleteUserURLs(userID, batch)
+				errCh <- s.updateDeleteUserURLs(s.stopCtx, userID, batch)
 			}
 		}(batch)
 	}
@@ -119,11 +284,13 @@ func (s *PostgresStorage) DeleteUserURLs(ctx context.Context, userID string, url
 		wg.Wait()
 		close(errCh)
 	}()
+	var errs []error
 	for err := range errCh {
 		if err != nil {
-			log.Printf("Ошибка при удалении: %v", err)
+			errs = append(errs, err)
 		}
 	}
+	return stderrors.Join(errs...)
 }
 func splitURLs(urls []string, n int) [][]string {
 	var chunks [][]string
@@ -136,16 +303,26 @@ func splitURLs(urls []string, n int) [][]string {
 
 	return chunks
 }
-func (s *PostgresStorage) updateDeleteUserURLs(userID string, batch []string) error {
-	tx, err := s.db.Begin()
+func (s *PostgresStorage) updateDeleteUserURLs(ctx context.Context, userID string, batch []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
-	query := "UPDATE short_urls SET is_deleted = true WHERE short_key = ANY($1) AND user_id = $2 AND is_deleted = false"
-	_, err = tx.Exec(query, pq.Array(batch), userID)
+	query := "UPDATE short_urls SET is_deleted = true, deleted_at = $3, updated_at = $3 WHERE short_key = ANY($1) AND user_id = $2 AND is_deleted = false"
+	_, err = tx.ExecContext(ctx, query, pq.Array(batch), userID, now())
 	if err != nil {
 		return err
 	}
 	return tx.Commit()
 }
+
+// Export выгружает ссылки из основной базы: реплика могла отстать.
+func (s *PostgresStorage) Export(ctx context.Context, fn func(Record) error) error {
+	return exportSQL(ctx, s.db, fn)
+}
+
+func (s *PostgresStorage) Import(ctx context.Context, records []Record, overwrite bool) (ImportResult, error) {
+	markWrite(ctx)
+	return importSQL(ctx, s.db, records, overwrite)
+}
@@ -8,12 +8,24 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+
+	"github.com/dron1337/shortener/internal/errors"
 )
 
 type FileStorage struct {
 	mu       sync.Mutex
 	filePath string
 }
+type fileRecord struct {
+	ShortKey     string `json:"short_key"`
+	OriginalURL  string `json:"original_url"`
+	CanonicalURL string `json:"canonical_url,omitempty"`
+	UserID       string `json:"user_id,omitempty"`
+	// IsDeleted есть только у ссылок, загруженных через Import; такие ссылки не находятся.
+	IsDeleted bool `json:"is_deleted,omitempty"`
+	// Время есть только у записей, сохранённых после появления этих полей.
+	Timestamps
+}
 
 func NewFileStorage(filePath string) *FileStorage {
 	return &FileStorage{
@@ -21,16 +33,15 @@ func NewFileStorage(filePath string) *FileStorage {
 	}
 }
 
-func (s *FileStorage) Save(ctx context.Context, userID, originalURL, shortKey string) error {
-	fmt.Println("Save FileStorage")
+func (s *FileStorage) Save(ctx context.Context, userID, originalURL, canonicalURL, shortKey string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	record := struct {
-		ShortKey    string `json:"short_key"`
-		OriginalURL string `json:"original_url"`
-	}{
-		ShortKey:    shortKey,
-		OriginalURL: originalURL,
+	record := fileRecord{
+		ShortKey:     shortKey,
+		OriginalURL:  originalURL,
+		CanonicalURL: canonicalURL,
+		UserID:       userID,
+		Timestamps:   created(),
 	}
 
 	data, err := json.Marshal(record)
@@ -83,16 +94,13 @@ func (s *FileStorage) GetOriginalURL(ctx context.Context, shortKey string) (stri
 		default:
 		}
 
-		var record struct {
-			ShortKey    string `json:"short_key"`
-			OriginalURL string `json:"original_url"`
-		}
+		var record fileRecord
 
 		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
 			continue // Пропускаем некорректные записи
 		}
 
-		if record.ShortKey == shortKey {
+		if record.ShortKey == shortKey && !record.IsDeleted {
 			return record.OriginalURL, nil
 		}
 	}
@@ -101,8 +109,253 @@ func (s *FileStorage) GetOriginalURL(ctx context.Context, shortKey string) (stri
 		return "", fmt.Errorf("scanner error: %w", err)
 	}
 
-	return "", fmt.Errorf("URL not found")
+	return "", errors.ErrURLNotFound
 }
-func (s *FileStorage) GetShortKey(ctx context.Context, originalURL string) string {
+
+// GetShortKey ищет ссылку по каноническому URL. У старых записей без canonical_url
+// сравнивается исходный адрес.
+func (s *FileStorage) GetShortKey(ctx context.Context, canonicalURL string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.Open(s.filePath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ""
+		}
+		var record fileRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.IsDeleted {
+			continue
+		}
+		stored := record.CanonicalURL
+		if stored == "" {
+			stored = record.OriginalURL
+		}
+		if stored == canonicalURL {
+			return record.ShortKey
+		}
+	}
 	return ""
 }
+
+// Stats считает записи в файле. Пользователи известны только для записей с user_id.
+func (s *FileStorage) Stats(ctx context.Context) (Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.Open(s.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Stats{}, nil
+		}
+		return Stats{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	var stats Stats
+	users := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return Stats{}, err
+		}
+		var record fileRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.IsDeleted {
+			continue
+		}
+		stats.URLs++
+		if record.UserID != "" {
+			users[record.UserID] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Stats{}, fmt.Errorf("scanner error: %w", err)
+	}
+	stats.Users = len(users)
+	return stats, nil
+}
+
+// CheckWritable проверяет, что файл хранилища можно открыть на запись.
+func (s *FileStorage) CheckWritable(ctx context.Context) error {
+	file, err := os.OpenFile(s.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Export передаёт fn записи файла по порядку; запись в файл на это время блокируется.
+func (s *FileStorage) Export(ctx context.Context, fn func(Record) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.Open(s.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var record fileRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if err := fn(Record{
+			ShortKey:     record.ShortKey,
+			OriginalURL:  record.OriginalURL,
+			CanonicalURL: record.CanonicalURL,
+			UserID:       record.UserID,
+			IsDeleted:    record.IsDeleted,
+			Timestamps:   record.Timestamps,
+		}); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("scanner error: %w", err)
+	}
+	return nil
+}
+
+// Import дописывает пачку в конец файла. Заменяемые записи сначала вырезаются
+// из файла, который для этого переписывается целиком.
+func (s *FileStorage) Import(ctx context.Context, records []Record, overwrite bool) (ImportResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, err := s.keys(ctx)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	var result ImportResult
+	replace := make(map[string]struct{})
+	pending := make(map[string]int)
+	var out []fileRecord
+	for _, rec := range records {
+		record := fileRecord{
+			ShortKey:     rec.ShortKey,
+			OriginalURL:  rec.OriginalURL,
+			CanonicalURL: rec.CanonicalURL,
+			UserID:       rec.UserID,
+			IsDeleted:    rec.IsDeleted,
+			Timestamps:   rec.Timestamps,
+		}
+		i, inBatch := pending[rec.ShortKey]
+		_, inFile := existing[rec.ShortKey]
+		switch {
+		case !inBatch && !inFile:
+			pending[rec.ShortKey] = len(out)
+			out = append(out, record)
+			result.Imported++
+		case !overwrite:
+			result.Skipped++
+		case inBatch:
+			out[i] = record
+			result.Overwritten++
+		default:
+			replace[rec.ShortKey] = struct{}{}
+			pending[rec.ShortKey] = len(out)
+			out = append(out, record)
+			result.Overwritten++
+		}
+	}
+	if len(replace) > 0 {
+		if err := s.rewriteWithout(replace); err != nil {
+			return ImportResult{}, err
+		}
+	}
+	if len(out) == 0 {
+		return result, nil
+	}
+	var buf []byte
+	for _, record := range out {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return ImportResult{}, err
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(s.filePath), 0755); err != nil {
+		return ImportResult{}, err
+	}
+	file, err := os.OpenFile(s.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	if _, err := file.Write(buf); err != nil {
+		file.Close()
+		return ImportResult{}, err
+	}
+	return result, file.Close()
+}
+
+// keys возвращает ключи всех записей файла.
+func (s *FileStorage) keys(ctx context.Context) (map[string]struct{}, error) {
+	keys := make(map[string]struct{})
+	file, err := os.Open(s.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return keys, nil
+		}
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var record fileRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err == nil {
+			keys[record.ShortKey] = struct{}{}
+		}
+	}
+	return keys, scanner.Err()
+}
+
+// rewriteWithout заменяет файл копией без записей с ключами из drop.
+func (s *FileStorage) rewriteWithout(drop map[string]struct{}) error {
+	src, err := os.Open(s.filePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(s.filePath), filepath.Base(s.filePath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	scanner := bufio.NewScanner(src)
+	for scanner.Scan() {
+		var record fileRecord
+		if json.Unmarshal(scanner.Bytes(), &record) == nil {
+			if _, ok := drop[record.ShortKey]; ok {
+				continue
+			}
+		}
+		w.Write(scanner.Bytes())
+		w.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.filePath)
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dron1337/shortener/internal/errors"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteScheme — префикс DATABASE_DSN, который выбирает SQLite вместо Postgres.
+const SQLiteScheme = "sqlite://"
+
+// IsSQLiteDSN сообщает, указывает ли DSN на файл SQLite.
+func IsSQLiteDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, SQLiteScheme)
+}
+
+// SQLiteStorage хранит ссылки в файле SQLite для развёртываний из одного узла.
+// Схема и мягкое удаление те же, что у PostgresStorage.
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+// sqliteMigrations повторяют изменения схемы Postgres; номер миграции — её индекс плюс один,
+// применённая версия хранится в PRAGMA user_version.
+var sqliteMigrations = []string{
+	`CREATE TABLE short_urls (
+		uuid INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id CHAR(36) NOT NULL,
+		original_url TEXT NOT NULL,
+		short_key VARCHAR(10) UNIQUE NOT NULL,
+		is_deleted BOOLEAN NOT NULL DEFAULT FALSE
+	);
+	CREATE TABLE workspaces (
+		id CHAR(36) PRIMARY KEY,
+		name TEXT NOT NULL,
+		owner_id CHAR(36) NOT NULL
+	);
+	CREATE TABLE workspace_members (
+		workspace_id CHAR(36) NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+		user_id CHAR(36) NOT NULL,
+		role VARCHAR(16) NOT NULL,
+		PRIMARY KEY (workspace_id, user_id)
+	);`,
+	`ALTER TABLE short_urls ADD COLUMN workspace_id CHAR(36) REFERENCES workspaces(id);
+	CREATE INDEX short_urls_workspace_id_idx ON short_urls (workspace_id);`,
+	`ALTER TABLE short_urls ADD COLUMN canonical_url TEXT;
+	CREATE INDEX short_urls_canonical_url_idx ON short_urls (canonical_url);
+	CREATE INDEX short_urls_original_url_idx ON short_urls (original_url);`,
+	`ALTER TABLE short_urls ADD COLUMN created_at TIMESTAMP;
+	ALTER TABLE short_urls ADD COLUMN updated_at TIMESTAMP;
+	ALTER TABLE short_urls ADD COLUMN deleted_at TIMESTAMP;
+	CREATE INDEX short_urls_user_id_created_at_idx ON short_urls (user_id, created_at);`,
+}
+
+// OpenSQLite открывает базу по DSN вида sqlite:///path/to/urls.db и применяет миграции.
+// SQLite допускает одного писателя, поэтому пул ограничен одним соединением.
+func OpenSQLite(dsn string) (*SQLiteStorage, error) {
+	path := strings.TrimPrefix(dsn, SQLiteScheme)
+	if path == "" {
+		return nil, fmt.Errorf("missing SQLite database path")
+	}
+	query := url.Values{"_pragma": {"busy_timeout(5000)", "foreign_keys(1)", "journal_mode(WAL)"}}
+	db, err := sql.Open("sqlite", "file:"+path+"?"+query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("error opening SQLite database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error migrating SQLite database: %w", err)
+	}
+	return &SQLiteStorage{db: db}, nil
+}
+
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		// PRAGMA не принимает параметры, номер подставляется в текст запроса
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close закрывает базу. Фоновых операций у SQLiteStorage нет, поэтому ctx не используется.
+func (s *SQLiteStorage) Close(ctx context.Context) error {
+	return s.db.Close()
+}
+
+// DB возвращает соединение с базой.
+func (s *SQLiteStorage) DB() *sql.DB {
+	return s.db
+}
+
+func (s *SQLiteStorage) Save(ctx context.Context, userID, originalURL, canonicalURL, shortKey string) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO short_urls (original_url, canonical_url, short_key, user_id, created_at, updated_at) VALUES (?1, ?2, ?3, ?4, ?5, ?5)",
+		originalURL, canonicalURL, shortKey, userID, now())
+	return err
+}
+
+func (s *SQLiteStorage) GetOriginalURL(ctx context.Context, shortKey string) (string, error) {
+	var originalURL string
+	var isDeleted bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT original_url, is_deleted FROM short_urls WHERE short_key = ?", shortKey).
+		Scan(&originalURL, &isDeleted)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", errors.ErrURLNotFound
+		}
+		return "", fmt.Errorf("db get error: %w", err)
+	}
+	if isDeleted {
+		return "", errors.ErrURLDeleted
+	}
+	return originalURL, nil
+}
+
+// Stats считает неудалённые ссылки и пользователей, у которых они есть.
+func (s *SQLiteStorage) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*), COUNT(DISTINCT user_id) FROM short_urls WHERE is_deleted = FALSE").
+		Scan(&stats.URLs, &stats.Users)
+	if err != nil {
+		return Stats{}, fmt.Errorf("db stats error: %w", err)
+	}
+	return stats, nil
+}
+
+// GetShortKey ищет ссылку по каноническому URL, а у записей без него — по исходному адресу.
+func (s *SQLiteStorage) GetShortKey(ctx context.Context, canonicalURL string) string {
+	var existingShortKey string
+	s.db.QueryRowContext(ctx,
+		"SELECT short_key FROM short_urls WHERE canonical_url = ?1 OR (canonical_url IS NULL AND original_url = ?1) LIMIT 1",
+		canonicalURL).Scan(&existingShortKey)
+	return existingShortKey
+}
+
+// GetURLsByUser возвращает неудалённые ссылки пользователя в порядке создания.
+func (s *SQLiteStorage) GetURLsByUser(ctx context.Context, userID, baseURL string) ([]ResponseURLs, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT short_key, original_url, created_at, updated_at FROM short_urls WHERE user_id = ? AND is_deleted = FALSE ORDER BY uuid",
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("db list error: %w", err)
+	}
+	return scanURLs(rows, baseURL)
+}
+
+func (s *SQLiteStorage) CheckConnection(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database ping failed: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserURLs помечает ссылки пользователя удалёнными одной транзакцией:
+// параллельные пакеты, как в Postgres, у SQLite всё равно выполнялись бы по очереди.
+func (s *SQLiteStorage) DeleteUserURLs(ctx context.Context, userID string, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	placeholders, args := sqliteIn(urls)
+	t := now()
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE short_urls SET is_deleted = TRUE, deleted_at = ?, updated_at = ? WHERE short_key IN ("+placeholders+") AND user_id = ? AND is_deleted = FALSE",
+		append(append([]any{t, t}, args...), userID)...)
+	return err
+}
+
+// sqliteIn возвращает плейсхолдеры для IN (...) и аргументы: SQLite не поддерживает массивы.
+func sqliteIn(values []string) (string, []any) {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", "), args
+}
+
+// Export держит единственное соединение до конца выгрузки.
+func (s *SQLiteStorage) Export(ctx context.Context, fn func(Record) error) error {
+	return exportSQL(ctx, s.db, fn)
+}
+
+func (s *SQLiteStorage) Import(ctx context.Context, records []Record, overwrite bool) (ImportResult, error) {
+	return importSQL(ctx, s.db, records, overwrite)
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/dron1337/shortener/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage(t *testing.T) {
+	ctx := context.Background()
+	dsn := SQLiteScheme + filepath.Join(t.TempDir(), "urls.db")
+	s, err := OpenSQLite(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close(ctx) })
+
+	t.Run("Save and get", func(t *testing.T) {
+		require.NoError(t, s.Save(ctx, "user1", "https://Example.com/a", "https://example.com/a", "abc"))
+		u, err := s.GetOriginalURL(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, "https://Example.com/a", u)
+		assert.Equal(t, "abc", s.GetShortKey(ctx, "https://example.com/a"))
+
+		assert.Error(t, s.Save(ctx, "user1", "https://other.com", "https://other.com", "abc"), "ключ уникален")
+		_, err = s.GetOriginalURL(ctx, "missing")
+		assert.ErrorIs(t, err, errors.ErrURLNotFound)
+	})
+
+	t.Run("Legacy rows without canonical URL", func(t *testing.T) {
+		_, err := s.DB().ExecContext(ctx,
+			"INSERT INTO short_urls (original_url, short_key, user_id) VALUES (?, ?, ?)",
+			"https://legacy.com", "old", "user1")
+		require.NoError(t, err)
+		assert.Equal(t, "old", s.GetShortKey(ctx, "https://legacy.com"))
+	})
+
+	t.Run("Soft delete", func(t *testing.T) {
+		require.NoError(t, s.Save(ctx, "user2", "https://example.com/b", "https://example.com/b", "del"))
+		require.NoError(t, s.DeleteUserURLs(ctx, "user1", []string{"del"}), "чужие ссылки не удаляются")
+		_, err := s.GetOriginalURL(ctx, "del")
+		require.NoError(t, err)
+
+		require.NoError(t, s.DeleteUserURLs(ctx, "user2", []string{"del", "missing"}))
+		_, err = s.GetOriginalURL(ctx, "del")
+		assert.ErrorIs(t, err, errors.ErrURLDeleted)
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		stats, err := s.Stats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, Stats{URLs: 2, Users: 1}, stats)
+	})
+
+	t.Run("Workspaces", func(t *testing.T) {
+		ws, err := s.CreateWorkspace(ctx, "owner", "team")
+		require.NoError(t, err)
+		role, err := s.GetMemberRole(ctx, ws.ID, "owner")
+		require.NoError(t, err)
+		assert.Equal(t, RoleAdmin, role)
+
+		require.NoError(t, s.SetWorkspaceMember(ctx, ws.ID, "member", RoleViewer))
+		require.NoError(t, s.SetWorkspaceMember(ctx, ws.ID, "member", RoleEditor))
+		members, err := s.GetWorkspaceMembers(ctx, ws.ID)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []WorkspaceMember{{"owner", RoleAdmin}, {"member", RoleEditor}}, members)
+
+		require.NoError(t, s.SaveToWorkspace(ctx, ws.ID, "member", "https://ws.com", "https://ws.com", "ws1"))
+		n, err := s.TransferURLs(ctx, "user1", ws.ID, []string{"abc", "old"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		urls, err := s.GetWorkspaceURLs(ctx, ws.ID, "http://localhost")
+		require.NoError(t, err)
+		assert.Len(t, urls, 3)
+
+		require.NoError(t, s.DeleteWorkspaceURLs(ctx, ws.ID, []string{"ws1"}))
+		_, err = s.GetOriginalURL(ctx, "ws1")
+		assert.ErrorIs(t, err, errors.ErrURLDeleted)
+
+		require.NoError(t, s.RemoveWorkspaceMember(ctx, ws.ID, "member"))
+		_, err = s.GetMemberRole(ctx, ws.ID, "member")
+		assert.ErrorIs(t, err, errors.ErrNotWorkspaceMember)
+		_, err = s.GetMemberRole(ctx, "missing", "owner")
+		assert.ErrorIs(t, err, errors.ErrWorkspaceNotFound)
+	})
+
+	t.Run("Reopen keeps data and schema version", func(t *testing.T) {
+		require.NoError(t, s.Close(ctx))
+		s, err = OpenSQLite(dsn)
+		require.NoError(t, err)
+		var version int
+		require.NoError(t, s.DB().QueryRow("PRAGMA user_version").Scan(&version))
+		assert.Equal(t, len(sqliteMigrations), version)
+		u, err := s.GetOriginalURL(ctx, "abc")
+		require.NoError(t, err)
+		assert.Equal(t, "https://Example.com/a", u)
+	})
+}
+
+func TestOpenSQLite_MissingPath(t *testing.T) {
+	_, err := OpenSQLite(SQLiteScheme)
+	assert.Error(t, err)
+}
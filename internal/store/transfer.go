@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Record — ссылка со всеми полями, которые переносятся между хранилищами.
+type Record struct {
+	ShortKey     string `json:"short_key"`
+	OriginalURL  string `json:"original_url"`
+	CanonicalURL string `json:"canonical_url,omitempty"`
+	UserID       string `json:"user_id"`
+	IsDeleted    bool   `json:"is_deleted"`
+	Timestamps
+}
+
+// ImportResult — итог загрузки ссылок.
+type ImportResult struct {
+	Imported    int `json:"imported"`
+	Overwritten int `json:"overwritten"`
+	Skipped     int `json:"skipped"`
+}
+
+// Add прибавляет к r итог следующей пачки.
+func (r *ImportResult) Add(other ImportResult) {
+	r.Imported += other.Imported
+	r.Overwritten += other.Overwritten
+	r.Skipped += other.Skipped
+}
+
+// Transferable — хранилище, из которого можно выгрузить все ссылки и в которое
+// можно загрузить их с теми же ключами, пользователями и признаком удаления.
+// Принадлежность к рабочим пространствам не переносится.
+type Transferable interface {
+	// Export передаёт fn все ссылки, включая удалённые, не загружая их в память целиком.
+	Export(ctx context.Context, fn func(Record) error) error
+	// Import записывает пачку ссылок. Ссылка с занятым ключом заменяется при overwrite,
+	// иначе пропускается.
+	Import(ctx context.Context, records []Record, overwrite bool) (ImportResult, error)
+}
+
+var (
+	_ Transferable = (*InMemoryStorage)(nil)
+	_ Transferable = (*FileStorage)(nil)
+	_ Transferable = (*PostgresStorage)(nil)
+	_ Transferable = (*SQLiteStorage)(nil)
+	_ Transferable = (*BoltStorage)(nil)
+	_ Transferable = (*ShardedStorage)(nil)
+)
+
+// canonicalOrOriginal возвращает URL для поиска дубликатов у хранилищ, которые
+// ищут только по canonical_url: у старых записей его нет, и сравнивается исходный адрес.
+func canonicalOrOriginal(rec Record) string {
+	if rec.CanonicalURL != "" {
+		return rec.CanonicalURL
+	}
+	return rec.OriginalURL
+}
+
+// exportSQL выгружает short_urls; запрос общий для Postgres и SQLite.
+func exportSQL(ctx context.Context, db *sql.DB, fn func(Record) error) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT short_key, original_url, COALESCE(canonical_url, ''), user_id, COALESCE(is_deleted, FALSE),
+			created_at, updated_at, deleted_at
+		FROM short_urls ORDER BY uuid`)
+	if err != nil {
+		return fmt.Errorf("db export error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rec Record
+		var createdAt, updatedAt, deletedAt sql.NullTime
+		if err := rows.Scan(&rec.ShortKey, &rec.OriginalURL, &rec.CanonicalURL, &rec.UserID, &rec.IsDeleted,
+			&createdAt, &updatedAt, &deletedAt); err != nil {
+			return err
+		}
+		rec.Timestamps = timestamps(createdAt, updatedAt, deletedAt)
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// importSQL загружает пачку одной транзакцией; запросы общие для Postgres и SQLite.
+func importSQL(ctx context.Context, db *sql.DB, records []Record, overwrite bool) (ImportResult, error) {
+	var result ImportResult
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+	for _, rec := range records {
+		// Пустой canonical_url хранится как NULL: так GetShortKey сравнивает исходный адрес
+		canonicalURL := sql.NullString{String: rec.CanonicalURL, Valid: rec.CanonicalURL != ""}
+		times := []any{nullTime(rec.CreatedAt), nullTime(rec.UpdatedAt), nullTime(rec.DeletedAt)}
+		var exists bool
+		if err := tx.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM short_urls WHERE short_key = $1)", rec.ShortKey).Scan(&exists); err != nil {
+			return ImportResult{}, err
+		}
+		switch {
+		case !exists:
+			_, err = tx.ExecContext(ctx,
+				`INSERT INTO short_urls (short_key, original_url, canonical_url, user_id, is_deleted, created_at, updated_at, deleted_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+				append([]any{rec.ShortKey, rec.OriginalURL, canonicalURL, rec.UserID, rec.IsDeleted}, times...)...)
+			result.Imported++
+		case overwrite:
+			_, err = tx.ExecContext(ctx,
+				`UPDATE short_urls SET original_url = $2, canonical_url = $3, user_id = $4, is_deleted = $5, workspace_id = NULL,
+				created_at = $6, updated_at = $7, deleted_at = $8 WHERE short_key = $1`,
+				append([]any{rec.ShortKey, rec.OriginalURL, canonicalURL, rec.UserID, rec.IsDeleted}, times...)...)
+			result.Overwritten++
+		default:
+			result.Skipped++
+		}
+		if err != nil {
+			return ImportResult{}, fmt.Errorf("import %q: %w", rec.ShortKey, err)
+		}
+	}
+	return result, tx.Commit()
+}
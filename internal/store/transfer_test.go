@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/dron1337/shortener/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBackends открывает по пустому хранилищу каждого вида.
+func testBackends(ctx context.Context) map[string]func(t *testing.T) Transferable {
+	return map[string]func(t *testing.T) Transferable{
+		"Memory": func(t *testing.T) Transferable { return NewInMemoryStorage() },
+		"File": func(t *testing.T) Transferable {
+			return NewFileStorage(filepath.Join(t.TempDir(), "urls.json"))
+		},
+		"SQLite": func(t *testing.T) Transferable {
+			s, err := OpenSQLite(SQLiteScheme + filepath.Join(t.TempDir(), "urls.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { s.Close(ctx) })
+			return s
+		},
+		"Bolt": func(t *testing.T) Transferable {
+			s, err := OpenBolt(BoltScheme + filepath.Join(t.TempDir(), "urls.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { s.Close(ctx) })
+			return s
+		},
+		"Postgres": func(t *testing.T) Transferable { return NewPostgresStorage(testReplicaDB(t)) },
+		"Sharded":  func(t *testing.T) Transferable { return NewShardedStorage(testShards(t, 3)) },
+	}
+}
+
+func TestTransferable(t *testing.T) {
+	ctx := context.Background()
+	backends := testBackends(ctx)
+	created := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	records := []Record{
+		{ShortKey: "aaaa1111", OriginalURL: "https://example.com/a", CanonicalURL: "https://example.com/a", UserID: "user-1",
+			Timestamps: Timestamps{CreatedAt: created, UpdatedAt: created.Add(time.Hour)}},
+		{ShortKey: "bbbb2222", OriginalURL: "https://example.com/b", UserID: "user-2"},
+		{ShortKey: "cccc3333", OriginalURL: "https://example.com/c", CanonicalURL: "https://example.com/c", UserID: "user-1", IsDeleted: true},
+	}
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			storage := s.(Storage)
+			require.NoError(t, storage.Save(ctx, "owner", "https://old.example.com", "https://old.example.com", "aaaa1111"))
+
+			result, err := s.Import(ctx, records, false)
+			require.NoError(t, err)
+			assert.Equal(t, ImportResult{Imported: 2, Skipped: 1}, result)
+			u, err := storage.GetOriginalURL(ctx, "aaaa1111")
+			require.NoError(t, err)
+			assert.Equal(t, "https://old.example.com", u)
+			assert.Equal(t, "bbbb2222", storage.GetShortKey(ctx, "https://example.com/b"), "без canonical_url ищется исходный адрес")
+			_, err = storage.GetOriginalURL(ctx, "cccc3333")
+			assert.Error(t, err)
+			if name != "File" {
+				assert.ErrorIs(t, err, errors.ErrURLDeleted)
+			}
+
+			result, err = s.Import(ctx, records[:1], true)
+			require.NoError(t, err)
+			assert.Equal(t, ImportResult{Overwritten: 1}, result)
+			u, err = storage.GetOriginalURL(ctx, "aaaa1111")
+			require.NoError(t, err)
+			assert.Equal(t, "https://example.com/a", u)
+			assert.Equal(t, "aaaa1111", storage.GetShortKey(ctx, "https://example.com/a"))
+			assert.Empty(t, storage.GetShortKey(ctx, "https://old.example.com"), "заменённая ссылка не находится")
+
+			var exported []Record
+			require.NoError(t, s.Export(ctx, func(rec Record) error {
+				exported = append(exported, rec)
+				return nil
+			}))
+			sort.Slice(exported, func(i, j int) bool { return exported[i].ShortKey < exported[j].ShortKey })
+			require.Len(t, exported, len(records))
+			for i, want := range records {
+				// Memory и Bolt ищут дубликаты только по canonical_url и заполняют его исходным адресом
+				if exported[i].CanonicalURL == want.OriginalURL {
+					exported[i].CanonicalURL = want.CanonicalURL
+				}
+				assert.Equal(t, want, exported[i])
+			}
+
+			stats, err := storage.Stats(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, Stats{URLs: 2, Users: 2}, stats)
+		})
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	ctx := context.Background()
+	for name, open := range testBackends(ctx) {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			before := time.Now().UTC().Add(-time.Second)
+			require.NoError(t, s.(Storage).Save(ctx, "user-1", "https://example.com/a", "https://example.com/a", "aaaa1111"))
+			var exported []Record
+			require.NoError(t, s.Export(ctx, func(rec Record) error {
+				exported = append(exported, rec)
+				return nil
+			}))
+			require.Len(t, exported, 1)
+			got := exported[0].Timestamps
+			assert.True(t, got.CreatedAt.After(before), "created_at = %v", got.CreatedAt)
+			assert.Equal(t, got.CreatedAt, got.UpdatedAt)
+			assert.True(t, got.DeletedAt.IsZero())
+			assert.Equal(t, time.UTC, got.CreatedAt.Location())
+
+			deleter, ok := s.(interface {
+				DeleteUserURLs(ctx context.Context, userID string, urls []string) error
+			})
+			// Удаление в Postgres использует ANY, которого нет в SQLite, подменяющей его в тестах
+			if !ok || name == "Postgres" || name == "Sharded" {
+				return
+			}
+			require.NoError(t, deleter.DeleteUserURLs(ctx, "user-1", []string{"aaaa1111"}))
+			exported = nil
+			require.NoError(t, s.Export(ctx, func(rec Record) error {
+				exported = append(exported, rec)
+				return nil
+			}))
+			require.Len(t, exported, 1)
+			deleted := exported[0].Timestamps
+			assert.Equal(t, got.CreatedAt, deleted.CreatedAt)
+			assert.False(t, deleted.DeletedAt.Before(got.CreatedAt))
+			assert.Equal(t, deleted.DeletedAt, deleted.UpdatedAt)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+
+	"github.com/dron1337/shortener/internal/errors"
+)
+
+// Role определяет права участника рабочего пространства.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+var roleRank = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// ParseRole проверяет, что строка является известной ролью.
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if _, ok := roleRank[role]; !ok {
+		return "", errors.ErrInvalidRole
+	}
+	return role, nil
+}
+
+// Allows сообщает, достаточно ли роли r для действия, требующего роли required.
+func (r Role) Allows(required Role) bool {
+	return roleRank[r] >= roleRank[required]
+}
+
+type Workspace struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	OwnerID string `json:"owner_id"`
+}
+
+type WorkspaceMember struct {
+	UserID string `json:"user_id"`
+	Role   Role   `json:"role"`
+}
+
+// WorkspaceStorage — хранилище рабочих пространств и их ссылок.
+type WorkspaceStorage interface {
+	CreateWorkspace(ctx context.Context, ownerID, name string) (Workspace, error)
+	GetMemberRole(ctx context.Context, workspaceID, userID string) (Role, error)
+	GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error)
+	SetWorkspaceMember(ctx context.Context, workspaceID, userID string, role Role) error
+	RemoveWorkspaceMember(ctx context.Context, workspaceID, userID string) error
+	SaveToWorkspace(ctx context.Context, workspaceID, userID, originalURL, canonicalURL, shortKey string) error
+	GetWorkspaceURLs(ctx context.Context, workspaceID, baseURL string) ([]ResponseURLs, error)
+	DeleteWorkspaceURLs(ctx context.Context, workspaceID string, keys []string) error
+	TransferURLs(ctx context.Context, userID, workspaceID string, keys []string) (int, error)
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dron1337/shortener/internal/errors"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+func (s *BoltStorage) CreateWorkspace(ctx context.Context, ownerID, name string) (Workspace, error) {
+	ws := Workspace{ID: uuid.New().String(), Name: name, OwnerID: ownerID}
+	data, err := json.Marshal(ws)
+	if err != nil {
+		return Workspace{}, err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltWorkspaces).Put([]byte(ws.ID), data); err != nil {
+			return err
+		}
+		members, err := tx.Bucket(boltMembers).CreateBucketIfNotExists([]byte(ws.ID))
+		if err != nil {
+			return err
+		}
+		return members.Put([]byte(ownerID), []byte(RoleAdmin))
+	})
+	if err != nil {
+		return Workspace{}, err
+	}
+	return ws, nil
+}
+
+// workspaceMembers возвращает бакет участников или ErrWorkspaceNotFound.
+func workspaceMembers(tx *bolt.Tx, workspaceID string) (*bolt.Bucket, error) {
+	if tx.Bucket(boltWorkspaces).Get([]byte(workspaceID)) == nil {
+		return nil, errors.ErrWorkspaceNotFound
+	}
+	if b := tx.Bucket(boltMembers).Bucket([]byte(workspaceID)); b != nil {
+		return b, nil
+	}
+	return nil, fmt.Errorf("members of workspace %q are missing", workspaceID)
+}
+
+func (s *BoltStorage) GetMemberRole(ctx context.Context, workspaceID, userID string) (Role, error) {
+	var role Role
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b, err := workspaceMembers(tx, workspaceID)
+		if err != nil {
+			return err
+		}
+		v := b.Get([]byte(userID))
+		if v == nil {
+			return errors.ErrNotWorkspaceMember
+		}
+		role = Role(v)
+		return nil
+	})
+	return role, err
+}
+
+func (s *BoltStorage) GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error) {
+	var result []WorkspaceMember
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b, err := workspaceMembers(tx, workspaceID)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(userID, role []byte) error {
+			result = append(result, WorkspaceMember{UserID: string(userID), Role: Role(role)})
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (s *BoltStorage) SetWorkspaceMember(ctx context.Context, workspaceID, userID string, role Role) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := workspaceMembers(tx, workspaceID)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(userID), []byte(role))
+	})
+}
+
+func (s *BoltStorage) RemoveWorkspaceMember(ctx context.Context, workspaceID, userID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := workspaceMembers(tx, workspaceID)
+		if err != nil {
+			return err
+		}
+		if b.Get([]byte(userID)) == nil {
+			return errors.ErrNotWorkspaceMember
+		}
+		return b.Delete([]byte(userID))
+	})
+}
+
+func (s *BoltStorage) SaveToWorkspace(ctx context.Context, workspaceID, userID, originalURL, canonicalURL, shortKey string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return insert(tx, shortKey, boltRecord{
+			OriginalURL:  originalURL,
+			CanonicalURL: canonicalURL,
+			UserID:       userID,
+			WorkspaceID:  workspaceID,
+			Timestamps:   created(),
+		})
+	})
+}
+
+func (s *BoltStorage) GetWorkspaceURLs(ctx context.Context, workspaceID, baseURL string) ([]ResponseURLs, error) {
+	var result []ResponseURLs
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if _, err := workspaceMembers(tx, workspaceID); err != nil {
+			return err
+		}
+		set := tx.Bucket(boltWorkspaceURLs).Bucket([]byte(workspaceID))
+		if set == nil {
+			return nil
+		}
+		return set.ForEach(func(key, _ []byte) error {
+			rec, ok, err := getRecord(tx, string(key))
+			if err != nil || !ok || rec.IsDeleted || rec.WorkspaceID != workspaceID {
+				return err
+			}
+			result = append(result, ResponseURLs{
+				OriginalURL: rec.OriginalURL,
+				ShortURL:    fmt.Sprintf("%s/%s", baseURL, key),
+				Timestamps:  Timestamps{CreatedAt: rec.CreatedAt, UpdatedAt: rec.UpdatedAt},
+			})
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (s *BoltStorage) DeleteWorkspaceURLs(ctx context.Context, workspaceID string, keys []string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, key := range keys {
+			rec, ok, err := getRecord(tx, key)
+			if err != nil {
+				return err
+			}
+			if ok && rec.WorkspaceID == workspaceID {
+				if err := markDeleted(tx, key); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// TransferURLs переносит неудалённые ссылки пользователя в пространство.
+// Ключ в множестве прежнего пространства остаётся и отфильтровывается в GetWorkspaceURLs.
+func (s *BoltStorage) TransferURLs(ctx context.Context, userID, workspaceID string, keys []string) (int, error) {
+	moved := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltWorkspaces).Get([]byte(workspaceID)) == nil {
+			return errors.ErrWorkspaceNotFound
+		}
+		for _, key := range keys {
+			rec, ok, err := getRecord(tx, key)
+			if err != nil {
+				return err
+			}
+			if !ok || rec.IsDeleted || rec.UserID != userID {
+				continue
+			}
+			rec.WorkspaceID = workspaceID
+			rec.UpdatedAt = now()
+			if err := putRecord(tx, key, rec); err != nil {
+				return err
+			}
+			if err := addToSet(tx, boltWorkspaceURLs, workspaceID, key); err != nil {
+				return err
+			}
+			moved++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
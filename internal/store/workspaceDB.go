@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dron1337/shortener/internal/errors"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func (s *PostgresStorage) CreateWorkspace(ctx context.Context, ownerID, name string) (Workspace, error) {
+	markWrite(ctx)
+	ws := Workspace{ID: uuid.New().String(), Name: name, OwnerID: ownerID}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Workspace{}, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO workspaces (id, name, owner_id) VALUES ($1, $2, $3)",
+		ws.ID, ws.Name, ws.OwnerID); err != nil {
+		return Workspace{}, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)",
+		ws.ID, ownerID, RoleAdmin); err != nil {
+		return Workspace{}, err
+	}
+	return ws, tx.Commit()
+}
+
+func (s *PostgresStorage) GetMemberRole(ctx context.Context, workspaceID, userID string) (Role, error) {
+	var role sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT m.role FROM workspaces w
+		LEFT JOIN workspace_members m ON m.workspace_id = w.id AND m.user_id = $2
+		WHERE w.id = $1`, workspaceID, userID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", errors.ErrWorkspaceNotFound
+		}
+		return "", fmt.Errorf("db get role error: %w", err)
+	}
+	if !role.Valid {
+		return "", errors.ErrNotWorkspaceMember
+	}
+	return Role(role.String), nil
+}
+
+func (s *PostgresStorage) GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error) {
+	if err := s.checkWorkspace(ctx, workspaceID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT user_id, role FROM workspace_members WHERE workspace_id = $1", workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var members []WorkspaceMember
+	for rows.Next() {
+		var m WorkspaceMember
+		if err := rows.Scan(&m.UserID, &m.Role); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (s *PostgresStorage) SetWorkspaceMember(ctx context.Context, workspaceID, userID string, role Role) error {
+	markWrite(ctx)
+	if err := s.checkWorkspace(ctx, workspaceID); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role`,
+		workspaceID, userID, role)
+	return err
+}
+
+func (s *PostgresStorage) RemoveWorkspaceMember(ctx context.Context, workspaceID, userID string) error {
+	markWrite(ctx)
+	if err := s.checkWorkspace(ctx, workspaceID); err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx,
+		"DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2", workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.ErrNotWorkspaceMember
+	}
+	return nil
+}
+
+func (s *PostgresStorage) SaveToWorkspace(ctx context.Context, workspaceID, userID, originalURL, canonicalURL, shortKey string) error {
+	markWrite(ctx)
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO short_urls (original_url, canonical_url, short_key, user_id, workspace_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $6)",
+		originalURL, canonicalURL, shortKey, userID, workspaceID, now())
+	return err
+}
+
+func (s *PostgresStorage) GetWorkspaceURLs(ctx context.Context, workspaceID, baseURL string) ([]ResponseURLs, error) {
+	if err := s.checkWorkspace(ctx, workspaceID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT short_key, original_url, created_at, updated_at FROM short_urls WHERE workspace_id = $1 AND is_deleted = false",
+		workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	return scanURLs(rows, baseURL)
+}
+
+func (s *PostgresStorage) DeleteWorkspaceURLs(ctx context.Context, workspaceID string, keys []string) error {
+	markWrite(ctx)
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE short_urls SET is_deleted = true, deleted_at = $3, updated_at = $3 WHERE short_key = ANY($1) AND workspace_id = $2 AND is_deleted = false",
+		pq.Array(keys), workspaceID, now())
+	return err
+}
+
+func (s *PostgresStorage) TransferURLs(ctx context.Context, userID, workspaceID string, keys []string) (int, error) {
+	markWrite(ctx)
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE short_urls SET workspace_id = $1, updated_at = $4 WHERE short_key = ANY($2) AND user_id = $3 AND is_deleted = false",
+		workspaceID, pq.Array(keys), userID, now())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *PostgresStorage) checkWorkspace(ctx context.Context, workspaceID string) error {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM workspaces WHERE id = $1)", workspaceID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errors.ErrWorkspaceNotFound
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dron1337/shortener/internal/errors"
+	"github.com/google/uuid"
+)
+
+func (s *SQLiteStorage) CreateWorkspace(ctx context.Context, ownerID, name string) (Workspace, error) {
+	ws := Workspace{ID: uuid.New().String(), Name: name, OwnerID: ownerID}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Workspace{}, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO workspaces (id, name, owner_id) VALUES (?, ?, ?)",
+		ws.ID, ws.Name, ws.OwnerID); err != nil {
+		return Workspace{}, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, ?, ?)",
+		ws.ID, ownerID, RoleAdmin); err != nil {
+		return Workspace{}, err
+	}
+	return ws, tx.Commit()
+}
+
+func (s *SQLiteStorage) GetMemberRole(ctx context.Context, workspaceID, userID string) (Role, error) {
+	var role sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT m.role FROM workspaces w
+		LEFT JOIN workspace_members m ON m.workspace_id = w.id AND m.user_id = ?2
+		WHERE w.id = ?1`, workspaceID, userID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", errors.ErrWorkspaceNotFound
+		}
+		return "", fmt.Errorf("db get role error: %w", err)
+	}
+	if !role.Valid {
+		return "", errors.ErrNotWorkspaceMember
+	}
+	return Role(role.String), nil
+}
+
+func (s *SQLiteStorage) GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]WorkspaceMember, error) {
+	if err := s.checkWorkspace(ctx, workspaceID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT user_id, role FROM workspace_members WHERE workspace_id = ?", workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var members []WorkspaceMember
+	for rows.Next() {
+		var m WorkspaceMember
+		if err := rows.Scan(&m.UserID, &m.Role); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (s *SQLiteStorage) SetWorkspaceMember(ctx context.Context, workspaceID, userID string, role Role) error {
+	if err := s.checkWorkspace(ctx, workspaceID); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, ?, ?)
+		ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = excluded.role`,
+		workspaceID, userID, role)
+	return err
+}
+
+func (s *SQLiteStorage) RemoveWorkspaceMember(ctx context.Context, workspaceID, userID string) error {
+	if err := s.checkWorkspace(ctx, workspaceID); err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx,
+		"DELETE FROM workspace_members WHERE workspace_id = ? AND user_id = ?", workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.ErrNotWorkspaceMember
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) SaveToWorkspace(ctx context.Context, workspaceID, userID, originalURL, canonicalURL, shortKey string) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO short_urls (original_url, canonical_url, short_key, user_id, workspace_id, created_at, updated_at) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?6)",
+		originalURL, canonicalURL, shortKey, userID, workspaceID, now())
+	return err
+}
+
+func (s *SQLiteStorage) GetWorkspaceURLs(ctx context.Context, workspaceID, baseURL string) ([]ResponseURLs, error) {
+	if err := s.checkWorkspace(ctx, workspaceID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT short_key, original_url, created_at, updated_at FROM short_urls WHERE workspace_id = ? AND is_deleted = FALSE",
+		workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	return scanURLs(rows, baseURL)
+}
+
+func (s *SQLiteStorage) DeleteWorkspaceURLs(ctx context.Context, workspaceID string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	placeholders, args := sqliteIn(keys)
+	t := now()
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE short_urls SET is_deleted = TRUE, deleted_at = ?, updated_at = ? WHERE short_key IN ("+placeholders+") AND workspace_id = ? AND is_deleted = FALSE",
+		append(append([]any{t, t}, args...), workspaceID)...)
+	return err
+}
+
+func (s *SQLiteStorage) TransferURLs(ctx context.Context, userID, workspaceID string, keys []string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	placeholders, args := sqliteIn(keys)
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE short_urls SET workspace_id = ?, updated_at = ? WHERE short_key IN ("+placeholders+") AND user_id = ? AND is_deleted = FALSE",
+		append(append([]any{workspaceID, now()}, args...), userID)...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLiteStorage) checkWorkspace(ctx context.Context, workspaceID string) error {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM workspaces WHERE id = ?)", workspaceID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errors.ErrWorkspaceNotFound
+	}
+	return nil
+}
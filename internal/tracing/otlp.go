@@ -0,0 +1,165 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// OTLPExporter пишет каждый спан отдельной строкой в формате OTLP/JSON
+// (ExportTraceServiceRequest). Такие файлы читает otel-collector с приёмником otlpjsonfile.
+type OTLPExporter struct {
+	service string
+	mu      sync.Mutex
+	w       io.Writer
+	closer  io.Closer
+	onError func(error)
+}
+
+// NewOTLPExporter пишет в w; closer, если задан, закрывается в Close.
+func NewOTLPExporter(service string, w io.Writer, closer io.Closer) *OTLPExporter {
+	return &OTLPExporter{service: service, w: w, closer: closer}
+}
+
+// OpenExporter создаёт экспортер для вывода: "stdout" или путь к файлу.
+// Пустой вывод означает, что спаны не экспортируются.
+func OpenExporter(service, output string) (Exporter, error) {
+	switch output {
+	case "":
+		return nil, nil
+	case "stdout":
+		return NewOTLPExporter(service, os.Stdout, nil), nil
+	}
+	file, err := os.OpenFile(output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open trace output: %w", err)
+	}
+	return NewOTLPExporter(service, file, file), nil
+}
+
+// OnError задаёт обработчик ошибок записи; по умолчанию они игнорируются.
+func (e *OTLPExporter) OnError(fn func(error)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onError = fn
+}
+
+func (e *OTLPExporter) Export(span *Span) {
+	data, err := json.Marshal(e.request(span))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err == nil {
+		_, err = e.w.Write(append(data, '\n'))
+	}
+	if err != nil && e.onError != nil {
+		e.onError(err)
+	}
+}
+
+func (e *OTLPExporter) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// statusError — код STATUS_CODE_ERROR в OTLP; успешные спаны остаются со статусом UNSET.
+const statusError = 2
+
+func (e *OTLPExporter) request(span *Span) otlpRequest {
+	span.mu.Lock()
+	s := otlpSpan{
+		TraceID:           span.context.TraceID.String(),
+		SpanID:            span.context.SpanID.String(),
+		Name:              span.name,
+		Kind:              span.kind,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+	}
+	if span.parent.IsValid() {
+		s.ParentSpanID = span.parent.String()
+	}
+	for _, attr := range span.attributes {
+		s.Attributes = append(s.Attributes, otlpAttribute{Key: attr.Key, Value: toOTLPValue(attr.Value)})
+	}
+	if span.errMessage != "" {
+		s.Status = otlpStatus{Code: statusError, Message: span.errMessage}
+	}
+	span.mu.Unlock()
+
+	scope := otlpScopeSpans{Spans: []otlpSpan{s}}
+	scope.Scope.Name = "github.com/dron1337/shortener"
+	resource := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scope}}
+	resource.Resource.Attributes = []otlpAttribute{{Key: "service.name", Value: toOTLPValue(e.service)}}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{resource}}
+}
+
+func toOTLPValue(v any) otlpValue {
+	switch val := v.(type) {
+	case string:
+		return otlpValue{StringValue: &val}
+	case bool:
+		return otlpValue{BoolValue: &val}
+	case int:
+		s := strconv.Itoa(val)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(val, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &val}
+	default:
+		s := fmt.Sprint(val)
+		return otlpValue{StringValue: &s}
+	}
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader — заголовок W3C Trace Context.
+const TraceparentHeader = "traceparent"
+
+// ParseTraceparent разбирает значение вида 00-<trace-id>-<span-id>-<flags>.
+func ParseTraceparent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, fmt.Errorf("malformed traceparent %q", value)
+	}
+	// Версия ff запрещена; для версии 00 лишних полей быть не должно
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, fmt.Errorf("unsupported traceparent version %q", parts[0])
+	}
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, fmt.Errorf("malformed trace id: %w", err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, fmt.Errorf("malformed span id: %w", err)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, fmt.Errorf("malformed trace flags: %w", err)
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("zero trace or span id")
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+// Traceparent форматирует идентификаторы спана для заголовка.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// Extract достаёт родительский спан из заголовков входящего запроса.
+// Некорректный traceparent игнорируется, и начинается новая трасса.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemoteParent(ctx, sc)
+}
+
+// Inject записывает текущий спан в заголовки исходящего запроса.
+func Inject(ctx context.Context, header http.Header) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		header.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
@@ -0,0 +1,190 @@
+// Package tracing — лёгкая трассировка запросов в модели OpenTelemetry:
+// спаны с родителями, W3C traceparent и экспорт в OTLP/JSON.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+func (id TraceID) IsValid() bool  { return id != TraceID{} }
+func (id SpanID) IsValid() bool   { return id != SpanID{} }
+
+// SpanKind — значения совпадают с перечислением SpanKind в OTLP.
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// SpanContext — идентификаторы спана, которые передаются между процессами.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Attribute — атрибут спана; Value может быть string, bool, int, int64 или float64.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Span — одна операция трассы. Методы безопасны для nil-спана.
+type Span struct {
+	tracer *Tracer
+
+	mu         sync.Mutex
+	name       string
+	kind       SpanKind
+	context    SpanContext
+	parent     SpanID
+	start      time.Time
+	end        time.Time
+	attributes []Attribute
+	errMessage string
+	ended      bool
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// SetAttributes добавляет атрибуты парами ключ-значение.
+func (s *Span) SetAttributes(kv ...any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(kv); i += 2 {
+		if key, ok := kv[i].(string); ok {
+			s.attributes = append(s.attributes, Attribute{Key: key, Value: kv[i+1]})
+		}
+	}
+}
+
+// SetError помечает спан как завершившийся с ошибкой.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errMessage = err.Error()
+}
+
+// End завершает спан и передаёт его экспортеру. Повторные вызовы игнорируются.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = s.tracer.now()
+	s.mu.Unlock()
+	if s.context.Sampled && s.tracer.exporter != nil {
+		s.tracer.exporter.Export(s)
+	}
+}
+
+// Exporter получает завершённые спаны.
+type Exporter interface {
+	Export(span *Span)
+	Close() error
+}
+
+// Tracer создаёт спаны. Без экспортера спаны всё равно получают идентификаторы,
+// чтобы их можно было связать с логами и передать дальше.
+type Tracer struct {
+	service  string
+	exporter Exporter
+	now      func() time.Time
+}
+
+func NewTracer(service string, exporter Exporter) *Tracer {
+	return &Tracer{service: service, exporter: exporter, now: time.Now}
+}
+
+// Start начинает спан, дочерний к спану из ctx (локальному или пришедшему в traceparent).
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	span := &Span{tracer: t, name: name, kind: kind, start: t.now()}
+	parent := SpanContextFromContext(ctx)
+	if parent.IsValid() {
+		span.context.TraceID = parent.TraceID
+		span.context.Sampled = parent.Sampled
+		span.parent = parent.SpanID
+	} else {
+		rand.Read(span.context.TraceID[:])
+		span.context.Sampled = true
+	}
+	rand.Read(span.context.SpanID[:])
+	return ContextWithSpan(ctx, span), span
+}
+
+// Close сбрасывает и закрывает экспортер.
+func (t *Tracer) Close() error {
+	if t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Close()
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// ContextWithRemoteParent запоминает родителя, пришедшего из другого процесса.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanFromContext возвращает текущий спан или nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext возвращает идентификаторы текущего спана или удалённого родителя.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.context
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
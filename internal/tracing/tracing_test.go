@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		sampled bool
+		wantErr bool
+	}{
+		{name: "Sampled", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sampled: true},
+		{name: "Not sampled", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		{name: "Future version with extra field", value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", sampled: true},
+		{name: "Zero trace id", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: true},
+		{name: "Forbidden version", value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "Not hex", value: "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", wantErr: true},
+		{name: "Empty", value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+			assert.Equal(t, tt.sampled, sc.Sampled)
+		})
+	}
+}
+
+func TestPropagationAndExport(t *testing.T) {
+	var out bytes.Buffer
+	tracer := NewTracer("test", NewOTLPExporter("test", &out, nil))
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := Extract(context.Background(), header)
+	ctx, parent := tracer.Start(ctx, "GET /{key}", KindServer)
+	_, child := tracer.Start(ctx, "storage get_original_url", KindClient)
+	child.SetAttributes("db.system", "postgres", "rows", 1)
+	child.SetError(errors.New("timeout"))
+	child.End()
+	parent.End()
+	parent.End()
+
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+	assert.Equal(t, parent.SpanContext().Traceparent(), outgoing.Get(TraceparentHeader))
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2, "each span is exported once")
+	var req otlpRequest
+	require.NoError(t, json.Unmarshal(lines[0], &req))
+	span := req.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID)
+	assert.Equal(t, parent.SpanContext().SpanID.String(), span.ParentSpanID)
+	assert.Equal(t, KindClient, span.Kind)
+	assert.Equal(t, statusError, span.Status.Code)
+	assert.Equal(t, "timeout", span.Status.Message)
+	require.Len(t, span.Attributes, 2)
+	assert.Equal(t, "1", *span.Attributes[1].Value.IntValue)
+
+	require.NoError(t, json.Unmarshal(lines[1], &req))
+	assert.Equal(t, "00f067aa0ba902b7", req.ResourceSpans[0].ScopeSpans[0].Spans[0].ParentSpanID)
+}
+
+func TestUnsampledParentIsNotExported(t *testing.T) {
+	var out bytes.Buffer
+	tracer := NewTracer("test", NewOTLPExporter("test", &out, nil))
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	require.NoError(t, err)
+	_, span := tracer.Start(ContextWithRemoteParent(context.Background(), sc), "op", KindInternal)
+	span.End()
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,277 @@
+// Package transfer выгружает ссылки из хранилищ в JSONL или CSV и загружает их обратно.
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/dron1337/shortener/internal/store"
+)
+
+// Format — формат выгрузки.
+type Format string
+
+const (
+	// JSONL — по одному JSON-объекту store.Record на строку.
+	JSONL Format = "jsonl"
+	// CSV — строка заголовка csvHeader и по строке на ссылку.
+	CSV Format = "csv"
+)
+
+// ParseFormat проверяет имя формата; пустое имя означает JSONL.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case "", JSONL:
+		return JSONL, nil
+	case CSV:
+		return CSV, nil
+	}
+	return "", fmt.Errorf("unknown format %q: want jsonl or csv", s)
+}
+
+// ContentType возвращает MIME-тип формата для HTTP-ответа.
+func (f Format) ContentType() string {
+	if f == CSV {
+		return "text/csv"
+	}
+	return "application/x-ndjson"
+}
+
+// ErrMalformed — входные данные Import не разбираются. Пачки, прочитанные до ошибки,
+// уже загружены, а пачка с ошибочной записью отбрасывается целиком.
+var ErrMalformed = errors.New("malformed input")
+
+var csvHeader = []string{"short_key", "original_url", "canonical_url", "user_id", "is_deleted", "created_at", "updated_at", "deleted_at"}
+
+// csvLegacyColumns — число столбцов выгрузок, сделанных до появления времени ссылок.
+const csvLegacyColumns = 5
+
+// Encoder записывает ссылки в поток.
+type Encoder interface {
+	Encode(rec store.Record) error
+	// Flush дописывает буферизованные данные.
+	Flush() error
+}
+
+// Decoder читает ссылки из потока; в конце возвращает io.EOF.
+type Decoder interface {
+	Decode() (store.Record, error)
+}
+
+// NewEncoder создаёт Encoder формата f.
+func NewEncoder(w io.Writer, f Format) Encoder {
+	if f == CSV {
+		return &csvEncoder{w: csv.NewWriter(w)}
+	}
+	buf := bufio.NewWriter(w)
+	return &jsonEncoder{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+// NewDecoder создаёт Decoder формата f.
+func NewDecoder(r io.Reader, f Format) Decoder {
+	if f == CSV {
+		// Число столбцов задаёт заголовок: csvHeader или его старый вариант без времени
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = 0
+		return &csvDecoder{r: cr}
+	}
+	return &jsonDecoder{dec: json.NewDecoder(r)}
+}
+
+type jsonEncoder struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *jsonEncoder) Encode(rec store.Record) error { return e.enc.Encode(rec) }
+func (e *jsonEncoder) Flush() error                  { return e.buf.Flush() }
+
+type jsonDecoder struct {
+	dec  *json.Decoder
+	line int
+}
+
+func (d *jsonDecoder) Decode() (store.Record, error) {
+	var rec store.Record
+	d.line++
+	if err := d.dec.Decode(&rec); err != nil {
+		if err == io.EOF {
+			return rec, err
+		}
+		return rec, fmt.Errorf("%w: record %d: %v", ErrMalformed, d.line, err)
+	}
+	return rec, validate(rec, d.line)
+}
+
+type csvEncoder struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (e *csvEncoder) Encode(rec store.Record) error {
+	if !e.wroteHeader {
+		e.wroteHeader = true
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	return e.w.Write([]string{rec.ShortKey, rec.OriginalURL, rec.CanonicalURL, rec.UserID, strconv.FormatBool(rec.IsDeleted),
+		formatTime(rec.CreatedAt), formatTime(rec.UpdatedAt), formatTime(rec.DeletedAt)})
+}
+
+// formatTime записывает неизвестное время пустой строкой.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	return t.UTC(), err
+}
+
+func (e *csvEncoder) Flush() error {
+	// Пустая выгрузка тоже начинается с заголовка
+	if !e.wroteHeader {
+		e.wroteHeader = true
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type csvDecoder struct {
+	r    *csv.Reader
+	line int
+}
+
+func (d *csvDecoder) Decode() (store.Record, error) {
+	if d.line == 0 {
+		d.line++
+		header, err := d.r.Read()
+		if err == io.EOF {
+			return store.Record{}, err
+		}
+		if err != nil {
+			return store.Record{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		if len(header) != len(csvHeader) && len(header) != csvLegacyColumns {
+			return store.Record{}, fmt.Errorf("%w: unexpected CSV header %v, want %v", ErrMalformed, header, csvHeader)
+		}
+		for i, name := range header {
+			if csvHeader[i] != name {
+				return store.Record{}, fmt.Errorf("%w: unexpected CSV header %v, want %v", ErrMalformed, header, csvHeader)
+			}
+		}
+	}
+	d.line++
+	row, err := d.r.Read()
+	if err == io.EOF {
+		return store.Record{}, err
+	}
+	if err != nil {
+		return store.Record{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	rec := store.Record{ShortKey: row[0], OriginalURL: row[1], CanonicalURL: row[2], UserID: row[3]}
+	if rec.IsDeleted, err = strconv.ParseBool(row[4]); err != nil {
+		return store.Record{}, fmt.Errorf("%w: line %d: invalid is_deleted %q", ErrMalformed, d.line, row[4])
+	}
+	times := []*time.Time{&rec.CreatedAt, &rec.UpdatedAt, &rec.DeletedAt}
+	for i, value := range row[csvLegacyColumns:] {
+		if *times[i], err = parseTime(value); err != nil {
+			return store.Record{}, fmt.Errorf("%w: line %d: invalid %s %q", ErrMalformed, d.line, csvHeader[csvLegacyColumns+i], value)
+		}
+	}
+	return rec, validate(rec, d.line)
+}
+
+func validate(rec store.Record, line int) error {
+	if rec.ShortKey == "" || rec.OriginalURL == "" {
+		return fmt.Errorf("%w: record %d: short_key and original_url are required", ErrMalformed, line)
+	}
+	return nil
+}
+
+// Export выгружает все ссылки src и возвращает их число.
+func Export(ctx context.Context, src store.Transferable, enc Encoder) (int, error) {
+	n := 0
+	err := src.Export(ctx, func(rec store.Record) error {
+		n++
+		return enc.Encode(rec)
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, enc.Flush()
+}
+
+// Options — настройки Import.
+type Options struct {
+	// Overwrite заменяет ссылки с уже занятыми ключами, иначе они пропускаются.
+	Overwrite bool
+	// BatchSize — число ссылок, записываемых за раз.
+	BatchSize int
+	// OnBatch, если задан, вызывается после каждой пачки с итогом на этот момент.
+	OnBatch func(total store.ImportResult)
+}
+
+// Import загружает ссылки пачками во все хранилища targets — так же, как новые
+// ссылки сохраняются во все настроенные хранилища. Итог считается только по первому,
+// основному хранилищу. Если пачка не записалась в одно из следующих, в предыдущих
+// она уже есть; ошибка называет хранилище, на котором запись прервалась.
+func Import(ctx context.Context, dec Decoder, targets []store.Transferable, opts Options) (store.ImportResult, error) {
+	var total store.ImportResult
+	if len(targets) == 0 {
+		return total, errors.New("no storage to import into")
+	}
+	batchSize := max(opts.BatchSize, 1)
+	batch := make([]store.Record, 0, batchSize)
+	flush := func() error {
+		for i, target := range targets {
+			result, err := target.Import(ctx, batch, opts.Overwrite)
+			if err != nil {
+				return fmt.Errorf("import into %T (target %d of %d): %w", target, i+1, len(targets), err)
+			}
+			if i == 0 {
+				total.Add(result)
+			}
+		}
+		batch = batch[:0]
+		if opts.OnBatch != nil {
+			opts.OnBatch(total)
+		}
+		return nil
+	}
+	for {
+		rec, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return total, err
+		}
+		if batch = append(batch, rec); len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return total, err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		return total, flush()
+	}
+	return total, nil
+}
@@ -0,0 +1,130 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dron1337/shortener/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRecords = []store.Record{
+	{ShortKey: "aaaa1111", OriginalURL: "https://example.com/a", CanonicalURL: "https://example.com/a", UserID: "user-1"},
+	{ShortKey: "bbbb2222", OriginalURL: "https://example.com/b,\"quoted\"", UserID: "user-2", IsDeleted: true,
+		Timestamps: store.Timestamps{
+			CreatedAt: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+			UpdatedAt: time.Date(2025, 3, 2, 10, 0, 0, 500, time.UTC),
+			DeletedAt: time.Date(2025, 3, 2, 10, 0, 0, 500, time.UTC),
+		}},
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{JSONL, CSV} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf, format)
+			for _, rec := range testRecords {
+				require.NoError(t, enc.Encode(rec))
+			}
+			require.NoError(t, enc.Flush())
+
+			dec := NewDecoder(&buf, format)
+			for _, want := range testRecords {
+				got, err := dec.Decode()
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+			_, err := dec.Decode()
+			assert.Equal(t, "EOF", err.Error())
+		})
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	for name, tt := range map[string]struct {
+		format Format
+		input  string
+	}{
+		"Broken JSON":    {JSONL, "{\"short_key\": \n"},
+		"Missing key":    {JSONL, `{"original_url":"https://example.com"}`},
+		"Wrong header":   {CSV, "key,url,canonical,user,deleted\n"},
+		"Invalid flag":   {CSV, "short_key,original_url,canonical_url,user_id,is_deleted\nk,https://example.com,,u,maybe\n"},
+		"Missing column": {CSV, "short_key,original_url,canonical_url,user_id,is_deleted\nk,https://example.com\n"},
+		"Invalid time": {CSV, "short_key,original_url,canonical_url,user_id,is_deleted,created_at,updated_at,deleted_at\n" +
+			"k,https://example.com,,u,false,yesterday,,\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewDecoder(strings.NewReader(tt.input), tt.format).Decode()
+			assert.ErrorIs(t, err, ErrMalformed)
+		})
+	}
+}
+
+func TestDecodeLegacyCSV(t *testing.T) {
+	input := "short_key,original_url,canonical_url,user_id,is_deleted\nk,https://example.com,,u,true\n"
+	got, err := NewDecoder(strings.NewReader(input), CSV).Decode()
+	require.NoError(t, err)
+	assert.Equal(t, store.Record{ShortKey: "k", OriginalURL: "https://example.com", UserID: "u", IsDeleted: true}, got)
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	src := store.NewInMemoryStorage()
+	require.NoError(t, src.Save(ctx, "user-1", "https://example.com/a", "https://example.com/a", "aaaa1111"))
+	require.NoError(t, src.Save(ctx, "user-2", "https://example.com/b", "https://example.com/b", "bbbb2222"))
+
+	var buf bytes.Buffer
+	n, err := Export(ctx, src, NewEncoder(&buf, CSV))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	exported := buf.String()
+
+	dst := store.NewInMemoryStorage()
+	require.NoError(t, dst.Save(ctx, "other", "https://old.example.com", "https://old.example.com", "aaaa1111"))
+	batches := 0
+	result, err := Import(ctx, NewDecoder(strings.NewReader(exported), CSV), []store.Transferable{dst}, Options{
+		BatchSize: 1,
+		OnBatch:   func(store.ImportResult) { batches++ },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, store.ImportResult{Imported: 1, Skipped: 1}, result)
+	assert.Equal(t, 2, batches)
+	u, _ := dst.GetOriginalURL(ctx, "aaaa1111")
+	assert.Equal(t, "https://old.example.com", u, "без overwrite занятый ключ не меняется")
+
+	result, err = Import(ctx, NewDecoder(strings.NewReader(exported), CSV), []store.Transferable{dst}, Options{Overwrite: true, BatchSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, store.ImportResult{Overwritten: 2}, result)
+	u, _ = dst.GetOriginalURL(ctx, "aaaa1111")
+	assert.Equal(t, "https://example.com/a", u)
+	assert.Empty(t, dst.GetURLsByUser(ctx, "other", "http://localhost"))
+}
+
+// failingTarget отклоняет любую пачку.
+type failingTarget struct {
+	store.Transferable
+}
+
+func (failingTarget) Import(context.Context, []store.Record, bool) (store.ImportResult, error) {
+	return store.ImportResult{}, errors.New("disk full")
+}
+
+func TestImportNamesFailedTarget(t *testing.T) {
+	ctx := context.Background()
+	primary := store.NewInMemoryStorage()
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, JSONL)
+	require.NoError(t, enc.Encode(testRecords[0]))
+	require.NoError(t, enc.Flush())
+	_, err := Import(ctx, NewDecoder(strings.NewReader(buf.String()), JSONL),
+		[]store.Transferable{primary, failingTarget{}}, Options{BatchSize: 10})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "transfer.failingTarget (target 2 of 2): disk full")
+	_, err = primary.GetOriginalURL(ctx, "aaaa1111")
+	assert.NoError(t, err, "пачка уже записана в хранилища до упавшего")
+}